@@ -0,0 +1,38 @@
+// auth/certificate.go
+package auth
+
+import "crypto/x509"
+
+// CertificateIdentity maps a client certificate subject, in the form
+// "CN=forms-builder,O=Stellarsky" or just "CN=forms-builder", to the service
+// identity it authenticates as.
+type CertificateIdentity struct {
+	Subject  string
+	Identity string
+}
+
+// CertificateMapper resolves verified client certificates to identities.
+type CertificateMapper struct {
+	bySubject map[string]string
+}
+
+func NewCertificateMapper(identities []CertificateIdentity) *CertificateMapper {
+	m := &CertificateMapper{bySubject: make(map[string]string, len(identities))}
+	for _, id := range identities {
+		m.bySubject[id.Subject] = id.Identity
+	}
+	return m
+}
+
+// Identity returns the identity mapped to cert's full subject, or failing
+// that to its common name.
+func (m *CertificateMapper) Identity(cert *x509.Certificate) (string, bool) {
+	if m == nil || cert == nil {
+		return "", false
+	}
+	if id, ok := m.bySubject[cert.Subject.String()]; ok {
+		return id, true
+	}
+	id, ok := m.bySubject["CN="+cert.Subject.CommonName]
+	return id, ok
+}
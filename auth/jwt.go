@@ -0,0 +1,111 @@
+// auth/jwt.go
+package auth
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the JWT claims this service understands. Roles maps a namespace,
+// or "*", to a role name.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles map[string]string `json:"roles,omitempty"`
+}
+
+// JWTVerifier checks token signatures against the configured keys.
+type JWTVerifier struct {
+	hmacSecret []byte
+	publicKeys []crypto.PublicKey
+	options    []jwt.ParserOption
+}
+
+// NewJWTVerifier builds a verifier from an HMAC secret and PEM encoded RSA,
+// ECDSA or Ed25519 public key files. Either may be empty; with neither, every
+// token is rejected.
+func NewJWTVerifier(hmacSecret string, publicKeyFiles []string, issuer, audience string) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		options: []jwt.ParserOption{
+			jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512",
+				"PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+			jwt.WithExpirationRequired(),
+		},
+	}
+	if hmacSecret != "" {
+		v.hmacSecret = []byte(hmacSecret)
+	}
+	for _, file := range publicKeyFiles {
+		key, err := loadPublicKey(file)
+		if err != nil {
+			return nil, err
+		}
+		v.publicKeys = append(v.publicKeys, key)
+	}
+	if issuer != "" {
+		v.options = append(v.options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		v.options = append(v.options, jwt.WithAudience(audience))
+	}
+	return v, nil
+}
+
+func loadPublicKey(file string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading jwt public key: %w", err)
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported jwt public key in %s", file)
+}
+
+// Verify parses token, checks its signature and registered claims and returns
+// the principal it names.
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, v.keyFunc, v.options...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	p := &Principal{Subject: claims.Subject, Method: "jwt"}
+	for namespace, name := range claims.Roles {
+		if role := Role(name); role.Valid() {
+			p.Grant(namespace, role)
+		}
+	}
+	return p, nil
+}
+
+func (v *JWTVerifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.hmacSecret == nil {
+			return nil, fmt.Errorf("hmac tokens are not accepted")
+		}
+		return v.hmacSecret, nil
+	default:
+		if len(v.publicKeys) == 0 {
+			return nil, fmt.Errorf("no public keys configured")
+		}
+		set := jwt.VerificationKeySet{}
+		for _, key := range v.publicKeys {
+			set.Keys = append(set.Keys, key)
+		}
+		return set, nil
+	}
+}
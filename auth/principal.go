@@ -0,0 +1,126 @@
+// auth/principal.go
+package auth
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// Role is a level of access within a namespace. Each role includes every
+// permission of the roles below it.
+type Role string
+
+const (
+	RoleViewer    Role = "viewer"
+	RoleEditor    Role = "editor"
+	RolePublisher Role = "publisher"
+	RoleAdmin     Role = "admin"
+)
+
+// AllNamespaces is the namespace a role is bound to when it applies everywhere.
+const AllNamespaces = "*"
+
+var roleRank = map[Role]int{
+	RoleViewer:    1,
+	RoleEditor:    2,
+	RolePublisher: 3,
+	RoleAdmin:     4,
+}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Includes reports whether r grants at least the permissions of other.
+func (r Role) Includes(other Role) bool {
+	return roleRank[r] >= roleRank[other] && roleRank[other] > 0
+}
+
+var (
+	// ErrNoCredentials is returned when a request carries no API key or token.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned for unknown, revoked or expired keys
+	// and for tokens that fail verification.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	// Method is how the principal authenticated: "api_key", "jwt", "mtls" or
+	// "anonymous".
+	Method string
+	// KeyID is the ID of the API key used, for API key principals.
+	KeyID uint64
+	// Roles maps a namespace, or AllNamespaces, to the role held in it.
+	Roles map[string]Role
+}
+
+// Grant gives p role in namespace, keeping any higher role it already holds.
+func (p *Principal) Grant(namespace string, role Role) {
+	if p.Roles == nil {
+		p.Roles = map[string]Role{}
+	}
+	if current, ok := p.Roles[namespace]; !ok || !current.Includes(role) {
+		p.Roles[namespace] = role
+	}
+}
+
+// Can reports whether p holds at least role in namespace.
+func (p *Principal) Can(namespace string, role Role) bool {
+	if p == nil {
+		return false
+	}
+	if r, ok := p.Roles[AllNamespaces]; ok && r.Includes(role) {
+		return true
+	}
+	if namespace == AllNamespaces {
+		return false
+	}
+	r, ok := p.Roles[namespace]
+	return ok && r.Includes(role)
+}
+
+// Namespaces lists the namespaces in which p holds at least role, or reports
+// all when p holds it in every namespace.
+func (p *Principal) Namespaces(role Role) (namespaces []string, all bool) {
+	if p == nil {
+		return []string{}, false
+	}
+	if r, ok := p.Roles[AllNamespaces]; ok && r.Includes(role) {
+		return nil, true
+	}
+	namespaces = []string{}
+	for ns, r := range p.Roles {
+		if ns != AllNamespaces && r.Includes(role) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, false
+}
+
+// Anonymous is the principal used for every request when authentication is
+// disabled. It holds admin in every namespace.
+func Anonymous() *Principal {
+	return &Principal{
+		Subject: "anonymous",
+		Method:  "anonymous",
+		Roles:   map[string]Role{AllNamespaces: RoleAdmin},
+	}
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal, or nil.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
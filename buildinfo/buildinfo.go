@@ -0,0 +1,29 @@
+// buildinfo/buildinfo.go
+package buildinfo
+
+import "runtime/debug"
+
+// Version and Commit are set at link time, for example
+//
+//	go build -ldflags "-X stellarsky.ai/platform/public-config-service/buildinfo.Version=1.4.0
+//	  -X stellarsky.ai/platform/public-config-service/buildinfo.Commit=$(git rev-parse HEAD)"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Info returns the build version and commit. Without a linked commit it falls
+// back to the VCS revision the Go toolchain stamped into the binary.
+func Info() (version, commit string) {
+	commit = Commit
+	if commit == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range info.Settings {
+				if s.Key == "vcs.revision" {
+					commit = s.Value
+				}
+			}
+		}
+	}
+	return Version, commit
+}
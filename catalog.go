@@ -0,0 +1,133 @@
+// catalog.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+
+	"stellarsky.ai/platform/public-config-service/db"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/repository"
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+func newCatalogService(database *gorm.DB, logger *slog.Logger) *service.CatalogService {
+	return service.NewCatalogService(
+		repository.NewTypeRepository(database, logger),
+		repository.NewValidationRepository(database, logger),
+		repository.NewOptionSetRepository(database, logger),
+		repository.NewAttributeRepository(database, logger),
+		repository.NewFormRepository(database, logger),
+		repository.NewCatalogRepository(database, logger),
+		logger)
+}
+
+func seed(args []string) int {
+	fs, common := newFlagSet("seed", "Upsert the default types and validations. Running it again changes nothing.")
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	if code, done := parseFlags(fs, args); done {
+		return code
+	}
+	a, code := loadApp(common, os.Stderr)
+	if code != exitOK {
+		return code
+	}
+	defaults, err := db.DefaultCatalog()
+	if err != nil {
+		a.logger.Error("could not read default catalog", slog.Any("error", err))
+		return exitFailure
+	}
+	return a.importCatalog(defaults, *dryRun)
+}
+
+func importCatalog(args []string) int {
+	fs, common := newFlagSet("import", "Upsert a catalog by namespace, family and name in one transaction.")
+	file := fs.String("file", "-", "catalog JSON to read, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "report what would change without writing")
+	if code, done := parseFlags(fs, args); done {
+		return code
+	}
+	a, code := loadApp(common, os.Stderr)
+	if code != exitOK {
+		return code
+	}
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			a.logger.Error("could not open catalog", slog.Any("error", err))
+			return exitFailure
+		}
+		defer f.Close()
+		in = f
+	}
+	var c model.Catalog
+	if err := json.NewDecoder(in).Decode(&c); err != nil {
+		a.logger.Error("could not decode catalog", slog.Any("error", err))
+		return exitFailure
+	}
+	return a.importCatalog(&c, *dryRun)
+}
+
+// importCatalog imports c and writes the report to stdout.
+func (a *app) importCatalog(c *model.Catalog, dryRun bool) int {
+	database, code := a.openDB(false)
+	if code != exitOK {
+		return code
+	}
+	report, err := newCatalogService(database, a.logger).Import(context.Background(), c, dryRun)
+	if err != nil {
+		a.logger.Error("import failed", slog.Any("error", err))
+		return exitFailure
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	return exitOK
+}
+
+func exportCatalog(args []string) int {
+	fs, common := newFlagSet("export", "Write every live entity as a catalog that import accepts.")
+	file := fs.String("file", "-", "file to write, - for stdout")
+	namespace := fs.String("namespace", "", "only export this namespace")
+	if code, done := parseFlags(fs, args); done {
+		return code
+	}
+	a, code := loadApp(common, os.Stderr)
+	if code != exitOK {
+		return code
+	}
+	database, code := a.openDB(false)
+	if code != exitOK {
+		return code
+	}
+	c, err := newCatalogService(database, a.logger).Export(context.Background(), *namespace)
+	if err != nil {
+		a.logger.Error("export failed", slog.Any("error", err))
+		return exitFailure
+	}
+
+	out := io.Writer(os.Stdout)
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			a.logger.Error("could not create export file", slog.Any("error", err))
+			return exitFailure
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		a.logger.Error("could not write catalog", slog.Any("error", err))
+		return exitFailure
+	}
+	return exitOK
+}
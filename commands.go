@@ -0,0 +1,216 @@
+// commands.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+
+	"stellarsky.ai/platform/public-config-service/config"
+	"stellarsky.ai/platform/public-config-service/db"
+	"stellarsky.ai/platform/public-config-service/logging"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+// Exit codes shared by every command.
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command ran and failed
+	exitUsage   = 2 // bad command line
+	exitConfig  = 3 // configuration missing or invalid
+	exitDB      = 4 // database unreachable or not migrated
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"serve", "Run the HTTP server (the default)", serve},
+	{"migrate", "Migrate the database schema", migrate},
+	{"seed", "Upsert the default types and validations", seed},
+	{"import", "Import a catalog exported by export", importCatalog},
+	{"export", "Export live entities as a catalog", exportCatalog},
+	{"check", "Validate the configuration and database connectivity", check},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to a command; without one it serves, as the binary always
+// has.
+func run(args []string) int {
+	if len(args) == 0 || (len(args[0]) > 0 && args[0][0] == '-') {
+		return serve(args)
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	if args[0] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	}
+	usage(os.Stderr)
+	if args[0] == "help" {
+		return exitOK
+	}
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: public-config-service <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nRun 'public-config-service <command> -h' for a command's flags.")
+	fmt.Fprintln(w, "Exit codes: 0 ok, 1 failure, 2 usage, 3 configuration, 4 database.")
+}
+
+// commonFlags are accepted by every command.
+type commonFlags struct {
+	profile   string
+	configDir string
+}
+
+func newFlagSet(name, summary string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	common := &commonFlags{}
+	fs.StringVar(&common.profile, "profile", config.Profile(), "config profile merged over config.yaml (default $"+config.ProfileEnv+")")
+	fs.StringVar(&common.configDir, "config-dir", "", "directory holding config.yaml (default . and ./config)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: public-config-service %s [flags]\n\n%s\n\nFlags:\n", name, summary)
+		fs.PrintDefaults()
+	}
+	return fs, common
+}
+
+// parseFlags parses args into fs. When done is true the command must exit
+// with code straight away, as after -h or a bad flag.
+func parseFlags(fs *flag.FlagSet, args []string) (code int, done bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, true
+		}
+		return exitUsage, true
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		fs.Usage()
+		return exitUsage, true
+	}
+	return exitOK, false
+}
+
+// app holds what every command needs once flags are parsed.
+type app struct {
+	cfg      *config.Config
+	loader   *config.Loader
+	logger   *slog.Logger
+	logLevel *slog.LevelVar
+}
+
+// loadApp builds the logger, writing to w, and loads the configuration.
+func loadApp(f *commonFlags, w io.Writer) (*app, int) {
+	logLevel := &slog.LevelVar{}
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(w,
+		&slog.HandlerOptions{Level: logLevel, ReplaceAttr: logging.RedactAttr})))
+
+	var paths []string
+	if f.configDir != "" {
+		paths = []string{f.configDir}
+	}
+	loader := config.NewLoader(f.profile, logger, paths...)
+	cfg, err := loader.Load()
+	if err != nil {
+		logger.Error("could not load configuration", slog.Any("error", err))
+		return nil, exitConfig
+	}
+	logLevel.UnmarshalText([]byte(cfg.Log.Level))
+	return &app{cfg: cfg, loader: loader, logger: logger, logLevel: logLevel}, exitOK
+}
+
+// openDB connects to the database and, with migrate, brings the schema up to
+// date. Without it the schema must already be at db.SchemaVersion.
+func (a *app) openDB(migrate bool) (*gorm.DB, int) {
+	database, err := db.InitDB(a.cfg)
+	if err != nil {
+		a.logger.Error("could not initialize database", slog.Any("error", err))
+		return nil, exitDB
+	}
+	if migrate {
+		if err := db.Migrate(database); err != nil {
+			a.logger.Error("could not migrate database", slog.Any("error", err))
+			return nil, exitDB
+		}
+		return database, exitOK
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	version, err := repository.NewHealthRepository(database, a.logger).SchemaVersion(ctx)
+	if err != nil {
+		a.logger.Error("could not read schema version", slog.Any("error", err))
+		return nil, exitDB
+	}
+	if version != db.SchemaVersion {
+		a.logger.Error("database schema is not migrated, run the migrate command",
+			slog.Int("schema_version", version), slog.Int("expected", db.SchemaVersion))
+		return nil, exitDB
+	}
+	return database, exitOK
+}
+
+func migrate(args []string) int {
+	fs, common := newFlagSet("migrate", "Migrate the database schema to the version this binary expects.")
+	if code, done := parseFlags(fs, args); done {
+		return code
+	}
+	a, code := loadApp(common, os.Stderr)
+	if code != exitOK {
+		return code
+	}
+	if _, code := a.openDB(true); code != exitOK {
+		return code
+	}
+	a.logger.Info("database migrated", slog.Int("schema_version", db.SchemaVersion))
+	return exitOK
+}
+
+func check(args []string) int {
+	fs, common := newFlagSet("check", "Validate the configuration and, unless -skip-db, database connectivity and schema version.")
+	skipDB := fs.Bool("skip-db", false, "only validate the configuration")
+	if code, done := parseFlags(fs, args); done {
+		return code
+	}
+	a, code := loadApp(common, os.Stderr)
+	if code != exitOK {
+		return code
+	}
+	a.logger.Info("configuration is valid")
+	if *skipDB {
+		return exitOK
+	}
+	database, code := a.openDB(false)
+	if code != exitOK {
+		return code
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := repository.NewHealthRepository(database, a.logger).Ping(ctx); err != nil {
+		a.logger.Error("database is unreachable", slog.Any("error", err))
+		return exitDB
+	}
+	a.logger.Info("database is reachable and migrated", slog.Int("schema_version", db.SchemaVersion))
+	return exitOK
+}
@@ -1,20 +1,238 @@
 // config/config.go
 package config
 
+import "time"
+
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
+	Log       LogConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	Purge     PurgeConfig
+	Tracing   TracingConfig
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	HTTP      HTTPConfig
+	// DataSources bounds the option lookups proxied for attributes with a
+	// data source. It can be changed without a restart.
+	DataSources DataSourcesConfig `mapstructure:"data_sources"`
+}
+
+type LogConfig struct {
+	// Level is "debug", "info", "warn" or "error". It can be changed without
+	// a restart.
+	Level string
 }
 
 type ServerConfig struct {
 	Port string
+	// RequestTimeout is the deadline applied to a request's context, and so to
+	// every query it runs, unless the caller sends X-Request-Timeout.
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+	// MaxRequestTimeout caps the deadline a caller may ask for.
+	MaxRequestTimeout time.Duration `mapstructure:"max_request_timeout"`
+	// ShutdownDrainDelay is how long the server keeps serving after it starts
+	// failing readiness, giving load balancers time to stop routing to it.
+	ShutdownDrainDelay time.Duration `mapstructure:"shutdown_drain_delay"`
+	// ShutdownTimeout bounds the wait for in-flight requests on shutdown.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// MaxBatchOperations caps the number of operations in one request to a
+	// batch endpoint.
+	MaxBatchOperations int `mapstructure:"max_batch_operations"`
+	TLS                TLSConfig
+}
+
+type TLSConfig struct {
+	Enabled  bool
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ClientCAFile enables mutual TLS: client certificates are verified
+	// against the CAs in this PEM bundle.
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuth is "request" to verify a client certificate when one is sent
+	// or "require" to reject connections without one.
+	ClientAuth string `mapstructure:"client_auth"`
+	// ClientIdentities maps verified certificate subjects to the service
+	// identities they authenticate as.
+	ClientIdentities []ClientIdentity `mapstructure:"client_identities"`
+	// ReloadInterval is how often the files are checked for changes; zero
+	// disables reloading.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+type ClientIdentity struct {
+	Subject  string
+	Identity string
 }
 
 type DatabaseConfig struct {
 	Host     string
 	Port     int
 	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Password Secret
+	// PasswordFile, when set, is read for the password instead.
+	PasswordFile string `mapstructure:"password_file"`
+	DBName       string
+	SSLMode      string
+	// MaxOpenConns and MaxIdleConns size the connection pool; zero leaves
+	// database/sql's defaults.
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	// StatementTimeout makes Postgres cancel any statement running longer,
+	// a backstop for queries whose request context is not cancelled.
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+	ConnectTimeout   time.Duration `mapstructure:"connect_timeout"`
+}
+
+type AuthConfig struct {
+	// Enabled turns on authentication for /api/v1. When false every request
+	// runs as an anonymous admin, which is only meant for local development.
+	Enabled bool
+	JWT     JWTConfig
+}
+
+type JWTConfig struct {
+	Issuer   string
+	Audience string
+	// HMACSecret verifies HS256/384/512 tokens.
+	HMACSecret Secret `mapstructure:"hmac_secret"`
+	// HMACSecretFile, when set, is read for the HMAC secret instead.
+	HMACSecretFile string `mapstructure:"hmac_secret_file"`
+	// PublicKeyFiles are PEM encoded RSA, ECDSA or Ed25519 keys that verify
+	// asymmetrically signed tokens.
+	PublicKeyFiles []string `mapstructure:"public_key_files"`
+}
+
+type DataSourcesConfig struct {
+	// Timeout bounds each request to a data source.
+	Timeout time.Duration
+	// CacheTTL is how long options are cached for data sources that do not
+	// set their own; zero disables caching.
+	CacheTTL        time.Duration `mapstructure:"cache_ttl"`
+	MaxCacheEntries int           `mapstructure:"max_cache_entries"`
+	// MaxResponseBytes caps the body read from a data source.
+	MaxResponseBytes int64 `mapstructure:"max_response_bytes"`
+	// AllowedHosts lists the only hosts data sources may point at; when it
+	// is empty data sources are not looked up. Loopback, link-local and
+	// private addresses are only reached when an entry is the address or a
+	// network containing it, such as "10.0.0.0/8".
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+}
+
+type PurgeConfig struct {
+	// Retention is how long soft-deleted entities stay restorable before the
+	// purge job removes them for good.
+	Retention time.Duration
+	// Interval is how often the purge job runs; zero disables it.
+	Interval time.Duration
+}
+
+type TracingConfig struct {
+	// Exporter selects where spans go: "otlp", "stdout", "memory" or "none".
+	Exporter string
+	// Endpoint is the OTLP/gRPC collector address, e.g. "localhost:4317".
+	Endpoint string
+	// Insecure disables TLS to the collector.
+	Insecure bool
+	// SampleRatio is the fraction of new traces that are recorded, from 0,
+	// which records none, to 1. Traces started upstream keep the caller's
+	// sampling decision.
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+	ServiceName string  `mapstructure:"service_name"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	// Key is what client budgets are keyed on: "credential" (API key or JWT
+	// subject, else client IP) or "ip".
+	Key string
+	// TrustedProxies is the number of proxies in front of the service that
+	// append to X-Forwarded-For. The client IP is taken that many entries from
+	// the right of the header; with 0 the header is ignored.
+	TrustedProxies int `mapstructure:"trusted_proxies"`
+	// Read and Write are the budgets of each client.
+	Read  RateLimitBudget
+	Write RateLimitBudget
+	// Namespace holds budgets shared by all clients per namespace. A zero rate
+	// disables namespace limiting.
+	Namespace RateLimitBudgets
+	// IP holds budgets per client IP charged before authentication, so
+	// requests with bad or missing credentials are limited too. A zero rate
+	// disables IP limiting.
+	IP RateLimitBudgets
+}
+
+type RateLimitBudgets struct {
+	Read  RateLimitBudget
+	Write RateLimitBudget
+}
+
+// RateLimitBudget is a token bucket refilled at Rate requests per second and
+// holding at most Burst.
+type RateLimitBudget struct {
+	Rate  float64
+	Burst int
+}
+
+type HTTPConfig struct {
+	CORS            CORSConfig
+	Compression     CompressionConfig
+	SecurityHeaders SecurityHeadersConfig `mapstructure:"security_headers"`
+	// Groups overrides the sections above for a route group: "api" for
+	// /api/v1 and "ops" for the probes and /metrics. A section set in a group
+	// replaces the default section whole.
+	Groups map[string]HTTPGroupConfig
+}
+
+type HTTPGroupConfig struct {
+	CORS            *CORSConfig
+	Compression     *CompressionConfig
+	SecurityHeaders *SecurityHeadersConfig `mapstructure:"security_headers"`
+}
+
+// ForGroup returns the settings that apply to the named route group.
+func (c HTTPConfig) ForGroup(name string) HTTPConfig {
+	out := HTTPConfig{CORS: c.CORS, Compression: c.Compression, SecurityHeaders: c.SecurityHeaders}
+	if g, ok := c.Groups[name]; ok {
+		if g.CORS != nil {
+			out.CORS = *g.CORS
+		}
+		if g.Compression != nil {
+			out.Compression = *g.Compression
+		}
+		if g.SecurityHeaders != nil {
+			out.SecurityHeaders = *g.SecurityHeaders
+		}
+	}
+	return out
+}
+
+type CORSConfig struct {
+	Enabled bool
+	// AllowedOrigins lists origins allowed to call the API; "*" allows any.
+	AllowedOrigins   []string `mapstructure:"allowed_origins"`
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"`
+	ExposedHeaders   []string `mapstructure:"exposed_headers"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	// MaxAge is how many seconds browsers may cache a preflight result.
+	MaxAge int `mapstructure:"max_age"`
+}
+
+type CompressionConfig struct {
+	Enabled bool
+	// MinSize is the smallest response body, in bytes, worth compressing.
+	MinSize int `mapstructure:"min_size"`
+	// Level is the gzip and brotli level; zero uses each one's default.
+	Level int
+}
+
+type SecurityHeadersConfig struct {
+	Enabled               bool
+	ContentSecurityPolicy string `mapstructure:"content_security_policy"`
+	FrameOptions          string `mapstructure:"frame_options"`
+	ReferrerPolicy        string `mapstructure:"referrer_policy"`
+	// HSTSMaxAge, in seconds, enables Strict-Transport-Security over TLS.
+	HSTSMaxAge int `mapstructure:"hsts_max_age"`
 }
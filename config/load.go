@@ -2,33 +2,177 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	"golang.org/x/exp/slog"
 )
 
-func LoadConfig(logger *slog.Logger) *Config {
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath("./config")
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+// ProfileEnv names the environment variable that selects a profile, such as
+// dev, test or prod, whose config.<profile>.yaml is merged over config.yaml.
+const ProfileEnv = "APP_PROFILE"
 
-	if err := viper.ReadInConfig(); err != nil {
-		logger.Error("Error reading config file", slog.Any("error", err))
-		os.Exit(1)
+// reloadable lists the sections applied at runtime when the files change.
+// Changes anywhere else are logged and take effect on the next restart.
+var reloadable = map[string]bool{"log": true, "rate_limit": true, "http": true, "data_sources": true}
+
+// Loader reads, validates and watches the configuration.
+type Loader struct {
+	profile string
+	paths   []string
+	logger  *slog.Logger
+
+	mu       sync.Mutex
+	current  *Config
+	files    []string
+	onReload []func(*Config)
+}
+
+// NewLoader returns a Loader for profile, which may be empty for the base
+// config alone. It searches paths, by default the working directory and
+// ./config.
+func NewLoader(profile string, logger *slog.Logger, paths ...string) *Loader {
+	if len(paths) == 0 {
+		paths = []string{".", "./config"}
+	}
+	return &Loader{
+		profile: profile,
+		paths:   paths,
+		logger:  logger,
 	}
+}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		logger.Error("Error unmarshaling config", slog.Any("error", err))
-		os.Exit(1)
+// Load reads the base config, merges the profile over it, applies
+// environment overrides such as SERVER_PORT, resolves secrets and validates
+// the result.
+func (l *Loader) Load() (*Config, error) {
+	cfg, files, err := l.read()
+	if err != nil {
+		return nil, err
 	}
+	l.mu.Lock()
+	l.current, l.files = cfg, files
+	l.mu.Unlock()
+
+	l.logger.Info("Application Config loaded",
+		slog.String("profile", l.profile), slog.Any("files", files), slog.Any("config", cfg))
+	return cfg, nil
+}
 
-	logger.Info("Application Config loaded", slog.Any("config", config))
+func (l *Loader) read() (*Config, []string, error) {
+	v := viper.New()
+	v.SetConfigName("config")
+	v.SetConfigType("yaml")
+	for _, p := range l.paths {
+		v.AddConfigPath(p)
+	}
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, nil, fmt.Errorf("reading config file: %w", err)
+	}
+	files := []string{v.ConfigFileUsed()}
+	if l.profile != "" {
+		v.SetConfigName("config." + l.profile)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("reading profile %q: %w", l.profile, err)
+		}
+		files = append(files, v.ConfigFileUsed())
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, fmt.Errorf("decoding config: %w", err)
+	}
+	if err := cfg.ResolveSecrets(); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, files, nil
+}
+
+// OnReload registers fn to receive each new configuration after a reload.
+// Only the reloadable sections should be applied from it.
+func (l *Loader) OnReload(fn func(*Config)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onReload = append(l.onReload, fn)
+}
+
+// Watch reloads the configuration whenever one of the loaded files changes.
+// A reload that fails to read or validate is logged and the current settings
+// stay in place.
+func (l *Loader) Watch() {
+	l.mu.Lock()
+	files := l.files
+	l.mu.Unlock()
+	for _, f := range files {
+		w := viper.New()
+		w.SetConfigFile(f)
+		w.OnConfigChange(func(e fsnotify.Event) { l.reload(e.Name) })
+		w.WatchConfig()
+	}
+}
+
+func (l *Loader) reload(file string) {
+	cfg, _, err := l.read()
+	if err != nil {
+		l.logger.Error("config reload failed, keeping current settings",
+			slog.String("file", file), slog.Any("error", err))
+		return
+	}
+
+	l.mu.Lock()
+	old := l.current
+	if reflect.DeepEqual(old, cfg) {
+		l.mu.Unlock()
+		return
+	}
+	l.current = cfg
+	callbacks := l.onReload
+	l.mu.Unlock()
+
+	applied, pending := changedSections(old, cfg)
+	if len(pending) > 0 {
+		l.logger.Warn("config changes need a restart to take effect", slog.Any("sections", pending))
+	}
+	l.logger.Info("config reloaded", slog.String("file", file), slog.Any("applied", applied))
+	for _, fn := range callbacks {
+		fn(cfg)
+	}
+}
+
+// changedSections splits the top-level sections that differ into those
+// applied at runtime and those that wait for a restart.
+func changedSections(old, cfg *Config) (applied, pending []string) {
+	ov, nv := reflect.ValueOf(*old), reflect.ValueOf(*cfg)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+		name := t.Field(i).Tag.Get("mapstructure")
+		if name == "" {
+			name = strings.ToLower(t.Field(i).Name)
+		}
+		if reloadable[name] {
+			applied = append(applied, name)
+		} else {
+			pending = append(pending, name)
+		}
+	}
+	return applied, pending
+}
 
-	return &config
+// Profile returns the profile selected by the environment.
+func Profile() string {
+	return os.Getenv(ProfileEnv)
 }
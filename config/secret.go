@@ -0,0 +1,80 @@
+// config/secret.go
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a config value that must never be printed. It formats, marshals
+// and logs as "[REDACTED]"; Value returns the real string.
+//
+// In config a secret may be written inline, as "env:NAME" to read environment
+// variable NAME, or as "file:/path" to read a file such as a mounted
+// Kubernetes secret.
+type Secret string
+
+func (s Secret) Value() string { return string(s) }
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) GoString() string { return s.String() }
+
+func (s Secret) LogValue() slog.Value { return slog.StringValue(s.String()) }
+
+func (s Secret) MarshalJSON() ([]byte, error) { return []byte(`"` + s.String() + `"`), nil }
+
+func (s Secret) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+// resolve replaces an env: or file: reference with what it points to. file,
+// when set, is a path read in place of the inline value.
+func (s Secret) resolve(file string) (Secret, error) {
+	if file != "" {
+		return readSecretFile(file)
+	}
+	v := string(s)
+	switch {
+	case strings.HasPrefix(v, "env:"):
+		name := strings.TrimPrefix(v, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return Secret(value), nil
+	case strings.HasPrefix(v, "file:"):
+		return readSecretFile(strings.TrimPrefix(v, "file:"))
+	default:
+		return s, nil
+	}
+}
+
+func readSecretFile(path string) (Secret, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+	return Secret(strings.TrimRight(string(b), "\r\n")), nil
+}
+
+// ResolveSecrets loads every secret given by reference or by a *_file
+// setting.
+func (c *Config) ResolveSecrets() error {
+	var err error
+	if c.Database.Password, err = c.Database.Password.resolve(c.Database.PasswordFile); err != nil {
+		return fmt.Errorf("database.password: %w", err)
+	}
+	if c.Auth.JWT.HMACSecret, err = c.Auth.JWT.HMACSecret.resolve(c.Auth.JWT.HMACSecretFile); err != nil {
+		return fmt.Errorf("auth.jwt.hmac_secret: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// config/validate.go
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate checks every setting and reports all problems at once, each
+// prefixed with the key it concerns.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.oneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error")
+
+	s := c.Server
+	if port, err := strconv.Atoi(s.Port); err != nil || port < 1 || port > 65535 {
+		v.add("server.port", "must be a port number between 1 and 65535, got %q", s.Port)
+	}
+	v.nonNegative("server.request_timeout", s.RequestTimeout)
+	v.nonNegative("server.max_request_timeout", s.MaxRequestTimeout)
+	if s.MaxRequestTimeout > 0 && s.MaxRequestTimeout < s.RequestTimeout {
+		v.add("server.max_request_timeout", "must not be less than server.request_timeout (%s)", s.RequestTimeout)
+	}
+	v.nonNegative("server.shutdown_drain_delay", s.ShutdownDrainDelay)
+	v.nonNegative("server.shutdown_timeout", s.ShutdownTimeout)
+	if s.MaxBatchOperations < 1 {
+		v.add("server.max_batch_operations", "must be at least 1, got %d", s.MaxBatchOperations)
+	}
+	if t := s.TLS; t.Enabled {
+		v.required("server.tls.cert_file", t.CertFile)
+		v.required("server.tls.key_file", t.KeyFile)
+		v.nonNegative("server.tls.reload_interval", t.ReloadInterval)
+		if t.ClientCAFile != "" {
+			v.oneOf("server.tls.client_auth", t.ClientAuth, "", "none", "request", "require")
+		}
+		for i, id := range t.ClientIdentities {
+			v.required(fmt.Sprintf("server.tls.client_identities[%d].subject", i), id.Subject)
+			v.required(fmt.Sprintf("server.tls.client_identities[%d].identity", i), id.Identity)
+		}
+	}
+
+	d := c.Database
+	v.required("database.host", d.Host)
+	if d.Port < 1 || d.Port > 65535 {
+		v.add("database.port", "must be between 1 and 65535, got %d", d.Port)
+	}
+	v.required("database.user", d.User)
+	v.required("database.dbname", d.DBName)
+	v.oneOf("database.sslmode", d.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	if d.MaxOpenConns < 0 {
+		v.add("database.max_open_conns", "must not be negative")
+	}
+	if d.MaxIdleConns < 0 {
+		v.add("database.max_idle_conns", "must not be negative")
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		v.add("database.max_idle_conns", "must not exceed database.max_open_conns (%d)", d.MaxOpenConns)
+	}
+	v.nonNegative("database.conn_max_lifetime", d.ConnMaxLifetime)
+	v.nonNegative("database.conn_max_idle_time", d.ConnMaxIdleTime)
+	v.nonNegative("database.statement_timeout", d.StatementTimeout)
+	v.nonNegative("database.connect_timeout", d.ConnectTimeout)
+
+	ds := c.DataSources
+	if ds.Timeout <= 0 {
+		v.add("data_sources.timeout", "must be positive, got %s", ds.Timeout)
+	}
+	v.nonNegative("data_sources.cache_ttl", ds.CacheTTL)
+	if ds.MaxCacheEntries < 1 {
+		v.add("data_sources.max_cache_entries", "must be at least 1, got %d", ds.MaxCacheEntries)
+	}
+	if ds.MaxResponseBytes < 1 {
+		v.add("data_sources.max_response_bytes", "must be at least 1, got %d", ds.MaxResponseBytes)
+	}
+	for _, host := range ds.AllowedHosts {
+		if _, _, err := net.ParseCIDR(host); strings.Contains(host, "/") && err != nil {
+			v.add("data_sources.allowed_hosts", "%q is not a host, address or network", host)
+		}
+	}
+
+	if c.Purge.Interval > 0 && c.Purge.Retention <= 0 {
+		v.add("purge.retention", "must be positive when purge.interval is set")
+	}
+	v.nonNegative("purge.interval", c.Purge.Interval)
+
+	t := c.Tracing
+	v.oneOf("tracing.exporter", strings.ToLower(t.Exporter), "", "none", "otlp", "stdout", "memory")
+	if strings.EqualFold(t.Exporter, "otlp") {
+		v.required("tracing.endpoint", t.Endpoint)
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		v.add("tracing.sample_ratio", "must be between 0 and 1, got %v", t.SampleRatio)
+	}
+
+	rl := c.RateLimit
+	v.oneOf("rate_limit.key", rl.Key, "credential", "ip")
+	if rl.TrustedProxies < 0 {
+		v.add("rate_limit.trusted_proxies", "must not be negative, got %d", rl.TrustedProxies)
+	}
+	v.budget("rate_limit.read", rl.Read)
+	v.budget("rate_limit.write", rl.Write)
+	v.budget("rate_limit.namespace.read", rl.Namespace.Read)
+	v.budget("rate_limit.namespace.write", rl.Namespace.Write)
+	v.budget("rate_limit.ip.read", rl.IP.Read)
+	v.budget("rate_limit.ip.write", rl.IP.Write)
+
+	v.http("http", HTTPConfig{CORS: c.HTTP.CORS, Compression: c.HTTP.Compression, SecurityHeaders: c.HTTP.SecurityHeaders})
+	for name := range c.HTTP.Groups {
+		if name != "api" && name != "ops" {
+			v.add("http.groups."+name, "unknown route group, expected api or ops")
+			continue
+		}
+		v.http("http.groups."+name, c.HTTP.ForGroup(name))
+	}
+
+	return v.err()
+}
+
+type validator struct {
+	problems []error
+}
+
+func (v *validator) add(key, format string, args ...any) {
+	v.problems = append(v.problems, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+}
+
+func (v *validator) required(key, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(key, "is required")
+	}
+}
+
+func (v *validator) nonNegative(key string, d time.Duration) {
+	if d < 0 {
+		v.add(key, "must not be negative, got %s", d)
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(key, "must be one of %s, got %q", strings.Join(nonEmpty(allowed), ", "), value)
+}
+
+func (v *validator) budget(key string, b RateLimitBudget) {
+	if b.Rate < 0 {
+		v.add(key+".rate", "must not be negative")
+	}
+	if b.Burst < 0 {
+		v.add(key+".burst", "must not be negative")
+	}
+	if b.Rate > 0 && b.Burst == 0 {
+		v.add(key+".burst", "must be at least 1 when a rate is set")
+	}
+}
+
+func (v *validator) http(key string, c HTTPConfig) {
+	if c.CORS.Enabled && len(c.CORS.AllowedOrigins) == 0 {
+		v.add(key+".cors.allowed_origins", "must list at least one origin when CORS is enabled")
+	}
+	if c.CORS.AllowCredentials {
+		for _, o := range c.CORS.AllowedOrigins {
+			if o == "*" {
+				v.add(key+".cors.allowed_origins", `must not contain "*" when allow_credentials is set`)
+			}
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		v.add(key+".cors.max_age", "must not be negative")
+	}
+	if c.Compression.MinSize < 0 {
+		v.add(key+".compression.min_size", "must not be negative")
+	}
+	if c.Compression.Level < 0 || c.Compression.Level > 11 {
+		v.add(key+".compression.level", "must be between 0 and 11, got %d", c.Compression.Level)
+	}
+	if c.SecurityHeaders.HSTSMaxAge < 0 {
+		v.add(key+".security_headers.hsts_max_age", "must not be negative")
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.problems...))
+}
+
+func nonEmpty(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, s := range values {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
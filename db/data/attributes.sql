@@ -1,9 +1,9 @@
 INSERT INTO attributes (namespace, family, name, label)
 VALUES ('general', 'input', 'first_name', 'Firstname'),
     ('general', 'input', 'last_name', 'Lastname'),
-    ('general', 'input', 'email', 'Firstname'),
+    ('general', 'input', 'email', 'Email'),
     ('general', 'input', 'password', 'Password'),
-    ('general', 'input', 'phone_number', 'PHone'),
+    ('general', 'input', 'phone_number', 'Phone'),
     ('general', 'input', 'date_of_birth', 'Date of birth'),
     ('general', 'input', 'gender', 'Gender'),
     ('general', 'input', 'home_locaiton', 'Home location'),
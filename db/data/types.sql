@@ -15,7 +15,7 @@ VALUES ('default', 'input', 'text', 'text', 'text_field'),
     ('default', 'input', 'year', 'year', 'year_picker'),
     ('default', 'input', 'month', 'month', 'month_picker'), 
     ('default', 'input', 'date', 'date', 'date_picker'),
-    ('default', 'input', 'day', 'day', 'day_pickeer'), 
+    ('default', 'input', 'day', 'day', 'day_picker'), 
     ('default', 'input', 'time', 'time', 'time_picker'),
     ('default', 'input', 'image', 'image', 'image_picker'),
     ('default', 'input', 'audio', 'audio', 'audio_picker'), 
@@ -3,15 +3,65 @@ package db
 
 import (
 	"fmt"
+	"strings"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 	"stellarsky.ai/platform/public-config-service/config"
+	"stellarsky.ai/platform/public-config-service/metrics"
 )
 
 func InitDB(cfg *config.Config) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.DBName, cfg.Database.SSLMode)
-	fmt.Printf("dsn: %s\n", dsn)
-	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	database, err := gorm.Open(postgres.Open(DSN(cfg.Database)), &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := database.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Database.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	}
+	if cfg.Database.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+
+	// Query spans; bind variables are left out so secrets never reach traces.
+	if err := database.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables())); err != nil {
+		return nil, err
+	}
+	if err := database.Use(metrics.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	return database, nil
+}
+
+// DSN builds a libpq keyword/value connection string. It contains the
+// password, so it must never be logged.
+func DSN(c config.DatabaseConfig) string {
+	params := []string{
+		"host=" + quoteDSN(c.Host),
+		fmt.Sprintf("port=%d", c.Port),
+		"user=" + quoteDSN(c.User),
+		"password=" + quoteDSN(c.Password.Value()),
+		"dbname=" + quoteDSN(c.DBName),
+		"sslmode=" + quoteDSN(c.SSLMode),
+	}
+	if c.ConnectTimeout > 0 {
+		params = append(params, fmt.Sprintf("connect_timeout=%d", int(c.ConnectTimeout.Seconds())))
+	}
+	if c.StatementTimeout > 0 {
+		params = append(params, fmt.Sprintf("statement_timeout=%d", c.StatementTimeout.Milliseconds()))
+	}
+	return strings.Join(params, " ")
+}
+
+// quoteDSN quotes a value so spaces, quotes and backslashes survive parsing.
+func quoteDSN(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
 }
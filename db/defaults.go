@@ -0,0 +1,22 @@
+// db/defaults.go
+package db
+
+import (
+	_ "embed"
+	"encoding/json"
+
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+//go:embed data/defaults.json
+var defaultCatalog []byte
+
+// DefaultCatalog returns the built-in types and validations that seed
+// upserts.
+func DefaultCatalog() (*model.Catalog, error) {
+	var c model.Catalog
+	if err := json.Unmarshal(defaultCatalog, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,57 @@
+// db/migrate.go
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// SchemaVersion identifies the schema Migrate produces. Bump it whenever
+// Migrate changes so that readiness checks can tell an instance is running
+// against a database that has not been migrated for it.
+const SchemaVersion = 9
+
+// appendOnlyAudit makes audit_entries reject updates and deletes, whoever
+// issues them.
+const appendOnlyAudit = `
+CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_entries_append_only ON audit_entries;
+CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE ON audit_entries
+	FOR EACH ROW EXECUTE FUNCTION audit_entries_append_only();
+`
+
+// dropLegacyUniqueness removes the unique constraints of setup.sql and the
+// index once shared by every table. Both also covered soft-deleted rows, so a
+// deleted name could never be created again; uniqueness is now enforced by
+// partial indexes over live rows only.
+const dropLegacyUniqueness = `
+ALTER TABLE types DROP CONSTRAINT IF EXISTS types_namespace_family_name_key;
+ALTER TABLE validations DROP CONSTRAINT IF EXISTS validations_namespace_family_name_key;
+ALTER TABLE attributes DROP CONSTRAINT IF EXISTS attributes_namespace_family_name_key;
+ALTER TABLE forms DROP CONSTRAINT IF EXISTS forms_namespace_family_name_key;
+DROP INDEX IF EXISTS idx_namespace_family_name;
+`
+
+// Migrate brings the schema up to date with the models and records
+// SchemaVersion.
+func Migrate(database *gorm.DB) error {
+	err := database.AutoMigrate(&model.Type{}, &model.Validation{}, &model.OptionSet{}, &model.Attribute{}, &model.Form{},
+		&model.APIKey{}, &model.RoleBinding{}, &model.AuditEntry{}, &model.SchemaMigration{})
+	if err != nil {
+		return err
+	}
+	if err := database.Exec(dropLegacyUniqueness).Error; err != nil {
+		return err
+	}
+	if err := database.Exec(appendOnlyAudit).Error; err != nil {
+		return err
+	}
+	return database.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.SchemaMigration{Version: SchemaVersion}).Error
+}
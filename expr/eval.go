@@ -0,0 +1,123 @@
+// expr/eval.go
+package expr
+
+import (
+	"strconv"
+	"strings"
+
+	"stellarsky.ai/platform/public-config-service/rules"
+)
+
+type node interface {
+	eval(values map[string]interface{}) interface{}
+}
+
+type literal struct{ value interface{} }
+
+func (n literal) eval(map[string]interface{}) interface{} { return n.value }
+
+type name struct{ name string }
+
+func (n name) eval(values map[string]interface{}) interface{} { return values[n.name] }
+
+type list []node
+
+func (n list) eval(values map[string]interface{}) interface{} {
+	items := make([]interface{}, len(n))
+	for i, item := range n {
+		items[i] = item.eval(values)
+	}
+	return items
+}
+
+type logical struct {
+	op          string
+	left, right node
+}
+
+func (n logical) eval(values map[string]interface{}) interface{} {
+	if n.op == "&&" {
+		return truthy(n.left.eval(values)) && truthy(n.right.eval(values))
+	}
+	return truthy(n.left.eval(values)) || truthy(n.right.eval(values))
+}
+
+type negation struct{ operand node }
+
+func (n negation) eval(values map[string]interface{}) interface{} {
+	return !truthy(n.operand.eval(values))
+}
+
+type compare struct {
+	op          string
+	left, right node
+}
+
+func (n compare) eval(values map[string]interface{}) interface{} {
+	a, b := n.left.eval(values), n.right.eval(values)
+	switch n.op {
+	case "==":
+		return rules.Equal(a, b)
+	case "!=":
+		return !rules.Equal(a, b)
+	}
+	if rules.Empty(a) || rules.Empty(b) {
+		return false
+	}
+	c := order(a, b)
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// membership holds when item is one of list or, when item is itself a list
+// such as a multiple choice answer, when they share an element.
+type membership struct{ item, list node }
+
+func (n membership) eval(values map[string]interface{}) interface{} {
+	items, _ := n.list.eval(values).([]interface{})
+	v := n.item.eval(values)
+	candidates, ok := v.([]interface{})
+	if !ok {
+		candidates = []interface{}{v}
+	}
+	for _, c := range candidates {
+		for _, item := range items {
+			if rules.Equal(c, item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// truthy is false for false and unanswered values and true otherwise.
+func truthy(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return !rules.Empty(v)
+}
+
+// order compares a and b as numbers when both are, and as text otherwise.
+func order(a, b interface{}) int {
+	x, errA := strconv.ParseFloat(rules.Text(a), 64)
+	y, errB := strconv.ParseFloat(rules.Text(b), 64)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(rules.Text(a), rules.Text(b))
+}
@@ -0,0 +1,315 @@
+// expr/expr.go
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits that keep expressions cheap to evaluate whatever a client writes.
+const (
+	MaxLength = 1000
+	maxDepth  = 32
+)
+
+// Expr is a parsed condition over a form's answers, such as
+//
+//	account_type == 'business' && (employees > 10 || country in ['DE', 'FR'])
+//
+// Names refer to answers by attribute name. Literals are strings in single or
+// double quotes, numbers, lists in brackets, true, false and null. Operators
+// are ||, &&, !, ==, !=, <, <=, >, >=, in and not in; a name on its own holds
+// when the attribute is answered.
+type Expr struct {
+	root  node
+	names []string
+}
+
+// Parse parses src.
+func Parse(src string) (*Expr, error) {
+	if len(src) > MaxLength {
+		return nil, fmt.Errorf("expression is longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, seen: map[string]bool{}}
+	root, err := p.or(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+	return &Expr{root: root, names: p.names}, nil
+}
+
+// Names lists the attribute names e refers to, in order of first use.
+func (e *Expr) Names() []string {
+	return e.names
+}
+
+// Eval reports whether e holds for values, the answers keyed by attribute
+// name.
+func (e *Expr) Eval(values map[string]interface{}) bool {
+	return truthy(e.root.eval(values))
+}
+
+// Rename rewrites the attribute names in src according to to, leaving
+// everything else as written.
+func Rename(src string, to map[string]string) (string, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	last := 0
+	for _, t := range tokens {
+		if t.kind != tokName {
+			continue
+		}
+		if name, ok := to[t.text]; ok {
+			b.WriteString(src[last:t.pos])
+			b.WriteString(name)
+			last = t.pos + len(t.text)
+		}
+	}
+	b.WriteString(src[last:])
+	return b.String(), nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokName
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string // as written, or the unquoted value of a string
+	pos  int
+}
+
+var operators = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(src[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, token{tokString, src[i+1 : i+1+end], i})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokNumber, src[i:j], i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' ||
+				src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{tokName, src[i:j], i})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokOp, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{tokEOF, "end of expression", len(src)}), nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	names  []string
+	seen   map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) accept(kind tokenKind, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(tokOp, text) {
+		t := p.peek()
+		return fmt.Errorf("expected %q but found %q at offset %d", text, t.text, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) or(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("expression is nested more than %d deep", maxDepth)
+	}
+	left, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOp, "||") {
+		right, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = logical{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and(depth int) (node, error) {
+	left, err := p.not(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokOp, "&&") {
+		right, err := p.not(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = logical{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not(depth int) (node, error) {
+	if p.accept(tokOp, "!") {
+		operand, err := p.not(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return negation{operand}, nil
+	}
+	return p.comparison(depth)
+}
+
+func (p *parser) comparison(depth int) (node, error) {
+	left, err := p.operand(depth)
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tokOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+		p.take()
+		right, err := p.operand(depth)
+		if err != nil {
+			return nil, err
+		}
+		return compare{op: t.text, left: left, right: right}, nil
+	case p.accept(tokName, "in"):
+		right, err := p.operand(depth)
+		if err != nil {
+			return nil, err
+		}
+		return membership{item: left, list: right}, nil
+	case p.accept(tokName, "not"):
+		if !p.accept(tokName, "in") {
+			t := p.peek()
+			return nil, fmt.Errorf("expected \"in\" but found %q at offset %d", t.text, t.pos)
+		}
+		right, err := p.operand(depth)
+		if err != nil {
+			return nil, err
+		}
+		return negation{membership{item: left, list: right}}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) operand(depth int) (node, error) {
+	t := p.take()
+	switch t.kind {
+	case tokString:
+		return literal{t.text}, nil
+	case tokNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return literal{n}, nil
+	case tokName:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		case "in", "not":
+			return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+		}
+		if !p.seen[t.text] {
+			p.seen[t.text] = true
+			p.names = append(p.names, t.text)
+		}
+		return name{t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			inner, err := p.or(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			return inner, p.expect(")")
+		case "[":
+			var items list
+			for !p.accept(tokOp, "]") {
+				if len(items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				item, err := p.operand(depth + 1)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return items, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/exp/slog"
 
+	"stellarsky.ai/platform/public-config-service/auth"
 	"stellarsky.ai/platform/public-config-service/model"
 	"stellarsky.ai/platform/public-config-service/service"
 )
@@ -26,12 +27,18 @@ func NewAttributeHandler(service *service.AttributeService, logger *slog.Logger)
 }
 
 func (h *AttributeHandler) GetAllAttributes(w http.ResponseWriter, r *http.Request) {
-	attributes, err := h.service.GetAllAttributes()
+	includeDeleted, err := queryBool(r, "include_deleted")
 	if err != nil {
-		h.logger.Error("error getting all attributes", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	attributes, err := h.service.GetAllAttributes(r.Context(), includeDeleted)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting all attributes", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
+	attributes = visible(r, attributes, func(a model.Attribute) string { return a.Namespace })
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(attributes)
 }
@@ -39,20 +46,28 @@ func (h *AttributeHandler) GetAllAttributes(w http.ResponseWriter, r *http.Reque
 func (h *AttributeHandler) GetAttribute(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	a, err := h.service.GetAttribute(id)
+	a, err := h.service.GetAttribute(r.Context(), id, includeDeleted)
 	if err != nil {
-		h.logger.Error("error getting attribute", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "error getting attribute", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	if a == nil {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
+	if !authorize(w, r, auth.RoleViewer, a.Namespace) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(a)
 }
@@ -60,13 +75,16 @@ func (h *AttributeHandler) GetAttribute(w http.ResponseWriter, r *http.Request)
 func (h *AttributeHandler) CreateAttribute(w http.ResponseWriter, r *http.Request) {
 	var a model.Attribute
 	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.CreateAttribute(&a); err != nil {
-		h.logger.Error("error creating attribute", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if !authorize(w, r, auth.RoleEditor, attributeNamespaces(&a)...) {
+		return
+	}
+	if err := h.service.CreateAttribute(r.Context(), &a); err != nil {
+		h.logger.ErrorContext(r.Context(), "error creating attribute", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -77,36 +95,132 @@ func (h *AttributeHandler) CreateAttribute(w http.ResponseWriter, r *http.Reques
 func (h *AttributeHandler) UpdateAttribute(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 	var a model.Attribute
 	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	existing, err := h.service.GetAttribute(r.Context(), int64(id), false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting attribute", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace, a.Namespace) {
+		return
+	}
 	a.ID = uint64(id)
-	if err := h.service.UpdateAttribute(&a); err != nil {
-		h.logger.Error("error updating attribute", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if err := h.service.UpdateAttribute(r.Context(), &a); err != nil {
+		h.logger.ErrorContext(r.Context(), "error updating attribute", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// DeleteAttribute refuses to delete an attribute that is on a form unless
+// ?cascade, which also removes it from its forms' pages, groups and
+// conditions, or ?force is given; ?dry_run only reports the forms that would
+// be affected. Those variants respond with the affected forms.
 func (h *AttributeHandler) DeleteAttribute(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.DeleteAttribute(id); err != nil {
-		h.logger.Error("error deleting attribute", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	opts, err := deleteOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	existing, err := h.service.GetAttribute(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting attribute", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	if opts.Mode == service.DeleteCascade {
+		usages, err := h.service.GetAttributeUsages(r.Context(), id)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "error getting attribute usages", slog.Any("error", err))
+			writeError(w, r, err)
+			return
+		}
+		if !authorize(w, r, auth.RoleEditor, usageNamespaces(usages)...) {
+			return
+		}
+	}
+	usages, err := h.service.DeleteAttribute(r.Context(), id, opts)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error deleting attribute", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if opts.Mode == service.DeleteRestrict && !opts.DryRun {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usages)
+}
+
+func (h *AttributeHandler) GetAttributeUsages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetAttribute(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting attribute", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) {
+		return
+	}
+	usages, err := h.service.GetAttributeUsages(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting attribute usages", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visibleUsages(r, usages))
+}
+
+func (h *AttributeHandler) RestoreAttribute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetAttribute(r.Context(), id, true)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting attribute", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	a, err := h.service.RestoreAttribute(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error restoring attribute", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
 }
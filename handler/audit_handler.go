@@ -0,0 +1,74 @@
+// handler/audit_handler.go
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/repository"
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+type AuditHandler struct {
+	service *service.AuditService
+	logger  *slog.Logger
+}
+
+func NewAuditHandler(service *service.AuditService, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// GetAuditEntries lists audit entries, newest first, filtered by the query
+// parameters actor, namespace, resource, since and until (RFC 3339) and limit.
+// Entries in namespaces the caller cannot view are left out before limit
+// applies.
+func (h *AuditHandler) GetAuditEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := repository.AuditFilter{
+		Actor:     q.Get("actor"),
+		Namespace: q.Get("namespace"),
+		Resource:  q.Get("resource"),
+	}
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
+	if f.Namespace != "" && !authorize(w, r, auth.RoleViewer, f.Namespace) {
+		return
+	}
+	if namespaces, all := auth.PrincipalFromContext(r.Context()).Namespaces(auth.RoleViewer); !all {
+		f.Namespaces = namespaces
+	}
+
+	entries, err := h.service.FindAuditEntries(r.Context(), f)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error finding audit entries", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
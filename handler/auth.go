@@ -0,0 +1,96 @@
+// handler/auth.go
+package handler
+
+import (
+	"net/http"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/ratelimit"
+)
+
+// authorize reports whether the request's principal holds at least role in
+// every one of namespaces, and charges each namespace's rate limit budget.
+// When it returns false it has written 401, 403 or 429 and the handler must
+// return.
+func authorize(w http.ResponseWriter, r *http.Request, role auth.Role, namespaces ...string) bool {
+	p := auth.PrincipalFromContext(r.Context())
+	if p == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	for _, namespace := range namespaces {
+		if !p.Can(namespace, role) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return false
+		}
+	}
+	for _, namespace := range namespaces {
+		if d := ratelimit.AllowNamespace(r.Context(), namespace); !d.Allowed {
+			ratelimit.Reject(w, d)
+			return false
+		}
+	}
+	return true
+}
+
+// visible returns the items whose namespace the request's principal may view.
+func visible[T any](r *http.Request, items []T, namespace func(T) string) []T {
+	p := auth.PrincipalFromContext(r.Context())
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		if p.Can(namespace(item), auth.RoleViewer) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// attributeNamespaces lists the namespaces written by creating a, which also
+// creates its nested type and validations.
+func attributeNamespaces(a *model.Attribute) []string {
+	namespaces := []string{a.Namespace}
+	if a.Type.Namespace != "" {
+		namespaces = append(namespaces, a.Type.Namespace)
+	}
+	for _, v := range a.Validations {
+		namespaces = append(namespaces, v.Namespace)
+	}
+	return namespaces
+}
+
+// formNamespaces lists the namespaces written by creating f and its nested
+// attributes.
+func formNamespaces(f *model.Form) []string {
+	namespaces := []string{f.Namespace}
+	for i := range f.Attributes {
+		namespaces = append(namespaces, attributeNamespaces(&f.Attributes[i])...)
+	}
+	return namespaces
+}
+
+// usageNamespaces lists the namespaces of the entities in u.
+func usageNamespaces(u *model.Usages) []string {
+	var namespaces []string
+	for _, ref := range u.Types {
+		namespaces = append(namespaces, ref.Namespace)
+	}
+	for _, ref := range u.Attributes {
+		namespaces = append(namespaces, ref.Namespace)
+	}
+	for _, ref := range u.Forms {
+		namespaces = append(namespaces, ref.Namespace)
+	}
+	return namespaces
+}
+
+// visibleUsages returns u without the entities the request's principal may not
+// view.
+func visibleUsages(r *http.Request, u *model.Usages) *model.Usages {
+	namespace := func(ref model.UsageRef) string { return ref.Namespace }
+	return &model.Usages{
+		Types:      visible(r, u.Types, namespace),
+		Attributes: visible(r, u.Attributes, namespace),
+		Forms:      visible(r, u.Forms, namespace),
+	}
+}
@@ -0,0 +1,153 @@
+// handler/auth_handler.go
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/exp/slog"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+type AuthHandler struct {
+	service *service.AuthService
+	logger  *slog.Logger
+}
+
+func NewAuthHandler(service *service.AuthService, logger *slog.Logger) *AuthHandler {
+	return &AuthHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// createdAPIKey is the response to CreateAPIKey, the only one that carries
+// the key itself.
+type createdAPIKey struct {
+	model.APIKey
+	Key string
+}
+
+func (h *AuthHandler) GetAllAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.RoleAdmin, auth.AllNamespaces) {
+		return
+	}
+	keys, err := h.service.GetAllAPIKeys(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting all api keys", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.RoleAdmin, auth.AllNamespaces) {
+		return
+	}
+	var k model.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	key, err := h.service.CreateAPIKey(r.Context(), &k)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error creating api key", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdAPIKey{APIKey: k, Key: key})
+}
+
+func (h *AuthHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, auth.RoleAdmin, auth.AllNamespaces) {
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteAPIKey(r.Context(), id); err != nil {
+		h.logger.ErrorContext(r.Context(), "error deleting api key", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAllRoleBindings lists the bindings in every namespace the caller
+// administers.
+func (h *AuthHandler) GetAllRoleBindings(w http.ResponseWriter, r *http.Request) {
+	bindings, err := h.service.GetAllRoleBindings(r.Context())
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting all role bindings", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	p := auth.PrincipalFromContext(r.Context())
+	administered := make([]model.RoleBinding, 0, len(bindings))
+	for _, b := range bindings {
+		if p.Can(b.Namespace, auth.RoleAdmin) {
+			administered = append(administered, b)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(administered)
+}
+
+// CreateRoleBinding grants a role in a namespace the caller administers. A
+// binding that already exists for the principal and namespace is replaced.
+func (h *AuthHandler) CreateRoleBinding(w http.ResponseWriter, r *http.Request) {
+	var b model.RoleBinding
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, auth.RoleAdmin, b.Namespace) {
+		return
+	}
+	if err := h.service.SaveRoleBinding(r.Context(), &b); err != nil {
+		h.logger.ErrorContext(r.Context(), "error creating role binding", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+func (h *AuthHandler) DeleteRoleBinding(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetRoleBinding(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting role binding", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleAdmin, existing.Namespace) {
+		return
+	}
+	if err := h.service.DeleteRoleBinding(r.Context(), id); err != nil {
+		h.logger.ErrorContext(r.Context(), "error deleting role binding", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
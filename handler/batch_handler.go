@@ -0,0 +1,171 @@
+// handler/batch_handler.go
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/exp/slog"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+// BatchHandler serves the /{resource}:batch endpoints, which apply a list of
+// creates, updates and deletes in one transaction.
+type BatchHandler struct {
+	types         *service.TypeService
+	validations   *service.ValidationService
+	attributes    *service.AttributeService
+	forms         *service.FormService
+	maxOperations int
+	logger        *slog.Logger
+}
+
+// NewBatchHandler returns a BatchHandler that rejects batches of more than
+// maxOperations operations.
+func NewBatchHandler(types *service.TypeService, validations *service.ValidationService, attributes *service.AttributeService,
+	forms *service.FormService, maxOperations int, logger *slog.Logger) *BatchHandler {
+	return &BatchHandler{
+		types:         types,
+		validations:   validations,
+		attributes:    attributes,
+		forms:         forms,
+		maxOperations: maxOperations,
+		logger:        logger,
+	}
+}
+
+func (h *BatchHandler) BatchTypes(w http.ResponseWriter, r *http.Request) {
+	serveBatch(h, w, r, "type", func(ctx context.Context, op model.BatchOperation[model.Type]) ([]string, error) {
+		if op.Op == model.BatchCreate {
+			return []string{op.Item.Namespace}, nil
+		}
+		existing, err := h.types.GetType(ctx, int64(op.ID), false)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == model.BatchUpdate {
+			return []string{existing.Namespace, op.Item.Namespace}, nil
+		}
+		return []string{existing.Namespace}, nil
+	}, h.types.BatchTypes)
+}
+
+func (h *BatchHandler) BatchValidations(w http.ResponseWriter, r *http.Request) {
+	serveBatch(h, w, r, "validation", func(ctx context.Context, op model.BatchOperation[model.Validation]) ([]string, error) {
+		if op.Op == model.BatchCreate {
+			return []string{op.Item.Namespace}, nil
+		}
+		existing, err := h.validations.GetValidation(ctx, int64(op.ID), false)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == model.BatchUpdate {
+			return []string{existing.Namespace, op.Item.Namespace}, nil
+		}
+		return []string{existing.Namespace}, nil
+	}, h.validations.BatchValidations)
+}
+
+func (h *BatchHandler) BatchAttributes(w http.ResponseWriter, r *http.Request) {
+	serveBatch(h, w, r, "attribute", func(ctx context.Context, op model.BatchOperation[model.Attribute]) ([]string, error) {
+		if op.Op == model.BatchCreate {
+			return attributeNamespaces(op.Item), nil
+		}
+		existing, err := h.attributes.GetAttribute(ctx, int64(op.ID), false)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == model.BatchUpdate {
+			return []string{existing.Namespace, op.Item.Namespace}, nil
+		}
+		return []string{existing.Namespace}, nil
+	}, h.attributes.BatchAttributes)
+}
+
+func (h *BatchHandler) BatchForms(w http.ResponseWriter, r *http.Request) {
+	serveBatch(h, w, r, "form", func(ctx context.Context, op model.BatchOperation[model.Form]) ([]string, error) {
+		if op.Op == model.BatchCreate {
+			return formNamespaces(op.Item), nil
+		}
+		existing, err := h.forms.GetForm(ctx, int64(op.ID), false)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == model.BatchUpdate {
+			return []string{existing.Namespace, op.Item.Namespace}, nil
+		}
+		return []string{existing.Namespace}, nil
+	}, h.forms.BatchForms)
+}
+
+// serveBatch decodes a batch, checks the principal may edit every namespace
+// it touches and applies it. namespaces lists the namespaces one well-formed
+// operation touches.
+func serveBatch[T any](h *BatchHandler, w http.ResponseWriter, r *http.Request, resource string,
+	namespaces func(context.Context, model.BatchOperation[T]) ([]string, error),
+	apply func(context.Context, []model.BatchOperation[T]) ([]model.BatchResult, error)) {
+	var req model.BatchRequest[T]
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	ops := req.Operations
+	if len(ops) > h.maxOperations {
+		http.Error(w, fmt.Sprintf("a batch may have at most %d operations, got %d", h.maxOperations, len(ops)),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := service.CheckBatch(ops); err != nil {
+		writeBatchError(w, r, err)
+		return
+	}
+
+	var touched []string
+	for i, op := range ops {
+		ns, err := namespaces(r.Context(), op)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "error getting "+resource, slog.Any("error", err))
+			writeBatchError(w, r, service.NewBatchError(ops, i, err))
+			return
+		}
+		touched = append(touched, ns...)
+	}
+	if !authorize(w, r, auth.RoleEditor, touched...) {
+		return
+	}
+
+	results, err := apply(r.Context(), ops)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error applying "+resource+" batch", slog.Any("error", err))
+		writeBatchError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.BatchResponse{Results: results})
+}
+
+// writeBatchError reports an aborted batch with the outcome of each
+// operation, or falls back to writeError for other errors.
+func writeBatchError(w http.ResponseWriter, r *http.Request, err error) {
+	var batchErr *service.BatchError
+	if !errors.As(err, &batchErr) {
+		writeError(w, r, err)
+		return
+	}
+	status, message := errorStatus(r, batchErr.Err)
+	results := batchErr.Results
+	results[batchErr.Index].Error = message
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(model.BatchResponse{
+		Error:   fmt.Sprintf("operation %d: %s", batchErr.Index, message),
+		Results: results,
+	})
+}
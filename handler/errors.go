@@ -0,0 +1,57 @@
+// handler/errors.go
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+// statusClientClosedRequest is the non-standard status nginx uses when the
+// client goes away before a response could be written.
+const statusClientClosedRequest = 499
+
+// writeError maps an error returned by a service onto an HTTP response.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var inUse *service.InUseError
+	if r.Context().Err() == nil && errors.As(err, &inUse) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(struct {
+			Error  string
+			Usages *model.Usages
+		}{inUse.Error(), inUse.Usages})
+		return
+	}
+	status, message := errorStatus(r, err)
+	http.Error(w, message, status)
+}
+
+// errorStatus returns the status and message reporting err. A request whose
+// context ended is reported as such, whatever the driver made of the aborted
+// query.
+func errorStatus(r *http.Request, err error) (int, string) {
+	if ctxErr := r.Context().Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "Gateway Timeout"
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, "Client Closed Request"
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound, "Not Found"
+	case errors.Is(err, service.ErrInvalid):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, service.ErrUpstream):
+		return http.StatusBadGateway, err.Error()
+	default:
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/exp/slog"
 
+	"stellarsky.ai/platform/public-config-service/auth"
 	"stellarsky.ai/platform/public-config-service/model"
 	"stellarsky.ai/platform/public-config-service/service"
 )
@@ -26,12 +27,18 @@ func NewFormHandler(service *service.FormService, logger *slog.Logger) *FormHand
 }
 
 func (h *FormHandler) GetAllForms(w http.ResponseWriter, r *http.Request) {
-	forms, err := h.service.GetAllForms()
+	includeDeleted, err := queryBool(r, "include_deleted")
 	if err != nil {
-		h.logger.Error("error getting all forms", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	forms, err := h.service.GetAllForms(r.Context(), includeDeleted)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting all forms", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	forms = visible(r, forms, func(f model.Form) string { return f.Namespace })
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(forms)
 }
@@ -39,20 +46,28 @@ func (h *FormHandler) GetAllForms(w http.ResponseWriter, r *http.Request) {
 func (h *FormHandler) GetForm(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	f, err := h.service.GetForm(id)
+	includeDeleted, err := queryBool(r, "include_deleted")
 	if err != nil {
-		h.logger.Error("error getting form", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	f, err := h.service.GetForm(r.Context(), id, includeDeleted)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	if f == nil {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
+	if !authorize(w, r, auth.RoleViewer, f.Namespace) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(f)
 }
@@ -60,13 +75,16 @@ func (h *FormHandler) GetForm(w http.ResponseWriter, r *http.Request) {
 func (h *FormHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
 	var f model.Form
 	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.CreateForm(&f); err != nil {
-		h.logger.Error("error creating form", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if !authorize(w, r, auth.RoleEditor, formNamespaces(&f)...) {
+		return
+	}
+	if err := h.service.CreateForm(r.Context(), &f); err != nil {
+		h.logger.ErrorContext(r.Context(), "error creating form", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -77,20 +95,29 @@ func (h *FormHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
 func (h *FormHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 	var f model.Form
 	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	existing, err := h.service.GetForm(r.Context(), int64(id), false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace, f.Namespace) {
+		return
+	}
 	f.ID = uint64(id)
-	if err := h.service.UpdateForm(&f); err != nil {
-		h.logger.Error("error updating form", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if err := h.service.UpdateForm(r.Context(), &f); err != nil {
+		h.logger.ErrorContext(r.Context(), "error updating form", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -99,14 +126,228 @@ func (h *FormHandler) UpdateForm(w http.ResponseWriter, r *http.Request) {
 func (h *FormHandler) DeleteForm(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.DeleteForm(id); err != nil {
-		h.logger.Error("error deleting form", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	existing, err := h.service.GetForm(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	if err := h.service.DeleteForm(r.Context(), id); err != nil {
+		h.logger.ErrorContext(r.Context(), "error deleting form", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (h *FormHandler) RestoreForm(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetForm(r.Context(), id, true)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	f, err := h.service.RestoreForm(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error restoring form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f)
+}
+
+// CloneForm copies a form to another namespace, family or name and responds
+// with the mapping from the source's IDs to the clone's: 201 for a new form,
+// or 200 when an existing form was reused unchanged.
+func (h *FormHandler) CloneForm(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	var req model.CloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetForm(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	target := req.Namespace
+	if target == "" {
+		target = existing.Namespace
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) || !authorize(w, r, auth.RoleEditor, target) {
+		return
+	}
+	result, err := h.service.CloneForm(r.Context(), id, &req)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error cloning form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	status := http.StatusCreated
+	if result.Reused {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetResolvedForm responds with the form laid out in pages, each attribute
+// with its type and validations inlined. Texts are translated into the locale
+// given by ?locale= or the Accept-Language header, falling back to more
+// general locales and then to the untranslated text.
+func (h *FormHandler) GetResolvedForm(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	preferred, err := locales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := h.service.ResolveForm(r.Context(), id, preferred)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error resolving form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, f.Namespace) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f)
+}
+
+// GetFormSchema responds with a JSON Schema for submissions to the form,
+// titled in the reader's locale as in GetResolvedForm.
+func (h *FormHandler) GetFormSchema(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	preferred, err := locales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	schema, namespace, err := h.service.FormSchema(r.Context(), id, preferred)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error exporting form schema", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, namespace) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(schema)
+}
+
+// ValidateSubmission checks answers to the form, either one page at a time
+// or along the whole path they take through it. Broken rules are reported in
+// the body, not by the status, with messages in the reader's locale when the
+// validation has one.
+func (h *FormHandler) ValidateSubmission(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	preferred, err := locales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var sub model.Submission
+	if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetForm(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) {
+		return
+	}
+	report, err := h.service.ValidateSubmission(r.Context(), id, &sub, preferred)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error validating submission", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetTranslationReports responds with the texts of the form that are not
+// translated, per locale. Locales are given by repeating ?locale=; without
+// any, every locale the form's texts are translated into is reported on.
+func (h *FormHandler) GetTranslationReports(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	var requested []string
+	for _, locale := range r.URL.Query()["locale"] {
+		canonical, err := parseLocale(locale)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requested = append(requested, canonical)
+	}
+	existing, err := h.service.GetForm(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) {
+		return
+	}
+	reports, err := h.service.TranslationReports(r.Context(), id, requested)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error reporting translations", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
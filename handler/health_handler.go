@@ -0,0 +1,39 @@
+// handler/health_handler.go
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/exp/slog"
+
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+type HealthHandler struct {
+	service *service.HealthService
+	logger  *slog.Logger
+}
+
+func NewHealthHandler(service *service.HealthService, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Healthz is the liveness probe.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.service.Live())
+}
+
+// Readyz is the readiness probe. It answers 503 when any check fails.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	health := h.service.Ready(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	if health.Status != service.StatusReady {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
@@ -0,0 +1,66 @@
+// handler/option_handler.go
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/exp/slog"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+type OptionHandler struct {
+	forms   *service.FormService
+	options *service.OptionService
+	logger  *slog.Logger
+}
+
+func NewOptionHandler(forms *service.FormService, options *service.OptionService, logger *slog.Logger) *OptionHandler {
+	return &OptionHandler{
+		forms:   forms,
+		options: options,
+		logger:  logger,
+	}
+}
+
+// GetOptions responds with the options of one of the form's attributes. The
+// answers a data source depends on are passed as query parameters, such as
+// ?country=DE. Option set labels are translated as in resolved forms.
+func (h *OptionHandler) GetOptions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	preferred, err := locales(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := h.forms.GetForm(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting form", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, f.Namespace) {
+		return
+	}
+	values := map[string]string{}
+	for name := range r.URL.Query() {
+		values[name] = r.URL.Query().Get(name)
+	}
+	options, err := h.options.Options(r.Context(), f, mux.Vars(r)["attr"], values, preferred)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting options", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
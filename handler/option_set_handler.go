@@ -0,0 +1,226 @@
+// handler/option_set_handler.go
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/exp/slog"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+type OptionSetHandler struct {
+	service *service.OptionSetService
+	logger  *slog.Logger
+}
+
+func NewOptionSetHandler(service *service.OptionSetService, logger *slog.Logger) *OptionSetHandler {
+	return &OptionSetHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *OptionSetHandler) GetAllOptionSets(w http.ResponseWriter, r *http.Request) {
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	sets, err := h.service.GetAllOptionSets(r.Context(), includeDeleted)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting all option sets", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	sets = visible(r, sets, func(o model.OptionSet) string { return o.Namespace })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sets)
+}
+
+func (h *OptionSetHandler) GetOptionSet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	o, err := h.service.GetOptionSet(r.Context(), id, includeDeleted)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if o == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, o.Namespace) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(o)
+}
+
+func (h *OptionSetHandler) CreateOptionSet(w http.ResponseWriter, r *http.Request) {
+	var o model.OptionSet
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, o.Namespace) {
+		return
+	}
+	if err := h.service.CreateOptionSet(r.Context(), &o); err != nil {
+		h.logger.ErrorContext(r.Context(), "error creating option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(o)
+}
+
+func (h *OptionSetHandler) UpdateOptionSet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	var o model.OptionSet
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetOptionSet(r.Context(), int64(id), false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace, o.Namespace) {
+		return
+	}
+	o.ID = uint64(id)
+	if err := h.service.UpdateOptionSet(r.Context(), &o); err != nil {
+		h.logger.ErrorContext(r.Context(), "error updating option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteOptionSet refuses to delete an option set that attributes offer
+// unless ?cascade, which unlinks it from them, or ?force is given; ?dry_run
+// only reports what would be affected. Those variants respond with the
+// affected attributes and forms.
+func (h *OptionSetHandler) DeleteOptionSet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	opts, err := deleteOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetOptionSet(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	if opts.Mode == service.DeleteCascade {
+		usages, err := h.service.GetOptionSetUsages(r.Context(), id)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "error getting option set usages", slog.Any("error", err))
+			writeError(w, r, err)
+			return
+		}
+		if !authorize(w, r, auth.RoleEditor, usageNamespaces(usages)...) {
+			return
+		}
+	}
+	usages, err := h.service.DeleteOptionSet(r.Context(), id, opts)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error deleting option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if opts.Mode == service.DeleteRestrict && !opts.DryRun {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usages)
+}
+
+func (h *OptionSetHandler) GetOptionSetUsages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetOptionSet(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) {
+		return
+	}
+	usages, err := h.service.GetOptionSetUsages(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting option set usages", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visibleUsages(r, usages))
+}
+
+func (h *OptionSetHandler) RestoreOptionSet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetOptionSet(r.Context(), id, true)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	o, err := h.service.RestoreOptionSet(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error restoring option set", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(o)
+}
@@ -0,0 +1,87 @@
+// handler/params.go
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/text/language"
+
+	"stellarsky.ai/platform/public-config-service/service"
+)
+
+// queryBool reads a boolean query parameter. A parameter given without a value,
+// as in "?include_deleted", counts as true.
+func queryBool(r *http.Request, name string) (bool, error) {
+	q := r.URL.Query()
+	if !q.Has(name) {
+		return false, nil
+	}
+	v := q.Get(name)
+	if v == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// deleteOptions reads the cascade, force and dry_run query parameters of a
+// delete. cascade and force are mutually exclusive.
+func deleteOptions(r *http.Request) (service.DeleteOptions, error) {
+	var opts service.DeleteOptions
+	cascade, err := queryBool(r, "cascade")
+	if err != nil {
+		return opts, err
+	}
+	force, err := queryBool(r, "force")
+	if err != nil {
+		return opts, err
+	}
+	if opts.DryRun, err = queryBool(r, "dry_run"); err != nil {
+		return opts, err
+	}
+	switch {
+	case cascade && force:
+		return opts, errors.New("cascade and force are mutually exclusive")
+	case cascade:
+		opts.Mode = service.DeleteCascade
+	case force:
+		opts.Mode = service.DeleteForce
+	}
+	return opts, nil
+}
+
+// locales reads the locales a reader prefers: the one given by ?locale=, or
+// else those of the Accept-Language header by preference. An Accept-Language
+// header that cannot be parsed is ignored.
+func locales(r *http.Request) ([]string, error) {
+	if locale := r.URL.Query().Get("locale"); locale != "" {
+		canonical, err := parseLocale(locale)
+		if err != nil {
+			return nil, err
+		}
+		return []string{canonical}, nil
+	}
+	tags, q, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil {
+		return nil, nil
+	}
+	var preferred []string
+	for i, tag := range tags {
+		if q[i] > 0 && tag != language.Und {
+			preferred = append(preferred, tag.String())
+		}
+	}
+	return preferred, nil
+}
+
+// parseLocale returns locale as the canonical language tag translations are
+// keyed by, such as "pt-BR" for "pt-br".
+func parseLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a locale", locale)
+	}
+	return tag.String(), nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/exp/slog"
 
+	"stellarsky.ai/platform/public-config-service/auth"
 	"stellarsky.ai/platform/public-config-service/model"
 	"stellarsky.ai/platform/public-config-service/service"
 )
@@ -26,12 +27,18 @@ func NewTypeHandler(service *service.TypeService, logger *slog.Logger) *TypeHand
 }
 
 func (h *TypeHandler) GetAllTypes(w http.ResponseWriter, r *http.Request) {
-	types, err := h.service.GetAllTypes()
+	includeDeleted, err := queryBool(r, "include_deleted")
 	if err != nil {
-		h.logger.Error("error getting all types", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	types, err := h.service.GetAllTypes(r.Context(), includeDeleted)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting all types", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	types = visible(r, types, func(t model.Type) string { return t.Namespace })
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(types)
 }
@@ -39,20 +46,28 @@ func (h *TypeHandler) GetAllTypes(w http.ResponseWriter, r *http.Request) {
 func (h *TypeHandler) GetType(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err), slog.Any("vars", mux.Vars(r)))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err), slog.Any("vars", mux.Vars(r)))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	t, err := h.service.GetType(id)
+	t, err := h.service.GetType(r.Context(), id, includeDeleted)
 	if err != nil {
-		h.logger.Error("error getting type", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "error getting type", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	if t == nil {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
+	if !authorize(w, r, auth.RoleViewer, t.Namespace) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(t)
 }
@@ -60,13 +75,16 @@ func (h *TypeHandler) GetType(w http.ResponseWriter, r *http.Request) {
 func (h *TypeHandler) CreateType(w http.ResponseWriter, r *http.Request) {
 	var t model.Type
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.CreateType(&t); err != nil {
-		h.logger.Error("error creating type", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if !authorize(w, r, auth.RoleEditor, t.Namespace) {
+		return
+	}
+	if err := h.service.CreateType(r.Context(), &t); err != nil {
+		h.logger.ErrorContext(r.Context(), "error creating type", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -77,36 +95,160 @@ func (h *TypeHandler) CreateType(w http.ResponseWriter, r *http.Request) {
 func (h *TypeHandler) UpdateType(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 	var t model.Type
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	existing, err := h.service.GetType(r.Context(), int64(id), false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace, t.Namespace) {
+		return
+	}
 	t.ID = uint64(id)
-	if err := h.service.UpdateType(&t); err != nil {
-		h.logger.Error("error updating type", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if err := h.service.UpdateType(r.Context(), &t); err != nil {
+		h.logger.ErrorContext(r.Context(), "error updating type", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// DeleteType refuses to delete a type that has attributes or that other types
+// extend unless ?cascade, which deletes its attributes and detaches the
+// extending types, or ?force is given; ?dry_run only reports what would be
+// affected. Those variants respond with the affected usages.
 func (h *TypeHandler) DeleteType(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.DeleteType(id); err != nil {
-		h.logger.Error("error deleting type", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	opts, err := deleteOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	existing, err := h.service.GetType(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	if opts.Mode == service.DeleteCascade {
+		usages, err := h.service.GetTypeUsages(r.Context(), id)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "error getting type usages", slog.Any("error", err))
+			writeError(w, r, err)
+			return
+		}
+		if !authorize(w, r, auth.RoleEditor, usageNamespaces(usages)...) {
+			return
+		}
+	}
+	usages, err := h.service.DeleteType(r.Context(), id, opts)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error deleting type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if opts.Mode == service.DeleteRestrict && !opts.DryRun {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usages)
+}
+
+func (h *TypeHandler) GetTypeUsages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetType(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) {
+		return
+	}
+	usages, err := h.service.GetTypeUsages(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting type usages", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visibleUsages(r, usages))
+}
+
+// GetEffectiveType returns the type with what it inherits from the types it
+// extends merged in.
+func (h *TypeHandler) GetEffectiveType(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetType(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) {
+		return
+	}
+	effective, err := h.service.GetEffectiveType(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting effective type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(effective)
+}
+
+func (h *TypeHandler) RestoreType(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetType(r.Context(), id, true)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	t, err := h.service.RestoreType(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error restoring type", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/exp/slog"
 
+	"stellarsky.ai/platform/public-config-service/auth"
 	"stellarsky.ai/platform/public-config-service/model"
 	"stellarsky.ai/platform/public-config-service/service"
 )
@@ -26,12 +27,18 @@ func NewValidationHandler(service *service.ValidationService, logger *slog.Logge
 }
 
 func (h *ValidationHandler) GetAllValidations(w http.ResponseWriter, r *http.Request) {
-	validations, err := h.service.GetAllValidations()
+	includeDeleted, err := queryBool(r, "include_deleted")
 	if err != nil {
-		h.logger.Error("error getting all validations", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	validations, err := h.service.GetAllValidations(r.Context(), includeDeleted)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting all validations", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
+	validations = visible(r, validations, func(v model.Validation) string { return v.Namespace })
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(validations)
 }
@@ -39,20 +46,28 @@ func (h *ValidationHandler) GetAllValidations(w http.ResponseWriter, r *http.Req
 func (h *ValidationHandler) GetValidation(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	includeDeleted, err := queryBool(r, "include_deleted")
+	if err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	v, err := h.service.GetValidation(id)
+	v, err := h.service.GetValidation(r.Context(), id, includeDeleted)
 	if err != nil {
-		h.logger.Error("error getting validation", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		h.logger.ErrorContext(r.Context(), "error getting validation", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	if v == nil {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
+	if !authorize(w, r, auth.RoleViewer, v.Namespace) {
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(v)
 }
@@ -60,13 +75,16 @@ func (h *ValidationHandler) GetValidation(w http.ResponseWriter, r *http.Request
 func (h *ValidationHandler) CreateValidation(w http.ResponseWriter, r *http.Request) {
 	var v model.Validation
 	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.CreateValidation(&v); err != nil {
-		h.logger.Error("error creating validation", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if !authorize(w, r, auth.RoleEditor, v.Namespace) {
+		return
+	}
+	if err := h.service.CreateValidation(r.Context(), &v); err != nil {
+		h.logger.ErrorContext(r.Context(), "error creating validation", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -77,36 +95,132 @@ func (h *ValidationHandler) CreateValidation(w http.ResponseWriter, r *http.Requ
 func (h *ValidationHandler) UpdateValidation(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 	var v model.Validation
 	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-		h.logger.Error("error decoding request body", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error decoding request body", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	existing, err := h.service.GetValidation(r.Context(), int64(id), false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting validation", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace, v.Namespace) {
+		return
+	}
 	v.ID = uint64(id)
-	if err := h.service.UpdateValidation(&v); err != nil {
-		h.logger.Error("error updating validation", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	if err := h.service.UpdateValidation(r.Context(), &v); err != nil {
+		h.logger.ErrorContext(r.Context(), "error updating validation", slog.Any("error", err))
+		writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// DeleteValidation refuses to delete a validation bound to types or
+// attributes unless ?cascade, which unbinds it, or ?force is given; ?dry_run
+// only reports what would be affected. Those variants respond with the
+// affected usages.
 func (h *ValidationHandler) DeleteValidation(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		h.logger.Error("error converting id", slog.Any("error", err))
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	if err := h.service.DeleteValidation(id); err != nil {
-		h.logger.Error("error deleting validation", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	opts, err := deleteOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	existing, err := h.service.GetValidation(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting validation", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	if opts.Mode == service.DeleteCascade {
+		usages, err := h.service.GetValidationUsages(r.Context(), id)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "error getting validation usages", slog.Any("error", err))
+			writeError(w, r, err)
+			return
+		}
+		if !authorize(w, r, auth.RoleEditor, usageNamespaces(usages)...) {
+			return
+		}
+	}
+	usages, err := h.service.DeleteValidation(r.Context(), id, opts)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error deleting validation", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if opts.Mode == service.DeleteRestrict && !opts.DryRun {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usages)
+}
+
+func (h *ValidationHandler) GetValidationUsages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetValidation(r.Context(), id, false)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting validation", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleViewer, existing.Namespace) {
+		return
+	}
+	usages, err := h.service.GetValidationUsages(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting validation usages", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visibleUsages(r, usages))
+}
+
+func (h *ValidationHandler) RestoreValidation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error converting id", slog.Any("error", err))
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	existing, err := h.service.GetValidation(r.Context(), id, true)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error getting validation", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	if !authorize(w, r, auth.RoleEditor, existing.Namespace) {
+		return
+	}
+	v, err := h.service.RestoreValidation(r.Context(), id)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "error restoring validation", slog.Any("error", err))
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
 }
@@ -0,0 +1,363 @@
+// jsonschema/jsonschema.go
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema. Only the keywords design specs need are
+// supported: type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, minimum, maximum, minLength, maxLength and
+// pattern, plus the annotations $schema, $comment, title, description,
+// default and examples. Other keywords are rejected rather than ignored, so
+// that a misspelt one does not silently accept everything.
+type Schema struct {
+	types      []string
+	enum       []interface{}
+	constant   *interface{}
+	properties map[string]*Schema
+	required   []string
+	// additional is nil when any additional property is allowed.
+	additional   *Schema
+	noAdditional bool
+	items        *Schema
+	minItems     *int
+	maxItems     *int
+	minimum      *float64
+	maximum      *float64
+	minLength    *int
+	maxLength    *int
+	pattern      *regexp.Regexp
+}
+
+// Error is a violation of a schema at Path, a JSON Pointer into the document
+// such as "/font/size". The root is "".
+type Error struct {
+	Path    string
+	Message string
+}
+
+func (e Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+var jsonTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+var annotations = map[string]bool{
+	"$schema": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true,
+}
+
+// Compile parses the schema in src.
+func Compile(src []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(src, &doc); err != nil {
+		return nil, fmt.Errorf("schema is not JSON: %v", err)
+	}
+	return compile(doc, "")
+}
+
+func compile(doc interface{}, path string) (*Schema, error) {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema%s must be an object", at(path))
+	}
+	s := &Schema{}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := obj[k]
+		var err error
+		switch k {
+		case "type":
+			err = s.compileType(v)
+		case "enum":
+			list, ok := v.([]interface{})
+			if !ok || len(list) == 0 {
+				err = fmt.Errorf("must be a non-empty list")
+			}
+			s.enum = list
+		case "const":
+			s.constant = &v
+		case "properties":
+			props, ok := v.(map[string]interface{})
+			if !ok {
+				err = fmt.Errorf("must be an object")
+				break
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for name, p := range props {
+				if s.properties[name], err = compile(p, path+"/properties/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			list, ok := v.([]interface{})
+			if !ok {
+				err = fmt.Errorf("must be a list of names")
+				break
+			}
+			for _, name := range list {
+				n, ok := name.(string)
+				if !ok {
+					err = fmt.Errorf("must be a list of names")
+					break
+				}
+				s.required = append(s.required, n)
+			}
+		case "additionalProperties":
+			if b, ok := v.(bool); ok {
+				s.noAdditional = !b
+			} else if s.additional, err = compile(v, path+"/additionalProperties"); err != nil {
+				return nil, err
+			}
+		case "items":
+			if s.items, err = compile(v, path+"/items"); err != nil {
+				return nil, err
+			}
+		case "minItems":
+			s.minItems, err = count(v)
+		case "maxItems":
+			s.maxItems, err = count(v)
+		case "minLength":
+			s.minLength, err = count(v)
+		case "maxLength":
+			s.maxLength, err = count(v)
+		case "minimum":
+			s.minimum, err = bound(v)
+		case "maximum":
+			s.maximum, err = bound(v)
+		case "pattern":
+			p, ok := v.(string)
+			if !ok {
+				err = fmt.Errorf("must be a string")
+				break
+			}
+			s.pattern, err = regexp.Compile(p)
+		default:
+			if !annotations[k] {
+				err = fmt.Errorf("is not a supported keyword")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("schema%s: %q %v", at(path), k, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) compileType(v interface{}) error {
+	switch t := v.(type) {
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, e := range t {
+			name, ok := e.(string)
+			if !ok {
+				return fmt.Errorf("must be a type name or a list of them")
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return fmt.Errorf("must be a type name or a list of them")
+	}
+	for _, name := range s.types {
+		if !jsonTypes[name] {
+			return fmt.Errorf("names unknown type %q", name)
+		}
+	}
+	return nil
+}
+
+func count(v interface{}) (*int, error) {
+	n, ok := v.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("must be a non-negative integer")
+	}
+	i := int(n)
+	return &i, nil
+}
+
+func bound(v interface{}) (*float64, error) {
+	n, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("must be a number")
+	}
+	return &n, nil
+}
+
+func at(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " at " + path
+}
+
+// Validate returns the violations of s by doc, a document decoded by
+// encoding/json into interface{}.
+func (s *Schema) Validate(doc interface{}) []Error {
+	v := validator{}
+	v.validate(s, doc, "")
+	return v.errors
+}
+
+// ValidatePartial is Validate without the required keyword, for documents
+// such as defaults that other documents complete.
+func (s *Schema) ValidatePartial(doc interface{}) []Error {
+	v := validator{partial: true}
+	v.validate(s, doc, "")
+	return v.errors
+}
+
+type validator struct {
+	partial bool
+	errors  []Error
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(s *Schema, doc interface{}, path string) {
+	if len(s.types) > 0 && !hasType(s.types, doc) {
+		v.fail(path, "must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+	if s.enum != nil && !contains(s.enum, doc) {
+		v.fail(path, "must be one of %s", list(s.enum))
+	}
+	if s.constant != nil && !reflect.DeepEqual(*s.constant, doc) {
+		v.fail(path, "must be %s", list([]interface{}{*s.constant}))
+	}
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		v.validateObject(s, d, path)
+	case []interface{}:
+		if s.minItems != nil && len(d) < *s.minItems {
+			v.fail(path, "must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(d) > *s.maxItems {
+			v.fail(path, "must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range d {
+				v.validate(s.items, item, fmt.Sprintf("%s/%d", path, i))
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(d)
+		if s.minLength != nil && n < *s.minLength {
+			v.fail(path, "must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			v.fail(path, "must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(d) {
+			v.fail(path, "must match %q", s.pattern.String())
+		}
+	case float64:
+		if s.minimum != nil && d < *s.minimum {
+			v.fail(path, "must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && d > *s.maximum {
+			v.fail(path, "must be at most %v", *s.maximum)
+		}
+	}
+}
+
+func (v *validator) validateObject(s *Schema, obj map[string]interface{}, path string) {
+	if !v.partial {
+		for _, name := range s.required {
+			if _, ok := obj[name]; !ok {
+				v.fail(path, "needs property %q", name)
+			}
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := path + "/" + escape(name)
+		switch prop, ok := s.properties[name]; {
+		case ok:
+			v.validate(prop, obj[name], p)
+		case s.additional != nil:
+			v.validate(s.additional, obj[name], p)
+		case s.noAdditional:
+			v.fail(p, "is not an allowed property")
+		}
+	}
+}
+
+func hasType(types []string, doc interface{}) bool {
+	for _, t := range types {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || t == "integer" && d == math.Trunc(d) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(values []interface{}, doc interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, doc) {
+			return true
+		}
+	}
+	return false
+}
+
+func list(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		parts[i] = string(b)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// escape encodes name as a JSON Pointer reference token.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
@@ -0,0 +1,26 @@
+// logging/redact.go
+package logging
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// sensitiveKeys are substrings of attribute keys whose values are never
+// logged.
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "api_key", "apikey", "dsn", "cookie", "private_key"}
+
+// RedactAttr masks attributes with sensitive keys. Use it as the ReplaceAttr
+// of the root handler so no log line, whatever its source, leaks a secret.
+// Struct values are masked by their own LogValue or MarshalJSON methods, as
+// config.Secret does.
+func RedactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, "[REDACTED]")
+		}
+	}
+	return a
+}
@@ -6,122 +6,352 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/exp/slog"
 
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/buildinfo"
 	"stellarsky.ai/platform/public-config-service/config"
 	"stellarsky.ai/platform/public-config-service/db"
 	"stellarsky.ai/platform/public-config-service/handler"
+	"stellarsky.ai/platform/public-config-service/metrics"
 	"stellarsky.ai/platform/public-config-service/middleware"
-	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/ratelimit"
 	"stellarsky.ai/platform/public-config-service/repository"
 	"stellarsky.ai/platform/public-config-service/service"
+	"stellarsky.ai/platform/public-config-service/tlsutil"
+	"stellarsky.ai/platform/public-config-service/tracing"
 )
 
 func setupRoutesWithMux(api *mux.Router, typeHandler *handler.TypeHandler, validationHandler *handler.ValidationHandler,
-	attributeHandler *handler.AttributeHandler, formHandler *handler.FormHandler) {
+	optionSetHandler *handler.OptionSetHandler, attributeHandler *handler.AttributeHandler, formHandler *handler.FormHandler,
+	optionHandler *handler.OptionHandler, authHandler *handler.AuthHandler, auditHandler *handler.AuditHandler,
+	batchHandler *handler.BatchHandler) {
 	api.HandleFunc("/types", typeHandler.GetAllTypes).Methods("GET")
 	api.HandleFunc("/types", typeHandler.CreateType).Methods("POST")
+	api.HandleFunc("/types:batch", batchHandler.BatchTypes).Methods("POST")
 	api.HandleFunc("/types/{id}", typeHandler.GetType).Methods("GET")
 	api.HandleFunc("/types/{id}", typeHandler.UpdateType).Methods("PUT")
 	api.HandleFunc("/types/{id}", typeHandler.DeleteType).Methods("DELETE")
+	api.HandleFunc("/types/{id}/restore", typeHandler.RestoreType).Methods("POST")
+	api.HandleFunc("/types/{id}/usages", typeHandler.GetTypeUsages).Methods("GET")
+	api.HandleFunc("/types/{id}/effective", typeHandler.GetEffectiveType).Methods("GET")
 
 	api.HandleFunc("/validations", validationHandler.GetAllValidations).Methods("GET")
 	api.HandleFunc("/validations", validationHandler.CreateValidation).Methods("POST")
+	api.HandleFunc("/validations:batch", batchHandler.BatchValidations).Methods("POST")
 	api.HandleFunc("/validations/{id}", validationHandler.GetValidation).Methods("GET")
 	api.HandleFunc("/validations/{id}", validationHandler.UpdateValidation).Methods("PUT")
 	api.HandleFunc("/validations/{id}", validationHandler.DeleteValidation).Methods("DELETE")
+	api.HandleFunc("/validations/{id}/restore", validationHandler.RestoreValidation).Methods("POST")
+	api.HandleFunc("/validations/{id}/usages", validationHandler.GetValidationUsages).Methods("GET")
+
+	api.HandleFunc("/optionsets", optionSetHandler.GetAllOptionSets).Methods("GET")
+	api.HandleFunc("/optionsets", optionSetHandler.CreateOptionSet).Methods("POST")
+	api.HandleFunc("/optionsets/{id}", optionSetHandler.GetOptionSet).Methods("GET")
+	api.HandleFunc("/optionsets/{id}", optionSetHandler.UpdateOptionSet).Methods("PUT")
+	api.HandleFunc("/optionsets/{id}", optionSetHandler.DeleteOptionSet).Methods("DELETE")
+	api.HandleFunc("/optionsets/{id}/restore", optionSetHandler.RestoreOptionSet).Methods("POST")
+	api.HandleFunc("/optionsets/{id}/usages", optionSetHandler.GetOptionSetUsages).Methods("GET")
 
 	api.HandleFunc("/attributes", attributeHandler.GetAllAttributes).Methods("GET")
 	api.HandleFunc("/attributes", attributeHandler.CreateAttribute).Methods("POST")
+	api.HandleFunc("/attributes:batch", batchHandler.BatchAttributes).Methods("POST")
 	api.HandleFunc("/attributes/{id}", attributeHandler.GetAttribute).Methods("GET")
 	api.HandleFunc("/attributes/{id}", attributeHandler.UpdateAttribute).Methods("PUT")
 	api.HandleFunc("/attributes/{id}", attributeHandler.DeleteAttribute).Methods("DELETE")
+	api.HandleFunc("/attributes/{id}/restore", attributeHandler.RestoreAttribute).Methods("POST")
+	api.HandleFunc("/attributes/{id}/usages", attributeHandler.GetAttributeUsages).Methods("GET")
 
 	api.HandleFunc("/forms", formHandler.GetAllForms).Methods("GET")
 	api.HandleFunc("/forms", formHandler.CreateForm).Methods("POST")
+	api.HandleFunc("/forms:batch", batchHandler.BatchForms).Methods("POST")
 	api.HandleFunc("/forms/{id}", formHandler.GetForm).Methods("GET")
 	api.HandleFunc("/forms/{id}", formHandler.UpdateForm).Methods("PUT")
 	api.HandleFunc("/forms/{id}", formHandler.DeleteForm).Methods("DELETE")
+	api.HandleFunc("/forms/{id}/restore", formHandler.RestoreForm).Methods("POST")
+	api.HandleFunc("/forms/{id}/clone", formHandler.CloneForm).Methods("POST")
+	api.HandleFunc("/forms/{id}/resolved", formHandler.GetResolvedForm).Methods("GET")
+	api.HandleFunc("/forms/{id}/schema", formHandler.GetFormSchema).Methods("GET")
+	api.HandleFunc("/forms/{id}/validate", formHandler.ValidateSubmission).Methods("POST")
+	api.HandleFunc("/forms/{id}/translations", formHandler.GetTranslationReports).Methods("GET")
+	api.HandleFunc("/forms/{id}/attributes/{attr}/options", optionHandler.GetOptions).Methods("GET")
+
+	api.HandleFunc("/apikeys", authHandler.GetAllAPIKeys).Methods("GET")
+	api.HandleFunc("/apikeys", authHandler.CreateAPIKey).Methods("POST")
+	api.HandleFunc("/apikeys/{id}", authHandler.DeleteAPIKey).Methods("DELETE")
+
+	api.HandleFunc("/rolebindings", authHandler.GetAllRoleBindings).Methods("GET")
+	api.HandleFunc("/rolebindings", authHandler.CreateRoleBinding).Methods("POST")
+	api.HandleFunc("/rolebindings/{id}", authHandler.DeleteRoleBinding).Methods("DELETE")
+
+	api.HandleFunc("/audit", auditHandler.GetAuditEntries).Methods("GET")
 }
 
-func main() {
-	// Initialize Logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+// withHTTPPolicy wraps h in CORS, compression and security headers, using
+// the settings of the route group each request path falls in.
+func withHTTPPolicy(c config.HTTPConfig, h http.Handler) http.Handler {
+	policy := func(group string) func(http.Handler) http.Handler {
+		g := c.ForGroup(group)
+		return func(next http.Handler) http.Handler {
+			next = middleware.Compress(middleware.CompressionOptions(g.Compression))(next)
+			next = middleware.CORS(middleware.CORSOptions(g.CORS))(next)
+			return middleware.SecurityHeaders(middleware.SecurityHeadersOptions(g.SecurityHeaders))(next)
+		}
+	}
+	ops := policy("ops")
+	return middleware.ByPathPrefix(policy(""), map[string]func(http.Handler) http.Handler{
+		"/api/v1":  policy("api"),
+		"/metrics": ops,
+		"/healthz": ops,
+		"/readyz":  ops,
+	})(h)
+}
 
-	// Load configuration
-	cfg := config.LoadConfig(logger)
+// applyRateLimits sets the IP, client and namespace budgets and the client keys
+// from rl.
+func applyRateLimits(rl config.RateLimitConfig, ips, clients, namespaces *ratelimit.Limiter, keying *middleware.RateLimitKeying) {
+	keying.Set(middleware.RateLimitKeys{KeyBy: rl.Key, TrustedProxies: rl.TrustedProxies})
+	if !rl.Enabled {
+		rl = config.RateLimitConfig{}
+	}
+	ips.SetBudgets(ratelimit.Budget(rl.IP.Read), ratelimit.Budget(rl.IP.Write))
+	clients.SetBudgets(ratelimit.Budget(rl.Read), ratelimit.Budget(rl.Write))
+	namespaces.SetBudgets(ratelimit.Budget(rl.Namespace.Read), ratelimit.Budget(rl.Namespace.Write))
+}
 
-	// Initialize Database
-	database, err := db.InitDB(cfg)
+// dataSourceLimits converts the data source settings for OptionService.
+func dataSourceLimits(c config.DataSourcesConfig) service.DataSourceLimits {
+	return service.DataSourceLimits{
+		Timeout:          c.Timeout,
+		CacheTTL:         c.CacheTTL,
+		MaxCacheEntries:  c.MaxCacheEntries,
+		MaxResponseBytes: c.MaxResponseBytes,
+		AllowedHosts:     c.AllowedHosts,
+	}
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM.
+func serve(args []string) int {
+	fs, common := newFlagSet("serve", "Run the HTTP server.")
+	migrateFirst := fs.Bool("migrate", true, "migrate the schema before serving")
+	if code, done := parseFlags(fs, args); done {
+		return code
+	}
+	a, code := loadApp(common, os.Stdout)
+	if code != exitOK {
+		return code
+	}
+	cfg, logger, logLevel, loader := a.cfg, a.logger, a.logLevel, a.loader
+
+	// Initialize Tracing
+	tracerProvider, err := tracing.Setup(context.Background(), cfg.Tracing, logger)
 	if err != nil {
-		logger.Error("could not initialize database", slog.Any("error", err))
-		return
+		logger.Error("could not initialize tracing", slog.Any("error", err))
+		return exitFailure
 	}
 
-	// Automigrate models
-	database.AutoMigrate(&model.Type{}, &model.Validation{}, &model.Attribute{}, &model.Form{})
+	// Initialize Database
+	database, code := a.openDB(*migrateFirst)
+	if code != exitOK {
+		return code
+	}
 
 	// Initialize Repositories
 	typeRepo := repository.NewTypeRepository(database, logger)
 	validationRepo := repository.NewValidationRepository(database, logger)
+	optionSetRepo := repository.NewOptionSetRepository(database, logger)
 	attributeRepo := repository.NewAttributeRepository(database, logger)
 	formRepo := repository.NewFormRepository(database, logger)
+	authRepo := repository.NewAuthRepository(database, logger)
+	auditRepo := repository.NewAuditRepository(database, logger)
+	healthRepo := repository.NewHealthRepository(database, logger)
+	statsRepo := repository.NewStatsRepository(database, logger)
+
+	// Database and catalog metrics
+	sqlDB, err := database.DB()
+	if err != nil {
+		logger.Error("could not get database handle", slog.Any("error", err))
+		return exitFailure
+	}
+	prometheus.MustRegister(
+		collectors.NewDBStatsCollector(sqlDB, "public_config"),
+		metrics.NewEntityCollector(statsRepo, logger),
+	)
+
+	jwtVerifier, err := auth.NewJWTVerifier(cfg.Auth.JWT.HMACSecret.Value(), cfg.Auth.JWT.PublicKeyFiles,
+		cfg.Auth.JWT.Issuer, cfg.Auth.JWT.Audience)
+	if err != nil {
+		logger.Error("could not initialize jwt verifier", slog.Any("error", err))
+		return exitFailure
+	}
+
+	identities := make([]auth.CertificateIdentity, 0, len(cfg.Server.TLS.ClientIdentities))
+	for _, id := range cfg.Server.TLS.ClientIdentities {
+		identities = append(identities, auth.CertificateIdentity{Subject: id.Subject, Identity: id.Identity})
+	}
+	certMapper := auth.NewCertificateMapper(identities)
 
 	// Initialize Services
 	typeService := service.NewTypeService(typeRepo, logger)
 	validationService := service.NewValidationService(validationRepo, logger)
+	optionSetService := service.NewOptionSetService(optionSetRepo, logger)
 	attributeService := service.NewAttributeService(attributeRepo, logger)
 	formService := service.NewFormService(formRepo, logger)
+	optionService := service.NewOptionService(&http.Client{}, dataSourceLimits(cfg.DataSources), logger)
+	authService := service.NewAuthService(authRepo, jwtVerifier, certMapper, logger)
+	auditService := service.NewAuditService(auditRepo, logger)
+	healthService := service.NewHealthService(healthRepo, db.SchemaVersion, logger)
+	purgeService := service.NewPurgeService(typeRepo, validationRepo, optionSetRepo, attributeRepo, formRepo,
+		cfg.Purge.Retention, logger)
 
 	// Initialize Handlers
 	typeHandler := handler.NewTypeHandler(typeService, logger)
 	validationHandler := handler.NewValidationHandler(validationService, logger)
+	optionSetHandler := handler.NewOptionSetHandler(optionSetService, logger)
 	attributeHandler := handler.NewAttributeHandler(attributeService, logger)
 	formHandler := handler.NewFormHandler(formService, logger)
+	optionHandler := handler.NewOptionHandler(formService, optionService, logger)
+	authHandler := handler.NewAuthHandler(authService, logger)
+	auditHandler := handler.NewAuditHandler(auditService, logger)
+	healthHandler := handler.NewHealthHandler(healthService, logger)
+	batchHandler := handler.NewBatchHandler(typeService, validationService, attributeService, formService,
+		cfg.Server.MaxBatchOperations, logger)
 
 	// Initialize Router
 	r := mux.NewRouter()
 
 	// Middleware
+	inFlight := &middleware.InFlight{}
+	r.Use(inFlight.Middleware)
+	r.Use(middleware.RequestIDMiddleware)
+	r.Use(middleware.TracingMiddleware)
 	r.Use(middleware.LoggingMiddleware(logger))
 	r.Use(middleware.MetricsMiddleware)
-	r.Use(middleware.TracingMiddleware)
+	r.Use(middleware.TimeoutMiddleware(cfg.Server.RequestTimeout, cfg.Server.MaxRequestTimeout))
 
 	// Prometheus metrics endpoint
 	r.Handle("/metrics", promhttp.Handler())
 
+	// Probes
+	r.HandleFunc("/healthz", healthHandler.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", healthHandler.Readyz).Methods("GET")
+
 	// Routes
 	api := r.PathPrefix("/api/v1").Subrouter()
-	setupRoutesWithMux(api, typeHandler, validationHandler, attributeHandler, formHandler)
+	// Rate limits are always installed so a reload can turn them on; with
+	// rate_limit.enabled false every budget is zero, which allows everything.
+	// The IP budget applies before authentication, the client and namespace
+	// budgets after it.
+	ips := ratelimit.NewLimiter(ratelimit.Budget{}, ratelimit.Budget{})
+	clients := ratelimit.NewLimiter(ratelimit.Budget{}, ratelimit.Budget{})
+	namespaces := ratelimit.NewLimiter(ratelimit.Budget{}, ratelimit.Budget{})
+	keying := middleware.NewRateLimitKeying(middleware.RateLimitKeys{})
+	applyRateLimits(cfg.RateLimit, ips, clients, namespaces, keying)
+	api.Use(middleware.IPRateLimitMiddleware(ips, keying))
+	if cfg.Auth.Enabled {
+		api.Use(middleware.AuthMiddleware(authService, logger))
+	} else {
+		logger.Warn("authentication is disabled, every request runs as an anonymous admin")
+		api.Use(middleware.AnonymousMiddleware)
+	}
+	api.Use(middleware.RateLimitMiddleware(clients, namespaces, keying))
+	setupRoutesWithMux(api, typeHandler, validationHandler, optionSetHandler, attributeHandler, formHandler, optionHandler,
+		authHandler, auditHandler, batchHandler)
+
+	// CORS, compression and security headers wrap the router and are rebuilt
+	// on reload.
+	root := &middleware.Swappable{}
+	root.Store(withHTTPPolicy(cfg.HTTP, r))
+
+	loader.OnReload(func(cfg *config.Config) {
+		logLevel.UnmarshalText([]byte(cfg.Log.Level))
+		applyRateLimits(cfg.RateLimit, ips, clients, namespaces, keying)
+		root.Store(withHTTPPolicy(cfg.HTTP, r))
+		optionService.SetLimits(dataSourceLimits(cfg.DataSources))
+	})
+	loader.Watch()
 
 	// Initialize server
 	srv := &http.Server{
-		Handler:      r,
+		Handler:      root,
 		Addr:         ":" + cfg.Server.Port,
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 	}
 
+	// Background jobs
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if cfg.Purge.Interval > 0 {
+		go purgeService.Run(jobs, cfg.Purge.Interval)
+	}
+
+	if tlsCfg := cfg.Server.TLS; tlsCfg.Enabled {
+		clientAuth, err := tlsutil.ParseClientAuth(tlsCfg.ClientAuth)
+		if err != nil {
+			logger.Error("invalid TLS client auth mode", slog.Any("error", err))
+			return exitFailure
+		}
+		reloader, err := tlsutil.NewReloader(tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.ClientCAFile, clientAuth, logger)
+		if err != nil {
+			logger.Error("could not load TLS certificates", slog.Any("error", err))
+			return exitFailure
+		}
+		srv.TLSConfig = reloader.TLSConfig()
+		if tlsCfg.ReloadInterval > 0 {
+			go reloader.Watch(jobs, tlsCfg.ReloadInterval)
+		}
+	}
+
 	// Graceful Shutdown
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			// Certificates come from TLSConfig, so no files are passed here.
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("listen error", slog.Any("error", err))
 		}
 	}()
-	logger.Info("Server started")
+	version, commit := buildinfo.Info()
+	logger.Info("Server started", slog.String("version", version), slog.String("commit", commit))
 
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
-	<-stop
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	sig := <-stop
+	logger.Info("Server shutting down", slog.String("signal", sig.String()))
+
+	// Fail readiness first and keep serving while load balancers notice, then
+	// close the listener and let in-flight requests finish.
+	healthService.SetDraining()
+	time.Sleep(cfg.Server.ShutdownDrainDelay)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Error("Server Shutdown Failed", slog.Any("error", err))
+		logger.Error("Server Shutdown Failed", slog.Any("error", err), slog.Int64("in_flight", inFlight.Count()))
+	}
+	stopJobs()
+	// Spans of the drained requests are flushed on a budget of their own.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := tracerProvider.Shutdown(flushCtx); err != nil {
+		logger.Error("Tracer Shutdown Failed", slog.Any("error", err))
 	}
 	logger.Info("Server Exited Properly")
+	return exitOK
 }
@@ -2,23 +2,56 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/config"
+	dbpkg "stellarsky.ai/platform/public-config-service/db"
+	"stellarsky.ai/platform/public-config-service/expr"
 	"stellarsky.ai/platform/public-config-service/handler"
+	"stellarsky.ai/platform/public-config-service/jsonschema"
+	"stellarsky.ai/platform/public-config-service/logging"
+	"stellarsky.ai/platform/public-config-service/middleware"
 	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/ratelimit"
 	"stellarsky.ai/platform/public-config-service/repository"
+	"stellarsky.ai/platform/public-config-service/rules"
 	"stellarsky.ai/platform/public-config-service/service"
+	"stellarsky.ai/platform/public-config-service/tlsutil"
+	"stellarsky.ai/platform/public-config-service/tracing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormtracing "gorm.io/plugin/opentelemetry/tracing"
 )
 
 // func convertToGinFunc(f func(http.ResponseWriter, *http.Request)) gin.HandlerFunc {
@@ -29,21 +62,63 @@ import (
 // }
 
 func setupRouter(db *gorm.DB, logger *slog.Logger) *mux.Router {
+	return setupRouterAs(db, logger, auth.Anonymous())
+}
+
+// doJSON sends body to router, encoded as JSON unless it is nil, with the
+// headers in header given as name and value pairs.
+func doJSON(router http.Handler, method, path string, body interface{}, header ...string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// validateForm submits sub to form id for validation and returns the report.
+func validateForm(t *testing.T, router http.Handler, id uint64, sub model.Submission) model.ValidationReport {
+	t.Helper()
+	w := doJSON(router, "POST", fmt.Sprintf("/forms/%d/validate", id), sub)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+	var report model.ValidationReport
+	json.NewDecoder(w.Body).Decode(&report)
+	return report
+}
+
+// setupRouterAs returns a router on which every request runs as p. With a nil
+// p the caller is expected to install its own authentication middleware.
+func setupRouterAs(db *gorm.DB, logger *slog.Logger, p *auth.Principal) *mux.Router {
 
 	typeRepo := repository.NewTypeRepository(db, logger)
 	validationRepo := repository.NewValidationRepository(db, logger)
+	optionSetRepo := repository.NewOptionSetRepository(db, logger)
 	attributeRepo := repository.NewAttributeRepository(db, logger)
 	formRepo := repository.NewFormRepository(db, logger)
 
 	typeService := service.NewTypeService(typeRepo, logger)
 	validationService := service.NewValidationService(validationRepo, logger)
+	optionSetService := service.NewOptionSetService(optionSetRepo, logger)
 	attributeService := service.NewAttributeService(attributeRepo, logger)
 	formService := service.NewFormService(formRepo, logger)
+	optionService := service.NewOptionService(http.DefaultClient, service.DataSourceLimits{
+		Timeout: 5 * time.Second, CacheTTL: time.Minute, MaxCacheEntries: 100, MaxResponseBytes: 1 << 20}, logger)
 
 	typeHandler := handler.NewTypeHandler(typeService, logger)
 	validationHandler := handler.NewValidationHandler(validationService, logger)
+	optionSetHandler := handler.NewOptionSetHandler(optionSetService, logger)
 	attributeHandler := handler.NewAttributeHandler(attributeService, logger)
 	formHandler := handler.NewFormHandler(formService, logger)
+	optionHandler := handler.NewOptionHandler(formService, optionService, logger)
+	auditHandler := handler.NewAuditHandler(service.NewAuditService(repository.NewAuditRepository(db, logger), logger), logger)
+	batchHandler := handler.NewBatchHandler(typeService, validationService, attributeService, formService, 10, logger)
 
 	// Routes
 	// Type Routes
@@ -51,12 +126,21 @@ func setupRouter(db *gorm.DB, logger *slog.Logger) *mux.Router {
 	// Attribute Routes
 	// Form Routes
 	// r := setupGinRouter(typeHandler, validationHandler, attributeHandler, formHandler)
-	r := setupMuxRouter(typeHandler, validationHandler, attributeHandler, formHandler)
+	r := setupMuxRouter(typeHandler, validationHandler, optionSetHandler, attributeHandler, formHandler, optionHandler,
+		auditHandler, batchHandler)
+	if p != nil {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				next.ServeHTTP(w, req.WithContext(auth.WithPrincipal(req.Context(), p)))
+			})
+		})
+	}
 	return r
 }
 
 func setupMuxRouter(typeHandler *handler.TypeHandler, validationHandler *handler.ValidationHandler,
-	attributeHandler *handler.AttributeHandler, formHandler *handler.FormHandler) *mux.Router {
+	optionSetHandler *handler.OptionSetHandler, attributeHandler *handler.AttributeHandler, formHandler *handler.FormHandler,
+	optionHandler *handler.OptionHandler, auditHandler *handler.AuditHandler, batchHandler *handler.BatchHandler) *mux.Router {
 
 	api := mux.NewRouter()
 	api.HandleFunc("/types", typeHandler.GetAllTypes).Methods("GET")
@@ -64,24 +148,53 @@ func setupMuxRouter(typeHandler *handler.TypeHandler, validationHandler *handler
 	api.HandleFunc("/types/{id}", typeHandler.GetType).Methods("GET")
 	api.HandleFunc("/types/{id}", typeHandler.UpdateType).Methods("PUT")
 	api.HandleFunc("/types/{id}", typeHandler.DeleteType).Methods("DELETE")
+	api.HandleFunc("/types/{id}/restore", typeHandler.RestoreType).Methods("POST")
+	api.HandleFunc("/types/{id}/usages", typeHandler.GetTypeUsages).Methods("GET")
+	api.HandleFunc("/types/{id}/effective", typeHandler.GetEffectiveType).Methods("GET")
 
 	api.HandleFunc("/validations", validationHandler.GetAllValidations).Methods("GET")
 	api.HandleFunc("/validations", validationHandler.CreateValidation).Methods("POST")
 	api.HandleFunc("/validations/{id}", validationHandler.GetValidation).Methods("GET")
 	api.HandleFunc("/validations/{id}", validationHandler.UpdateValidation).Methods("PUT")
 	api.HandleFunc("/validations/{id}", validationHandler.DeleteValidation).Methods("DELETE")
+	api.HandleFunc("/validations/{id}/restore", validationHandler.RestoreValidation).Methods("POST")
+	api.HandleFunc("/validations/{id}/usages", validationHandler.GetValidationUsages).Methods("GET")
+
+	api.HandleFunc("/optionsets", optionSetHandler.GetAllOptionSets).Methods("GET")
+	api.HandleFunc("/optionsets", optionSetHandler.CreateOptionSet).Methods("POST")
+	api.HandleFunc("/optionsets/{id}", optionSetHandler.GetOptionSet).Methods("GET")
+	api.HandleFunc("/optionsets/{id}", optionSetHandler.UpdateOptionSet).Methods("PUT")
+	api.HandleFunc("/optionsets/{id}", optionSetHandler.DeleteOptionSet).Methods("DELETE")
+	api.HandleFunc("/optionsets/{id}/restore", optionSetHandler.RestoreOptionSet).Methods("POST")
+	api.HandleFunc("/optionsets/{id}/usages", optionSetHandler.GetOptionSetUsages).Methods("GET")
 
 	api.HandleFunc("/attributes", attributeHandler.GetAllAttributes).Methods("GET")
 	api.HandleFunc("/attributes", attributeHandler.CreateAttribute).Methods("POST")
 	api.HandleFunc("/attributes/{id}", attributeHandler.GetAttribute).Methods("GET")
 	api.HandleFunc("/attributes/{id}", attributeHandler.UpdateAttribute).Methods("PUT")
 	api.HandleFunc("/attributes/{id}", attributeHandler.DeleteAttribute).Methods("DELETE")
+	api.HandleFunc("/attributes/{id}/restore", attributeHandler.RestoreAttribute).Methods("POST")
+	api.HandleFunc("/attributes/{id}/usages", attributeHandler.GetAttributeUsages).Methods("GET")
 
 	api.HandleFunc("/forms", formHandler.GetAllForms).Methods("GET")
 	api.HandleFunc("/forms", formHandler.CreateForm).Methods("POST")
 	api.HandleFunc("/forms/{id}", formHandler.GetForm).Methods("GET")
 	api.HandleFunc("/forms/{id}", formHandler.UpdateForm).Methods("PUT")
 	api.HandleFunc("/forms/{id}", formHandler.DeleteForm).Methods("DELETE")
+	api.HandleFunc("/forms/{id}/restore", formHandler.RestoreForm).Methods("POST")
+	api.HandleFunc("/forms/{id}/clone", formHandler.CloneForm).Methods("POST")
+	api.HandleFunc("/forms/{id}/resolved", formHandler.GetResolvedForm).Methods("GET")
+	api.HandleFunc("/forms/{id}/schema", formHandler.GetFormSchema).Methods("GET")
+	api.HandleFunc("/forms/{id}/validate", formHandler.ValidateSubmission).Methods("POST")
+	api.HandleFunc("/forms/{id}/translations", formHandler.GetTranslationReports).Methods("GET")
+	api.HandleFunc("/forms/{id}/attributes/{attr}/options", optionHandler.GetOptions).Methods("GET")
+
+	api.HandleFunc("/audit", auditHandler.GetAuditEntries).Methods("GET")
+
+	api.HandleFunc("/types:batch", batchHandler.BatchTypes).Methods("POST")
+	api.HandleFunc("/validations:batch", batchHandler.BatchValidations).Methods("POST")
+	api.HandleFunc("/attributes:batch", batchHandler.BatchAttributes).Methods("POST")
+	api.HandleFunc("/forms:batch", batchHandler.BatchForms).Methods("POST")
 
 	return api
 }
@@ -113,7 +226,7 @@ func setupGinRouter(typeHandler *handler.TypeHandler, validationHandler *handler
 
 func setupTestDB(logger *slog.Logger) *gorm.DB {
 	dsn := "host=localhost user=test_public_config_user password=testpassword dbname=test_public_config_db port=5432 sslmode=disable TimeZone=Asia/Shanghai"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		logger.Error("failed to connect to database")
 		panic(err)
@@ -450,3 +563,2169 @@ func TestFormAPI(t *testing.T) {
 		}
 	})
 }
+
+// activeTypeQueries counts statements against the types table that postgres is
+// still executing, so a test can tell whether a cancelled request's query was
+// really aborted rather than just abandoned by the client.
+func activeTypeQueries(t *testing.T, db *gorm.DB) int64 {
+	var n int64
+	err := db.Raw(`SELECT count(*) FROM pg_stat_activity
+		WHERE state = 'active' AND query LIKE 'SELECT * FROM "types"%'`).Scan(&n).Error
+	if err != nil {
+		t.Fatalf("error querying pg_stat_activity: %v", err)
+	}
+	return n
+}
+
+func waitForNoTypeQueries(t *testing.T, db *gorm.DB) {
+	deadline := time.Now().Add(2 * time.Second)
+	for activeTypeQueries(t, db) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("query against types still running after its request ended")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRequestCancellation(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+
+	// Hold an exclusive lock on types so that every query against it blocks
+	// until the request's context gives up.
+	lock := db.Begin()
+	defer lock.Rollback()
+	if err := lock.Exec("LOCK TABLE types IN ACCESS EXCLUSIVE MODE").Error; err != nil {
+		t.Fatalf("error locking types: %v", err)
+	}
+
+	t.Run("ClientDisconnect", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		req, _ := http.NewRequestWithContext(ctx, "GET", "/types", nil)
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		router.ServeHTTP(w, req)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("request took %v after being cancelled", elapsed)
+		}
+		if w.Code != 499 {
+			t.Fatalf("expected status code %d but got %d", 499, w.Code)
+		}
+		waitForNoTypeQueries(t, db)
+	})
+
+	t.Run("RequestTimeoutHeader", func(t *testing.T) {
+		timed := middleware.TimeoutMiddleware(time.Minute, time.Minute)(router)
+		req, _ := http.NewRequest("GET", "/types", nil)
+		req.Header.Set(middleware.RequestTimeoutHeader, "200ms")
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		timed.ServeHTTP(w, req)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("request took %v with a 200ms deadline", elapsed)
+		}
+		if w.Code != http.StatusGatewayTimeout {
+			t.Fatalf("expected status code %d but got %d", http.StatusGatewayTimeout, w.Code)
+		}
+		waitForNoTypeQueries(t, db)
+	})
+}
+
+func TestAuthAPI(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	db.AutoMigrate(&model.APIKey{}, &model.RoleBinding{})
+
+	verifier, err := auth.NewJWTVerifier("test-secret", nil, "", "")
+	if err != nil {
+		t.Fatalf("error creating jwt verifier: %v", err)
+	}
+	authService := service.NewAuthService(repository.NewAuthRepository(db, logger), verifier, nil, logger)
+	router := setupRouterAs(db, logger, nil)
+	router.Use(middleware.AuthMiddleware(authService, logger))
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	postType := func(credentials, namespace string) int {
+		jsonValue, _ := json.Marshal(model.Type{
+			Namespace:   namespace,
+			Family:      "test_auth_family",
+			Name:        "test_auth_" + suffix,
+			ElementType: "test_element",
+			WidgetType:  "test_widget",
+		})
+		req, _ := http.NewRequest("POST", "/types", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		if credentials != "" {
+			req.Header.Set("Authorization", "Bearer "+credentials)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("NoCredentials", func(t *testing.T) {
+		if code := postType("", "test_namespace_a"); code != http.StatusUnauthorized {
+			t.Fatalf("expected status code %d but got %d", http.StatusUnauthorized, code)
+		}
+	})
+
+	t.Run("APIKey", func(t *testing.T) {
+		principal := "test_ci_" + suffix
+		key, err := authService.CreateAPIKey(context.Background(), &model.APIKey{Name: "ci", Principal: principal})
+		if err != nil {
+			t.Fatalf("error creating api key: %v", err)
+		}
+		binding := model.RoleBinding{Principal: principal, Namespace: "test_namespace_a", Role: string(auth.RoleEditor)}
+		if err := authService.SaveRoleBinding(context.Background(), &binding); err != nil {
+			t.Fatalf("error creating role binding: %v", err)
+		}
+
+		if code := postType(key, "test_namespace_a"); code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, code)
+		}
+		if code := postType(key, "test_namespace_b"); code != http.StatusForbidden {
+			t.Fatalf("expected status code %d but got %d", http.StatusForbidden, code)
+		}
+		if code := postType(key+"x", "test_namespace_a"); code != http.StatusUnauthorized {
+			t.Fatalf("expected status code %d but got %d", http.StatusUnauthorized, code)
+		}
+	})
+
+	t.Run("JWT", func(t *testing.T) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "test_viewer_" + suffix,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Roles: map[string]string{"test_namespace_b": string(auth.RoleViewer)},
+		}).SignedString([]byte("test-secret"))
+
+		req, _ := http.NewRequest("GET", "/types", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+		var types []model.Type
+		json.Unmarshal(w.Body.Bytes(), &types)
+		for _, typ := range types {
+			if typ.Namespace != "test_namespace_b" {
+				t.Fatalf("viewer of test_namespace_b was shown a type in %s", typ.Namespace)
+			}
+		}
+
+		if code := postType(token, "test_namespace_b"); code != http.StatusForbidden {
+			t.Fatalf("expected status code %d but got %d", http.StatusForbidden, code)
+		}
+	})
+}
+
+func TestAuditAPI(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	db.AutoMigrate(&model.AuditEntry{})
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	actor := "test_auditor_" + suffix
+	router := middleware.RequestIDMiddleware(setupRouterAs(db, logger, &auth.Principal{
+		Subject: actor,
+		Roles:   map[string]auth.Role{auth.AllNamespaces: auth.RoleAdmin},
+	}))
+	createdType := model.Type{}
+
+	t.Run("CreateAndUpdateType", func(t *testing.T) {
+		newType := model.Type{
+			Namespace:   "test_namespace",
+			Family:      "test_audit_family",
+			Name:        "test_audit_" + suffix,
+			ElementType: "test_element",
+			WidgetType:  "test_widget",
+		}
+		jsonValue, _ := json.Marshal(newType)
+		req, _ := http.NewRequest("POST", "/types", bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, w.Code)
+		}
+		json.NewDecoder(w.Body).Decode(&createdType)
+
+		newType.WidgetType = "test_other_widget"
+		jsonValue, _ = json.Marshal(newType)
+		req, _ = http.NewRequest("PUT", fmt.Sprintf("/types/%d", createdType.ID), bytes.NewBuffer(jsonValue))
+		req.Header.Set("X-Request-ID", "test-request-"+suffix)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d but got %d", http.StatusNoContent, w.Code)
+		}
+	})
+
+	t.Run("GetAuditEntries", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/audit?resource=type&namespace=test_namespace&actor="+actor, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+
+		var entries []model.AuditEntry
+		json.Unmarshal(w.Body.Bytes(), &entries)
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 audit entries but got %d", len(entries))
+		}
+		update, create := entries[0], entries[1]
+		if create.Action != "create" || create.ResourceID != createdType.ID {
+			t.Fatalf("unexpected create entry %+v", create)
+		}
+		if update.Action != "update" || update.RequestID != "test-request-"+suffix {
+			t.Fatalf("unexpected update entry %+v", update)
+		}
+		var changes map[string]map[string]interface{}
+		json.Unmarshal(update.Changes, &changes)
+		if changes["WidgetType"]["before"] != "test_widget" || changes["WidgetType"]["after"] != "test_other_widget" {
+			t.Fatalf("unexpected changes %s", update.Changes)
+		}
+	})
+
+	t.Run("LimitAfterScoping", func(t *testing.T) {
+		hidden := model.Type{Namespace: "test_hidden_" + suffix, Family: "test_audit_family", Name: "test_audit_" + suffix}
+		if w := doJSON(router, "POST", "/types", hidden); w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, w.Code)
+		}
+		scoped := setupRouterAs(db, logger, &auth.Principal{
+			Subject: "test_viewer_" + suffix,
+			Roles:   map[string]auth.Role{"test_namespace": auth.RoleViewer},
+		})
+		var entries []model.AuditEntry
+		json.NewDecoder(doJSON(scoped, "GET", "/audit?limit=1&actor="+actor, nil).Body).Decode(&entries)
+		if len(entries) != 1 || entries[0].Namespace != "test_namespace" {
+			t.Fatalf("expected the newest visible entry but got %+v", entries)
+		}
+	})
+
+	t.Run("NestedCreates", func(t *testing.T) {
+		namespace := "test_nested_" + suffix
+		form := model.Form{Namespace: namespace, Family: "test_audit_family", Name: "test_audit_form", Attributes: []model.Attribute{{
+			Namespace: namespace, Family: "test_audit_family", Name: "test_audit_attribute",
+			Type:        model.Type{Namespace: namespace, Family: "test_audit_family", Name: "test_audit_type"},
+			Validations: []model.Validation{{Namespace: namespace, Family: "test_audit_family", Name: "test_audit_validation", RuleName: "required"}},
+		}}}
+		if w := doJSON(router, "POST", "/forms", form); w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+		}
+
+		var entries []model.AuditEntry
+		json.NewDecoder(doJSON(router, "GET", "/audit?namespace="+namespace, nil).Body).Decode(&entries)
+		created := map[string]int{}
+		for _, e := range entries {
+			if e.Action == "create" {
+				created[e.Resource]++
+			}
+		}
+		for _, resource := range []string{"form", "attribute", "type", "validation"} {
+			if created[resource] != 1 {
+				t.Fatalf("expected 1 %s create entry but got %d in %+v", resource, created[resource], entries)
+			}
+		}
+	})
+}
+
+func TestSoftDeleteLifecycle(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	newType := model.Type{
+		Namespace:   "test_namespace",
+		Family:      "test_lifecycle_family",
+		Name:        "test_lifecycle_" + suffix,
+		ElementType: "test_element",
+		WidgetType:  "test_widget",
+	}
+	var original, replacement model.Type
+
+	t.Run("DeleteAndReuseName", func(t *testing.T) {
+		w := doJSON(router, "POST", "/types", newType)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, w.Code)
+		}
+		json.NewDecoder(w.Body).Decode(&original)
+
+		if w := doJSON(router, "DELETE", fmt.Sprintf("/types/%d", original.ID), nil); w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d but got %d", http.StatusNoContent, w.Code)
+		}
+		if w := doJSON(router, "GET", fmt.Sprintf("/types/%d", original.ID), nil); w.Code != http.StatusNotFound {
+			t.Fatalf("expected status code %d but got %d", http.StatusNotFound, w.Code)
+		}
+		if w := doJSON(router, "GET", fmt.Sprintf("/types/%d?include_deleted", original.ID), nil); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+
+		w = doJSON(router, "POST", "/types", newType)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, w.Code)
+		}
+		json.NewDecoder(w.Body).Decode(&replacement)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		if w := doJSON(router, "POST", fmt.Sprintf("/types/%d/restore", original.ID), nil); w.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d but got %d", http.StatusConflict, w.Code)
+		}
+		if w := doJSON(router, "DELETE", fmt.Sprintf("/types/%d", replacement.ID), nil); w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d but got %d", http.StatusNoContent, w.Code)
+		}
+		w := doJSON(router, "POST", fmt.Sprintf("/types/%d/restore", original.ID), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+		var restored model.Type
+		json.NewDecoder(w.Body).Decode(&restored)
+		if restored.ID != original.ID || restored.DeletedAt.Valid {
+			t.Fatalf("unexpected restored type %+v", restored)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		typeRepo := repository.NewTypeRepository(db, logger)
+		if _, err := typeRepo.Purge(context.Background(), time.Now()); err != nil {
+			t.Fatalf("error purging types: %v", err)
+		}
+		if w := doJSON(router, "GET", fmt.Sprintf("/types/%d?include_deleted", replacement.ID), nil); w.Code != http.StatusNotFound {
+			t.Fatalf("expected status code %d but got %d", http.StatusNotFound, w.Code)
+		}
+		if w := doJSON(router, "GET", fmt.Sprintf("/types/%d", original.ID), nil); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+	})
+}
+
+func TestDeleteProtection(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	createdAttribute := model.Attribute{}
+	t.Run("CreateAttribute", func(t *testing.T) {
+		w := doJSON(router, "POST", "/attributes", buildAttribute("usage_"+suffix))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, w.Code)
+		}
+		json.NewDecoder(w.Body).Decode(&createdAttribute)
+	})
+
+	typeURL := fmt.Sprintf("/types/%d", createdAttribute.TypeID)
+
+	t.Run("GetTypeUsages", func(t *testing.T) {
+		w := doJSON(router, "GET", typeURL+"/usages", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+		var usages model.Usages
+		json.NewDecoder(w.Body).Decode(&usages)
+		if len(usages.Attributes) != 1 || usages.Attributes[0].ID != createdAttribute.ID {
+			t.Fatalf("unexpected usages %+v", usages)
+		}
+	})
+
+	t.Run("RestrictedDelete", func(t *testing.T) {
+		if w := doJSON(router, "DELETE", typeURL, nil); w.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d but got %d", http.StatusConflict, w.Code)
+		}
+		if w := doJSON(router, "DELETE", typeURL+"?cascade&dry_run", nil); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+		if w := doJSON(router, "GET", fmt.Sprintf("/attributes/%d", createdAttribute.ID), nil); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("CascadeDelete", func(t *testing.T) {
+		if w := doJSON(router, "DELETE", typeURL+"?cascade", nil); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+		if w := doJSON(router, "GET", fmt.Sprintf("/attributes/%d", createdAttribute.ID), nil); w.Code != http.StatusNotFound {
+			t.Fatalf("expected status code %d but got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("LinkDeleted", func(t *testing.T) {
+		form := model.Form{Namespace: "test_namespace", Family: "test_usage_family", Name: "test_usage_" + suffix,
+			Attributes: []model.Attribute{{ID: createdAttribute.ID, Namespace: createdAttribute.Namespace}}}
+		if w := doJSON(router, "POST", "/forms", form); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
+
+func TestHealthAPI(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	db.AutoMigrate(&model.SchemaMigration{})
+	healthService := service.NewHealthService(repository.NewHealthRepository(db, logger), 0, logger)
+	healthHandler := handler.NewHealthHandler(healthService, logger)
+
+	t.Run("Healthz", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		healthHandler.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("ReadyzWhileDraining", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		healthHandler.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusOK, w.Code, w.Body)
+		}
+
+		healthService.SetDraining()
+		w = httptest.NewRecorder()
+		healthHandler.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status code %d but got %d", http.StatusServiceUnavailable, w.Code)
+		}
+		var health service.Health
+		json.NewDecoder(w.Body).Decode(&health)
+		if health.Checks["shutdown"].Status != service.StatusFailed || health.Checks["database"].Status != service.StatusOK {
+			t.Fatalf("unexpected checks %+v", health.Checks)
+		}
+	})
+}
+
+func TestTracing(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	provider, err := tracing.Setup(context.Background(), config.TracingConfig{Exporter: tracing.ExporterMemory, SampleRatio: 1}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Shutdown(context.Background())
+	db := setupTestDB(logger)
+	if err := db.Use(gormtracing.NewPlugin(gormtracing.WithoutMetrics())); err != nil {
+		t.Fatal(err)
+	}
+	router := setupRouter(db, logger)
+	router.Use(middleware.TracingMiddleware)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("GET", "/types/999999999", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status code %d but got %d", http.StatusNotFound, w.Code)
+	}
+
+	var server, svc, query bool
+	for _, span := range provider.Memory.GetSpans() {
+		if span.SpanContext.TraceID().String() != traceID {
+			t.Fatalf("span %q has trace %s, want %s", span.Name, span.SpanContext.TraceID(), traceID)
+		}
+		switch {
+		case span.Name == "GET /types/{id}":
+			server = true
+			for _, kv := range span.Attributes {
+				if kv.Key == "http.response.status_code" && kv.Value.AsInt64() != http.StatusNotFound {
+					t.Fatalf("expected recorded status %d but got %d", http.StatusNotFound, kv.Value.AsInt64())
+				}
+			}
+		case span.Name == "TypeService.GetType":
+			svc = true
+		case span.SpanKind == trace.SpanKindClient:
+			query = true
+		}
+	}
+	if !server || !svc || !query {
+		t.Fatalf("missing spans: server=%v service=%v query=%v", server, svc, query)
+	}
+}
+
+func TestMetricsRouteLabels(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(middleware.MetricsMiddleware)
+	r.HandleFunc("/forms/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	for _, id := range []string{"1", "2", "3"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/forms/"+id, nil))
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() != "endpoint" {
+					continue
+				}
+				if label.GetValue() != "/forms/{id}" {
+					t.Fatalf("unexpected endpoint label %q", label.GetValue())
+				}
+				if m.GetCounter().GetValue() != 3 {
+					t.Fatalf("expected 3 requests but got %v", m.GetCounter().GetValue())
+				}
+				return
+			}
+		}
+	}
+	t.Fatal("http_requests_total not reported")
+}
+
+func TestRequestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(&buf, nil)))
+	provider, err := tracing.Setup(context.Background(), config.TracingConfig{Exporter: tracing.ExporterMemory, SampleRatio: 1}, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Shutdown(context.Background())
+
+	r := mux.NewRouter()
+	r.Use(middleware.RequestIDMiddleware, middleware.TracingMiddleware, middleware.LoggingMiddleware(logger), middleware.AnonymousMiddleware)
+	r.HandleFunc("/types/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.ErrorContext(r.Context(), "handler failed")
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}).Methods("GET")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/types/1", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	dec := json.NewDecoder(&buf)
+	var records []map[string]interface{}
+	for dec.More() {
+		var rec map[string]interface{}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 log records but got %d", len(records))
+	}
+	for _, rec := range records {
+		if rec["request_id"] != "req-123" || rec["trace_id"] != traceID {
+			t.Fatalf("record %v lacks the request or trace ID", rec)
+		}
+	}
+	access := records[1]
+	if access["status"] != float64(http.StatusNotFound) || access["bytes"] != float64(len("Not Found\n")) ||
+		access["principal"] != auth.Anonymous().Subject || access["remote_addr"] == "" {
+		t.Fatalf("unexpected access log %v", access)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	clients := ratelimit.NewLimiter(ratelimit.Budget{Rate: 0.01, Burst: 2}, ratelimit.Budget{Rate: 0.01, Burst: 1})
+	keying := middleware.NewRateLimitKeying(middleware.RateLimitKeys{KeyBy: middleware.RateLimitByCredential})
+	r := mux.NewRouter()
+	r.Use(middleware.AnonymousMiddleware, middleware.RateLimitMiddleware(clients, nil, keying))
+	r.HandleFunc("/forms", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET", "POST")
+
+	send := func(method, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/forms", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := send("GET", "10.0.0.1:1234")
+		if w.Code != want {
+			t.Fatalf("request %d: expected status code %d but got %d", i, want, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: unexpected RateLimit-Limit %q", i, w.Header().Get("RateLimit-Limit"))
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatal("expected Retry-After on a rejected request")
+		}
+	}
+	if w := send("POST", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("writes have their own budget, got status code %d", w.Code)
+	}
+	if w := send("GET", "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Fatalf("other clients have their own budget, got status code %d", w.Code)
+	}
+
+	// Keys apply to the requests after they change, as on a reload.
+	keying.Set(middleware.RateLimitKeys{KeyBy: middleware.RateLimitByIP, TrustedProxies: 1})
+	forwarded := func(xff string) int {
+		req := httptest.NewRequest("GET", "/forms", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", xff)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := forwarded("192.0.2.7"); code != http.StatusOK {
+		t.Fatalf("expected the forwarded client to have its own budget, got status code %d", code)
+	}
+	if code := forwarded("192.0.2.7"); code != http.StatusOK {
+		t.Fatalf("expected the forwarded client's second request to be allowed, got status code %d", code)
+	}
+	// The client controls the entries left of those its proxies append, so a
+	// spoofed one must not buy a fresh budget.
+	if code := forwarded("198.51.100.1, 192.0.2.7"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected a spoofed X-Forwarded-For to share the client's budget, got status code %d", code)
+	}
+}
+
+func TestNamespaceRateLimit(t *testing.T) {
+	clients := ratelimit.NewLimiter(ratelimit.Budget{}, ratelimit.Budget{})
+	namespaces := ratelimit.NewLimiter(ratelimit.Budget{Rate: 0.01, Burst: 2}, ratelimit.Budget{Rate: 0.01, Burst: 2})
+	keying := middleware.NewRateLimitKeying(middleware.RateLimitKeys{KeyBy: middleware.RateLimitByCredential})
+	p := &auth.Principal{Subject: "team-a-viewer", Method: "jwt"}
+	p.Grant("team-a", auth.RoleViewer)
+	queries := 0
+	r := mux.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(auth.WithPrincipal(req.Context(), p)))
+		})
+	}, middleware.RateLimitMiddleware(clients, namespaces, keying))
+	// Stands in for a list handler that queries the database.
+	r.HandleFunc("/forms", func(w http.ResponseWriter, r *http.Request) { queries++ }).Methods("GET")
+
+	list := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := list("/forms"); code != want {
+			t.Fatalf("request %d: expected status code %d but got %d", i, want, code)
+		}
+	}
+	if queries != 2 {
+		t.Fatalf("expected the rejected list to skip its query, got %d queries", queries)
+	}
+
+	// A filter on a namespace the principal cannot view does not move the
+	// charge there; one it can view charges only that namespace.
+	if code := list("/forms?namespace=team-b"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected an unviewable namespace filter to charge team-a, got status code %d", code)
+	}
+	p.Grant("team-b", auth.RoleViewer)
+	if code := list("/forms?namespace=team-b"); code != http.StatusOK {
+		t.Fatalf("expected team-b's own budget, got status code %d", code)
+	}
+}
+
+func TestIPRateLimit(t *testing.T) {
+	ips := ratelimit.NewLimiter(ratelimit.Budget{Rate: 0.01, Burst: 2}, ratelimit.Budget{Rate: 0.01, Burst: 2})
+	keying := middleware.NewRateLimitKeying(middleware.RateLimitKeys{KeyBy: middleware.RateLimitByCredential})
+	lookups := 0
+	r := mux.NewRouter()
+	r.Use(middleware.IPRateLimitMiddleware(ips, keying))
+	// Stands in for authentication rejecting a guessed API key.
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lookups++
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		})
+	})
+	r.HandleFunc("/forms", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/forms", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-API-Key", fmt.Sprintf("guess-%d", i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("request %d: expected status code %d but got %d", i, want, w.Code)
+		}
+	}
+	if lookups != 2 {
+		t.Fatalf("expected the rejected request to skip the credential lookup, got %d lookups", lookups)
+	}
+}
+
+func TestHTTPPolicy(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"name":"field"}`), 200)
+	r := mux.NewRouter()
+	r.HandleFunc("/api/v1/forms", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}).Methods("GET")
+	policy := func(cors middleware.CORSOptions, compress bool) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			next = middleware.Compress(middleware.CompressionOptions{Enabled: compress, MinSize: 1024})(next)
+			next = middleware.CORS(cors)(next)
+			return middleware.SecurityHeaders(middleware.SecurityHeadersOptions{Enabled: true, FrameOptions: "DENY"})(next)
+		}
+	}
+	apiCORS := middleware.CORSOptions{Enabled: true, AllowedOrigins: []string{"https://builder.example"},
+		AllowedMethods: []string{"GET", "POST"}, AllowedHeaders: []string{"Authorization"}, MaxAge: 600}
+	h := middleware.ByPathPrefix(policy(middleware.CORSOptions{}, false), map[string]func(http.Handler) http.Handler{
+		"/api/v1": policy(apiCORS, true),
+	})(r)
+
+	t.Run("Preflight", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/api/v1/forms", nil)
+		req.Header.Set("Origin", "https://builder.example")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://builder.example" ||
+			w.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+			t.Fatalf("unexpected preflight response %d %v", w.Code, w.Header())
+		}
+
+		req.Header.Set("Origin", "https://evil.example")
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("unexpected preflight response for a foreign origin %d %v", w.Code, w.Header())
+		}
+	})
+
+	t.Run("Compression", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/forms", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br;q=0.5")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Fatalf("unexpected headers %v", w.Header())
+		}
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(zr)
+		if !bytes.Equal(got, body) {
+			t.Fatal("decompressed body differs")
+		}
+	})
+
+	t.Run("Negotiation", func(t *testing.T) {
+		for _, tc := range []struct {
+			accept string
+			want   string
+		}{
+			{"gzip, br", "br"},
+			{"gzip;q=1.0, br;q=0.8", "gzip"},
+			{"br;q=0", ""},
+			{"gzip;q=0, br;q=0", ""},
+			{"gzip, br;q=0", "gzip"},
+			{"br;q=0.0, gzip;q=0.1", "gzip"},
+			{"identity", ""},
+		} {
+			req := httptest.NewRequest("GET", "/api/v1/forms", nil)
+			req.Header.Set("Accept-Encoding", tc.accept)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if got := w.Header().Get("Content-Encoding"); got != tc.want {
+				t.Errorf("Accept-Encoding %q: expected Content-Encoding %q but got %q", tc.accept, tc.want, got)
+			}
+		}
+	})
+}
+
+type certAuthenticator struct {
+	mapper *auth.CertificateMapper
+}
+
+func (a certAuthenticator) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	return nil, auth.ErrInvalidCredentials
+}
+
+func (a certAuthenticator) AuthenticateToken(ctx context.Context, token string) (*auth.Principal, error) {
+	return nil, auth.ErrInvalidCredentials
+}
+
+func (a certAuthenticator) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*auth.Principal, error) {
+	identity, ok := a.mapper.Identity(cert)
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return &auth.Principal{Subject: identity, Method: "mtls"}, nil
+}
+
+// issueCert returns a PEM certificate and key for cn signed by parent, or
+// self-signed when parent is nil.
+func issueCert(t *testing.T, cn string, serial int64, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestMutualTLS(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	dir := t.TempDir()
+	ca, caKey, caPEM, _ := issueCert(t, "test-ca", 1, true, nil, nil)
+	_, _, serverPEM, serverKeyPEM := issueCert(t, "localhost", 2, false, ca, caKey)
+	_, _, clientPEM, clientKeyPEM := issueCert(t, "forms-builder", 3, false, ca, caKey)
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	certFile, keyFile, caFile := write("server.pem", serverPEM), write("server-key.pem", serverKeyPEM), write("ca.pem", caPEM)
+
+	reloader, err := tlsutil.NewReloader(certFile, keyFile, caFile, tls.RequireAndVerifyClientCert, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapper := auth.NewCertificateMapper([]auth.CertificateIdentity{{Subject: "CN=forms-builder", Identity: "svc-forms-builder"}})
+	r := mux.NewRouter()
+	r.Use(middleware.AuthMiddleware(certAuthenticator{mapper}, logger))
+	r.HandleFunc("/whoami", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.PrincipalFromContext(r.Context()).Subject))
+	})
+	srv := httptest.NewUnstartedServer(r)
+	srv.TLS = reloader.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	clientCert, _ := tls.X509KeyPair(clientPEM, clientKeyPEM)
+	get := func() (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs: roots, Certificates: []tls.Certificate{clientCert}}}}
+		return client.Get(srv.URL + "/whoami")
+	}
+
+	t.Run("ClientCertificateIdentity", func(t *testing.T) {
+		resp, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(body) != "svc-forms-builder" {
+			t.Fatalf("expected svc-forms-builder but got %d %q", resp.StatusCode, body)
+		}
+		if resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 2 {
+			t.Fatalf("unexpected server certificate serial %v", resp.TLS.PeerCertificates[0].SerialNumber)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		_, _, rotatedPEM, rotatedKeyPEM := issueCert(t, "localhost", 4, false, ca, caKey)
+		write("server.pem", rotatedPEM)
+		write("server-key.pem", rotatedKeyPEM)
+		if err := reloader.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 4 {
+			t.Fatalf("expected the rotated certificate but got serial %v", resp.TLS.PeerCertificates[0].SerialNumber)
+		}
+	})
+}
+
+func TestSecretRedaction(t *testing.T) {
+	const password = "s3cr3t pa'ss"
+	path := filepath.Join(t.TempDir(), "db-password")
+	if err := os.WriteFile(path, []byte(password+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_HMAC_SECRET", "hmac-value")
+	cfg := config.Config{
+		Database: config.DatabaseConfig{Host: "localhost", Port: 5432, User: "u", PasswordFile: path, DBName: "d", SSLMode: "disable"},
+		Auth:     config.AuthConfig{JWT: config.JWTConfig{HMACSecret: "env:TEST_HMAC_SECRET"}},
+	}
+	if err := cfg.ResolveSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password.Value() != password || cfg.Auth.JWT.HMACSecret.Value() != "hmac-value" {
+		t.Fatalf("secrets not resolved: %q %q", cfg.Database.Password.Value(), cfg.Auth.JWT.HMACSecret.Value())
+	}
+	if !strings.Contains(dbpkg.DSN(cfg.Database), `password='s3cr3t pa\'ss'`) {
+		t.Fatalf("password not quoted in DSN")
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: logging.RedactAttr}))
+	logger.Info("config", slog.Any("config", cfg), slog.String("dsn", dbpkg.DSN(cfg.Database)))
+	fmt.Fprintf(&buf, "%v %+v", cfg, cfg)
+	if strings.Contains(buf.String(), "s3cr3t") || strings.Contains(buf.String(), "hmac-value") {
+		t.Fatalf("secret leaked: %s", buf.String())
+	}
+}
+
+func TestConfigLoader(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	dir := t.TempDir()
+	base, err := os.ReadFile("config/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("config.yaml", string(base))
+	write("config.staging.yaml", "log:\n  level: \"warn\"\ndatabase:\n  dbname: \"staging\"\n")
+
+	t.Run("ProfileMergedOverBase", func(t *testing.T) {
+		cfg, err := config.NewLoader("staging", logger, dir).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Log.Level != "warn" || cfg.Database.DBName != "staging" || cfg.Database.Host != "localhost" {
+			t.Fatalf("unexpected merged config %+v %+v", cfg.Log, cfg.Database)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		write("config.broken.yaml", "server:\n  port: \"\"\ntracing:\n  sample_ratio: 2\n")
+		_, err := config.NewLoader("broken", logger, dir).Load()
+		if err == nil || !strings.Contains(err.Error(), "server.port") || !strings.Contains(err.Error(), "tracing.sample_ratio") {
+			t.Fatalf("expected errors for server.port and tracing.sample_ratio, got %v", err)
+		}
+	})
+
+	t.Run("HotReload", func(t *testing.T) {
+		loader := config.NewLoader("staging", logger, dir)
+		if _, err := loader.Load(); err != nil {
+			t.Fatal(err)
+		}
+		reloaded := make(chan *config.Config, 10)
+		loader.OnReload(func(cfg *config.Config) { reloaded <- cfg })
+		loader.Watch()
+
+		write("config.staging.yaml", "log:\n  level: \"error\"\ndatabase:\n  dbname: \"staging\"\n")
+		select {
+		case cfg := <-reloaded:
+			if cfg.Log.Level != "error" {
+				t.Fatalf("expected reloaded log level error but got %q", cfg.Log.Level)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("config was not reloaded")
+		}
+	})
+}
+
+func TestCatalogImportExport(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	catalogService := newCatalogService(db, logger)
+	ctx := context.Background()
+
+	t.Run("SeedIsIdempotent", func(t *testing.T) {
+		defaults, err := dbpkg.DefaultCatalog()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := catalogService.Import(ctx, defaults, false); err != nil {
+			t.Fatal(err)
+		}
+		report, err := catalogService.Import(ctx, defaults, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for resource, counts := range report.Resources {
+			if counts.Created != 0 || counts.Updated != 0 {
+				t.Fatalf("second seed changed %s: %+v", resource, counts)
+			}
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		ns := fmt.Sprintf("catalog_%d", time.Now().UnixNano())
+		c := &model.Catalog{
+			Version:     model.CatalogFormatVersion,
+			Types:       []model.Type{{Namespace: ns, Family: "input", Name: "text", ElementType: "text", WidgetType: "text_field"}},
+			Validations: []model.Validation{{Namespace: ns, Family: "text", Name: "required", RuleName: "required", ValidationParams: "{}"}},
+			Attributes: []model.Attribute{{Namespace: ns, Family: "person", Name: "first_name", Label: "First name", DesignSpec: "{}",
+				Type:        model.Type{Namespace: ns, Family: "input", Name: "text"},
+				Validations: []model.Validation{{Namespace: ns, Family: "text", Name: "required"}}}},
+			Forms: []model.Form{{Namespace: ns, Family: "signup", Name: "basic", ActionName: "submit",
+				Attributes: []model.Attribute{{Namespace: ns, Family: "person", Name: "first_name"}}}},
+		}
+		dry, err := catalogService.Import(ctx, c, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !dry.DryRun || dry.Resources["form"].Created != 1 {
+			t.Fatalf("unexpected dry run report %+v", dry)
+		}
+		if _, err := catalogService.Import(ctx, c, false); err != nil {
+			t.Fatal(err)
+		}
+
+		exported, err := catalogService.Export(ctx, ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(exported.Forms) != 1 || len(exported.Forms[0].Attributes) != 1 || len(exported.Attributes[0].Validations) != 1 {
+			t.Fatalf("unexpected export %+v", exported)
+		}
+		report, err := catalogService.Import(ctx, exported, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for resource, counts := range report.Resources {
+			if counts.Unchanged != 1 {
+				t.Fatalf("re-importing the export changed %s: %+v", resource, counts)
+			}
+		}
+	})
+
+	t.Run("MissingReference", func(t *testing.T) {
+		c := &model.Catalog{Version: model.CatalogFormatVersion, Attributes: []model.Attribute{{
+			Namespace: "catalog_missing", Family: "f", Name: "n", Type: model.Type{Namespace: "nope", Family: "nope", Name: "nope"}}}}
+		if _, err := catalogService.Import(ctx, c, false); !errors.Is(err, service.ErrInvalid) {
+			t.Fatalf("expected ErrInvalid but got %v", err)
+		}
+	})
+}
+
+func TestBatchAPI(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("batch_%d", time.Now().UnixNano())
+
+	batch := func(t *testing.T, path string, ops []model.BatchOperation[model.Type]) (int, model.BatchResponse) {
+		body, _ := json.Marshal(model.BatchRequest[model.Type]{Operations: ops})
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var resp model.BatchResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	newType := func(name string) *model.Type {
+		return &model.Type{Namespace: ns, Family: "batch", Name: name, ElementType: "text", WidgetType: "text_field"}
+	}
+	countTypes := func() int64 {
+		var n int64
+		db.Model(&model.Type{}).Where("namespace = ?", ns).Count(&n)
+		return n
+	}
+
+	var first model.BatchResult
+	t.Run("Commit", func(t *testing.T) {
+		code, resp := batch(t, "/types:batch", []model.BatchOperation[model.Type]{
+			{Op: model.BatchCreate, Item: newType("a")},
+			{Op: model.BatchCreate, Item: newType("b")},
+		})
+		if code != http.StatusOK || len(resp.Results) != 2 || resp.Results[0].Status != model.BatchCreated || resp.Results[0].ID == 0 {
+			t.Fatalf("unexpected response %d %+v", code, resp)
+		}
+		first = resp.Results[0]
+		if n := countTypes(); n != 2 {
+			t.Fatalf("expected 2 types but found %d", n)
+		}
+	})
+
+	t.Run("RollBack", func(t *testing.T) {
+		renamed := newType("a2")
+		code, resp := batch(t, "/types:batch", []model.BatchOperation[model.Type]{
+			{Op: model.BatchUpdate, ID: first.ID, Item: renamed},
+			{Op: model.BatchCreate, Item: newType("c")},
+			{Op: model.BatchCreate, Item: newType("b")},
+			{Op: model.BatchDelete, ID: first.ID},
+		})
+		if code != http.StatusConflict {
+			t.Fatalf("expected status code %d but got %d", http.StatusConflict, code)
+		}
+		want := []model.BatchStatus{model.BatchRolledBack, model.BatchRolledBack, model.BatchFailed, model.BatchSkipped}
+		for i, r := range resp.Results {
+			if r.Status != want[i] {
+				t.Fatalf("operation %d: expected %s but got %s", i, want[i], r.Status)
+			}
+		}
+		if resp.Results[2].Error == "" {
+			t.Fatalf("expected an error for the failed operation")
+		}
+		if n := countTypes(); n != 2 {
+			t.Fatalf("expected the batch to be rolled back but found %d types", n)
+		}
+		var a model.Type
+		db.First(&a, first.ID)
+		if a.Name != "a" {
+			t.Fatalf("expected the update to be rolled back but name is %q", a.Name)
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		code, resp := batch(t, "/types:batch", []model.BatchOperation[model.Type]{
+			{Op: model.BatchCreate, Item: newType("d")},
+			{Op: model.BatchUpdate, Item: newType("e")},
+		})
+		if code != http.StatusBadRequest || resp.Results[1].Status != model.BatchFailed {
+			t.Fatalf("unexpected response %d %+v", code, resp)
+		}
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		ops := make([]model.BatchOperation[model.Type], 11)
+		for i := range ops {
+			ops[i] = model.BatchOperation[model.Type]{Op: model.BatchCreate, Item: newType(fmt.Sprintf("big%d", i))}
+		}
+		if code, _ := batch(t, "/types:batch", ops); code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected status code %d but got %d", http.StatusRequestEntityTooLarge, code)
+		}
+	})
+}
+
+func TestFormClone(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("clone_%d", time.Now().UnixNano())
+
+	typ := model.Type{Namespace: ns, Family: "input", Name: "text", ElementType: "text", WidgetType: "text_field"}
+	db.Create(&typ)
+	v := model.Validation{Namespace: ns, Family: "text", Name: "required", RuleName: "required", ValidationParams: "{}"}
+	db.Create(&v)
+	a := model.Attribute{Namespace: ns, Family: "person", Name: "email", Label: "Email", DesignSpec: "{}", TypeID: typ.ID,
+		Validations: []model.Validation{v}}
+	db.Create(&a)
+	f := model.Form{Namespace: ns, Family: "signup", Name: "registration", ActionName: "submit", Attributes: []model.Attribute{a}}
+	db.Create(&f)
+
+	clone := func(req model.CloneRequest) (int, model.CloneResult) {
+		body, _ := json.Marshal(req)
+		r, _ := http.NewRequest("POST", fmt.Sprintf("/forms/%d/clone", f.ID), bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		var result model.CloneResult
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	target := ns + "_copy"
+	t.Run("DeepCopy", func(t *testing.T) {
+		code, result := clone(model.CloneRequest{Namespace: target, DeepCopy: true})
+		if code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, code)
+		}
+		newID := result.Attributes[a.ID]
+		if newID == 0 || newID == a.ID || result.Forms[f.ID] == 0 {
+			t.Fatalf("unexpected mapping %+v", result)
+		}
+		if len(result.Form.Attributes) != 1 || result.Form.Attributes[0].Namespace != target ||
+			len(result.Form.Attributes[0].Validations) != 1 || result.Form.Attributes[0].Validations[0].ID != v.ID {
+			t.Fatalf("unexpected clone %+v", result.Form)
+		}
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		if code, _ := clone(model.CloneRequest{Namespace: target, DeepCopy: true}); code != http.StatusConflict {
+			t.Fatalf("expected status code %d but got %d", http.StatusConflict, code)
+		}
+	})
+
+	t.Run("Suffix", func(t *testing.T) {
+		code, result := clone(model.CloneRequest{Namespace: target, DeepCopy: true, OnConflict: model.CloneSuffix})
+		if code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, code)
+		}
+		if result.Form.Name != "registration_copy" || result.Form.Attributes[0].Name != "email_copy" {
+			t.Fatalf("unexpected names %q and %q", result.Form.Name, result.Form.Attributes[0].Name)
+		}
+	})
+
+	t.Run("Reference", func(t *testing.T) {
+		code, result := clone(model.CloneRequest{Family: "signup_v2"})
+		if code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, code)
+		}
+		if result.Attributes[a.ID] != a.ID || result.Form.Namespace != ns || result.Form.Family != "signup_v2" {
+			t.Fatalf("unexpected clone %+v", result)
+		}
+	})
+
+	t.Run("Reuse", func(t *testing.T) {
+		var existing model.Form
+		db.Preload("Attributes").Where("namespace = ? AND family = ? AND name = ?", target, "signup", "registration").First(&existing)
+		code, result := clone(model.CloneRequest{Namespace: target, DeepCopy: true, OnConflict: model.CloneReuse})
+		if code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, code)
+		}
+		if !result.Reused || result.Forms[f.ID] != existing.ID || result.Attributes[a.ID] != existing.Attributes[0].ID {
+			t.Fatalf("expected form %d to be reused but got %+v", existing.ID, result)
+		}
+		if result.Form.Version != existing.Version || len(result.Form.Attributes) != 1 ||
+			result.Form.Attributes[0].ID != existing.Attributes[0].ID {
+			t.Fatalf("expected the reused form unchanged but got %+v", result.Form)
+		}
+	})
+}
+
+func TestValidationRules(t *testing.T) {
+	cases := []struct {
+		rule, params string
+		value        interface{}
+		ok           bool
+	}{
+		{"required", "{}", "", false},
+		{"required", "{}", []interface{}{}, false},
+		{"required", "{}", "x", true},
+		{"email", "{}", "a@example.com", true},
+		{"email", "{}", "Firstname", false},
+		{"email", "{}", "", true},
+		{"min_length", `{"min": 3}`, "ab", false},
+		{"min_length", "{}", "ab", true},
+		{"max_value", `{"max": 10}`, 11.0, false},
+		{"numeric", "{}", "12.5", true},
+		{"match_pattern", `{"pattern": "^[A-Z]{2}$"}`, "DE", true},
+		{"valid_credit_card", "{}", "4111 1111 1111 1111", true},
+		{"valid_credit_card", "{}", "4111 1111 1111 1112", false},
+		{"exact_words_count", `{"count": 2}`, "one two three", false},
+		{"custom_client_rule", "{}", "anything", true},
+	}
+	for _, c := range cases {
+		if ok, msg := rules.Check(c.rule, c.params, c.value); ok != c.ok {
+			t.Errorf("%s(%s) on %#v: expected %v but got %v (%s)", c.rule, c.params, c.value, c.ok, ok, msg)
+		}
+	}
+}
+
+func TestMultiPageForm(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("pages_%d", time.Now().UnixNano())
+
+	typ := model.Type{Namespace: ns, Family: "input", Name: "text", ElementType: "text", WidgetType: "text_field"}
+	db.Create(&typ)
+	required := model.Validation{Namespace: ns, Family: "text", Name: "required", RuleName: "required", ValidationParams: "{}"}
+	db.Create(&required)
+	var attributes []model.Attribute
+	for _, name := range []string{"account_type", "company_name", "first_name"} {
+		a := model.Attribute{Namespace: ns, Family: "onboarding", Name: name, Label: name, DesignSpec: "{}", TypeID: typ.ID,
+			Validations: []model.Validation{required}}
+		db.Create(&a)
+		attributes = append(attributes, model.Attribute{ID: a.ID})
+	}
+
+	form := model.Form{Namespace: ns, Family: "onboarding", Name: "wizard", ActionName: "submit", Attributes: attributes,
+		Pages: model.Pages{
+			{Name: "account", Title: "Account", Attributes: []string{"account_type"}, Navigation: []model.NavigationRule{
+				{When: model.Condition{Attribute: "account_type", Op: model.OpEquals, Value: "personal"}, GoTo: "person"},
+			}},
+			{Name: "company", Title: "Company", Attributes: []string{"company_name"}},
+			{Name: "person", Title: "You", Attributes: []string{"first_name"}},
+		}}
+
+	t.Run("RejectsBadPages", func(t *testing.T) {
+		bad := form
+		bad.Name = "bad"
+		bad.Pages = model.Pages{{Name: "only", Attributes: []string{"account_type", "missing"}}}
+		if w := doJSON(router, "POST", "/forms", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		bad.Pages = model.Pages{
+			{Name: "one", Attributes: []string{"account_type", "company_name"}},
+			{Name: "two", Attributes: []string{"first_name"}, Navigation: []model.NavigationRule{
+				{When: model.Condition{Attribute: "first_name", Op: model.OpSet}, GoTo: "one"},
+			}},
+		}
+		if w := doJSON(router, "POST", "/forms", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected backward navigation to be rejected but got %d", w.Code)
+		}
+	})
+
+	w := doJSON(router, "POST", "/forms", form)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&form)
+
+	t.Run("Resolved", func(t *testing.T) {
+		w := doJSON(router, "GET", fmt.Sprintf("/forms/%d/resolved", form.ID), nil)
+		var resolved model.ResolvedForm
+		json.NewDecoder(w.Body).Decode(&resolved)
+		if len(resolved.Pages) != 3 || resolved.Pages[1].Attributes[0].Name != "company_name" ||
+			resolved.Pages[1].Attributes[0].WidgetType != "text_field" || len(resolved.Pages[1].Attributes[0].Validations) != 1 {
+			t.Fatalf("unexpected resolved form %+v", resolved)
+		}
+	})
+
+	t.Run("PerPage", func(t *testing.T) {
+		report := validateForm(t, router, form.ID, model.Submission{Page: "account", Values: map[string]interface{}{"account_type": "personal"}})
+		if !report.Valid || report.NextPage != "person" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		report = validateForm(t, router, form.ID, model.Submission{Page: "account", Values: map[string]interface{}{}})
+		if report.Valid || report.Errors[0].Attribute != "account_type" || report.NextPage != "company" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("SkipsPagesOffThePath", func(t *testing.T) {
+		report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"account_type": "personal", "first_name": "Ada"}})
+		if !report.Valid || strings.Join(report.Pages, ",") != "account,person" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		report = validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"account_type": "business", "first_name": "Ada"}})
+		if report.Valid || len(report.Errors) != 1 || report.Errors[0].Attribute != "company_name" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+	})
+}
+
+func TestConditionExpressions(t *testing.T) {
+	values := map[string]interface{}{"account_type": "business", "employees": 12.0, "country": "DE", "tags": []interface{}{"a", "b"}}
+	cases := map[string]bool{
+		"account_type == 'business'":                true,
+		"account_type != \"business\"":              false,
+		"employees > 10 && country in ['DE', 'FR']": true,
+		"!(employees >= 12) || missing":             false,
+		"country not in ['DE']":                     false,
+		"tags in ['b', 'c']":                        true,
+		"missing == null":                           true,
+	}
+	for src, want := range cases {
+		e, err := expr.Parse(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if got := e.Eval(values); got != want {
+			t.Errorf("%s: expected %v but got %v", src, want, got)
+		}
+	}
+	for _, src := range []string{"a ==", "(a", "a b", "a = 'x'", "'open"} {
+		if _, err := expr.Parse(src); err == nil {
+			t.Errorf("%s: expected a parse error", src)
+		}
+	}
+}
+
+func TestConditionalRules(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("conditions_%d", time.Now().UnixNano())
+
+	typ := model.Type{Namespace: ns, Family: "input", Name: "text", ElementType: "text", WidgetType: "text_field"}
+	db.Create(&typ)
+	required := model.Validation{Namespace: ns, Family: "text", Name: "required", RuleName: "required", ValidationParams: "{}"}
+	db.Create(&required)
+	var attributes []model.Attribute
+	for _, name := range []string{"account_type", "company_name", "contact_preference", "phone"} {
+		a := model.Attribute{Namespace: ns, Family: "contact", Name: name, Label: name, DesignSpec: "{}", TypeID: typ.ID}
+		if name == "company_name" {
+			a.Validations = []model.Validation{required}
+		}
+		db.Create(&a)
+		attributes = append(attributes, model.Attribute{ID: a.ID})
+	}
+
+	form := model.Form{Namespace: ns, Family: "contact", Name: "details", ActionName: "submit", Attributes: attributes,
+		Conditions: model.Conditions{
+			"company_name": {VisibleIf: "account_type == 'business'"},
+			"phone":        {RequiredIf: "contact_preference == 'sms'"},
+		}}
+
+	t.Run("RejectsUnknownNames", func(t *testing.T) {
+		bad := form
+		bad.Name = "bad"
+		bad.Conditions = model.Conditions{"phone": {RequiredIf: "contact_method == 'sms'"}}
+		if w := doJSON(router, "POST", "/forms", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		bad.Conditions = model.Conditions{"phone": {RequiredIf: "contact_preference =="}}
+		if w := doJSON(router, "POST", "/forms", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	w := doJSON(router, "POST", "/forms", form)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&form)
+
+	t.Run("ReturnedInReads", func(t *testing.T) {
+		var got model.Form
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d", form.ID), nil).Body).Decode(&got)
+		if got.Conditions["phone"].RequiredIf != "contact_preference == 'sms'" {
+			t.Fatalf("unexpected conditions %+v", got.Conditions)
+		}
+	})
+
+	t.Run("HiddenFieldsSkipped", func(t *testing.T) {
+		if report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"account_type": "personal"}}); !report.Valid {
+			t.Fatalf("expected hidden company_name to be skipped but got %+v", report.Errors)
+		}
+		if report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"account_type": "business"}}); report.Valid {
+			t.Fatalf("expected visible company_name to be required")
+		}
+	})
+
+	t.Run("ConditionallyRequired", func(t *testing.T) {
+		report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"account_type": "personal", "contact_preference": "sms"}})
+		if report.Valid || report.Errors[0].Attribute != "phone" || report.Errors[0].Rule != "required_if" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		report = validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"account_type": "personal", "contact_preference": "email"}})
+		if !report.Valid {
+			t.Fatalf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("FollowsRenames", func(t *testing.T) {
+		var accountType model.Attribute
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/attributes/%d", attributes[0].ID), nil).Body).Decode(&accountType)
+		accountType.Name = "customer_type"
+		if w := doJSON(router, "PUT", fmt.Sprintf("/attributes/%d", accountType.ID), accountType); w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusNoContent, w.Code, w.Body)
+		}
+		var got model.Form
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d", form.ID), nil).Body).Decode(&got)
+		if got.Conditions["company_name"].VisibleIf != "customer_type == 'business'" {
+			t.Fatalf("expected the condition to follow the rename but got %+v", got.Conditions)
+		}
+		if report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"customer_type": "business"}}); report.Valid {
+			t.Fatalf("expected visible company_name to stay required")
+		}
+	})
+
+	t.Run("CascadeKeepsReadAnswers", func(t *testing.T) {
+		// company_name's visibility reads account_type, now customer_type.
+		if w := doJSON(router, "DELETE", fmt.Sprintf("/attributes/%d?cascade=true", attributes[0].ID), nil); w.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d but got %d", http.StatusConflict, w.Code)
+		}
+		if w := doJSON(router, "DELETE", fmt.Sprintf("/attributes/%d?cascade=true", attributes[1].ID), nil); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusOK, w.Code, w.Body)
+		}
+		var got model.Form
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d", form.ID), nil).Body).Decode(&got)
+		if _, ok := got.Conditions["company_name"]; ok || len(got.Attributes) != 3 {
+			t.Fatalf("expected company_name and its conditions gone but got %+v", got)
+		}
+	})
+}
+
+func TestRepeatableGroups(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("groups_%d", time.Now().UnixNano())
+
+	typ := model.Type{Namespace: ns, Family: "input", Name: "text", ElementType: "text", WidgetType: "text_field"}
+	db.Create(&typ)
+	required := model.Validation{Namespace: ns, Family: "text", Name: "required", RuleName: "required", ValidationParams: "{}"}
+	db.Create(&required)
+	var attributes []model.Attribute
+	for _, name := range []string{"full_name", "street", "city"} {
+		a := model.Attribute{Namespace: ns, Family: "address", Name: name, Label: name, DesignSpec: "{}", TypeID: typ.ID,
+			Validations: []model.Validation{required}}
+		db.Create(&a)
+		attributes = append(attributes, model.Attribute{ID: a.ID})
+	}
+
+	form := model.Form{Namespace: ns, Family: "address", Name: "book", ActionName: "submit", Attributes: attributes,
+		Groups: model.Groups{{Name: "addresses", Title: "Addresses", Attributes: []string{"street", "city"}, MinOccurs: 1, MaxOccurs: 2}}}
+
+	t.Run("RejectsInvalidGroups", func(t *testing.T) {
+		bad := form
+		bad.Name = "bad"
+		bad.Groups = model.Groups{{Name: "addresses", Attributes: []string{"street"}, MinOccurs: 3, MaxOccurs: 2}}
+		if w := doJSON(router, "POST", "/forms", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		bad.Groups = model.Groups{{Name: "addresses", Attributes: []string{"street", "zip"}}}
+		if w := doJSON(router, "POST", "/forms", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	w := doJSON(router, "POST", "/forms", form)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&form)
+
+	t.Run("Resolved", func(t *testing.T) {
+		var resolved model.ResolvedForm
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d/resolved", form.ID), nil).Body).Decode(&resolved)
+		page := resolved.Pages[0]
+		if len(page.Attributes) != 1 || len(page.Groups) != 1 || len(page.Groups[0].Attributes) != 2 ||
+			page.Groups[0].MaxOccurs != 2 {
+			t.Fatalf("unexpected resolved page %+v", page)
+		}
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		var schema struct {
+			Properties map[string]struct {
+				Type     string
+				MinItems int
+				MaxItems int
+			}
+			Required []string
+		}
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d/schema", form.ID), nil).Body).Decode(&schema)
+		if g := schema.Properties["addresses"]; g.Type != "array" || g.MinItems != 1 || g.MaxItems != 2 {
+			t.Fatalf("unexpected schema %+v", schema)
+		}
+	})
+
+	t.Run("Occurrences", func(t *testing.T) {
+		report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"full_name": "Ada"}})
+		if report.Valid || report.Errors[0].Group != "addresses" || report.Errors[0].Rule != "min_occurs" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		address := map[string]interface{}{"street": "1 Main St", "city": "Springfield"}
+		report = validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"full_name": "Ada", "addresses": []interface{}{address, address, address}}})
+		if report.Valid || report.Errors[0].Rule != "max_occurs" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("PerOccurrenceValidations", func(t *testing.T) {
+		report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"full_name": "Ada", "addresses": []interface{}{
+			map[string]interface{}{"street": "1 Main St", "city": "Springfield"},
+			map[string]interface{}{"street": "2 Side St"},
+		}}})
+		if report.Valid || len(report.Errors) != 1 || report.Errors[0].Attribute != "city" ||
+			report.Errors[0].Occurrence == nil || *report.Errors[0].Occurrence != 1 {
+			t.Fatalf("unexpected report %+v", report)
+		}
+	})
+}
+
+func TestOptionSets(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("options_%d", time.Now().UnixNano())
+
+	colours := model.OptionSet{Namespace: ns, Family: "palette", Name: "colours", Options: model.Options{
+		{Value: "red", Label: "Red", Group: "warm"},
+		{Value: "blue", Label: "Blue", Group: "cool"},
+		{Value: "beige", Label: "Beige", Disabled: true},
+	}}
+
+	t.Run("RejectsDuplicateValues", func(t *testing.T) {
+		bad := colours
+		bad.Name = "bad"
+		bad.Options = model.Options{{Value: "red"}, {Value: "red"}}
+		if w := doJSON(router, "POST", "/optionsets", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	w := doJSON(router, "POST", "/optionsets", colours)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&colours)
+
+	choice := model.Type{Namespace: ns, Family: "input", Name: "choice", ElementType: "choice", WidgetType: "choice_field"}
+	db.Create(&choice)
+	multi := model.Type{Namespace: ns, Family: "input", Name: "multi_choice", ElementType: "multi_choice", WidgetType: "multi_choice_field"}
+	db.Create(&multi)
+
+	t.Run("RejectsUnknownOptionSet", func(t *testing.T) {
+		missing := uint64(1 << 40)
+		a := model.Attribute{Namespace: ns, Family: "prefs", Name: "bad", DesignSpec: "{}", TypeID: choice.ID, OptionSetID: &missing}
+		if w := doJSON(router, "POST", "/attributes", a); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	var attributes []model.Attribute
+	for name, typeID := range map[string]uint64{"favourite": choice.ID, "liked": multi.ID} {
+		a := model.Attribute{Namespace: ns, Family: "prefs", Name: name, Label: name, DesignSpec: "{}", TypeID: typeID,
+			OptionSetID: &colours.ID}
+		w := doJSON(router, "POST", "/attributes", a)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+		}
+		json.NewDecoder(w.Body).Decode(&a)
+		attributes = append(attributes, model.Attribute{ID: a.ID})
+	}
+	form := model.Form{Namespace: ns, Family: "prefs", Name: "survey", ActionName: "submit", Attributes: attributes}
+	w = doJSON(router, "POST", "/forms", form)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&form)
+
+	t.Run("Resolved", func(t *testing.T) {
+		var resolved model.ResolvedForm
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d/resolved", form.ID), nil).Body).Decode(&resolved)
+		for _, a := range resolved.Pages[0].Attributes {
+			if len(a.Options) != 3 || a.Options[0].Group != "warm" {
+				t.Fatalf("unexpected options %+v", a.Options)
+			}
+		}
+	})
+
+	t.Run("ValidatesAnswers", func(t *testing.T) {
+		if report := validateForm(t, router, form.ID, model.Submission{Values: map[string]interface{}{"favourite": "red", "liked": []interface{}{"red", "blue"}}}); !report.Valid {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		for _, values := range []map[string]interface{}{
+			{"favourite": "green"},
+			{"favourite": "beige"},
+			{"favourite": []interface{}{"red"}},
+			{"liked": "red"},
+			{"liked": []interface{}{"red", "green"}},
+		} {
+			if report := validateForm(t, router, form.ID, model.Submission{Values: values}); report.Valid || report.Errors[0].Rule != "options" {
+				t.Fatalf("expected %v to be rejected but got %+v", values, report)
+			}
+		}
+	})
+
+	t.Run("DeleteRestricted", func(t *testing.T) {
+		if w := doJSON(router, "DELETE", fmt.Sprintf("/optionsets/%d", colours.ID), nil); w.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d but got %d", http.StatusConflict, w.Code)
+		}
+	})
+}
+
+func TestDataSourceOptions(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	var requests atomic.Int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/cities":
+			cities := map[string][]map[string]interface{}{
+				"DE": {{"code": "BER", "name": "Berlin", "state": "Berlin"}, {"code": "MUC", "name": "Munich", "state": "Bavaria"}},
+				"FR": {{"code": "PAR", "name": "Paris", "state": "Île-de-France"}},
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"cities": cities[r.URL.Query().Get("country")]}})
+		case "/moved":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	defer stub.Close()
+
+	cities := &model.DataSource{URL: stub.URL + "/cities?country={country}", DependsOn: []string{"country"},
+		Mapping: model.ResponseMapping{Items: "data.cities", Value: "code", Label: "name", Group: "state"}}
+	form := &model.Form{Attributes: []model.Attribute{
+		{Name: "city", DataSource: cities},
+		{Name: "broken", DataSource: &model.DataSource{URL: stub.URL + "/broken", Mapping: model.ResponseMapping{Value: "code"}}},
+		{Name: "colour", OptionSet: &model.OptionSet{Options: model.Options{{Value: "red", Label: "Red"}}}},
+		{Name: "free_text"},
+	}}
+	limits := service.DataSourceLimits{Timeout: 5 * time.Second, CacheTTL: time.Minute, MaxCacheEntries: 10,
+		MaxResponseBytes: 1 << 20, AllowedHosts: []string{"127.0.0.1"}}
+	options := service.NewOptionService(stub.Client(), limits, logger)
+	ctx := context.Background()
+
+	t.Run("MappedAndCached", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			got, err := options.Options(ctx, form, "city", map[string]string{"country": "DE"}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 || !reflect.DeepEqual(got[1], model.Option{Value: "MUC", Label: "Munich", Group: "Bavaria"}) {
+				t.Fatalf("unexpected options %+v", got)
+			}
+		}
+		if n := requests.Load(); n != 1 {
+			t.Fatalf("expected one request to the data source but got %d", n)
+		}
+	})
+
+	t.Run("DependsOnAnswers", func(t *testing.T) {
+		got, err := options.Options(ctx, form, "city", map[string]string{"country": "FR"}, nil)
+		if err != nil || len(got) != 1 || got[0].Value != "PAR" {
+			t.Fatalf("unexpected options %+v, %v", got, err)
+		}
+		if _, err := options.Options(ctx, form, "city", nil, nil); !errors.Is(err, service.ErrInvalid) {
+			t.Fatalf("expected ErrInvalid without a country but got %v", err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, err := options.Options(ctx, form, "broken", nil, nil); !errors.Is(err, service.ErrUpstream) {
+			t.Fatalf("expected ErrUpstream but got %v", err)
+		}
+		if _, err := options.Options(ctx, form, "free_text", nil, nil); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound but got %v", err)
+		}
+		restricted := limits
+		restricted.AllowedHosts = []string{"options.example.com"}
+		options.SetLimits(restricted)
+		defer options.SetLimits(limits)
+		if _, err := options.Options(ctx, form, "city", map[string]string{"country": "XX"}, nil); !errors.Is(err, service.ErrUpstream) {
+			t.Fatalf("expected a disallowed host to fail but got %v", err)
+		}
+	})
+
+	t.Run("GuardsTargets", func(t *testing.T) {
+		mapping := model.ResponseMapping{Value: "code"}
+		guarded := &model.Form{Attributes: []model.Attribute{
+			{Name: "moved", DataSource: &model.DataSource{URL: stub.URL + "/moved", Mapping: mapping}},
+			{Name: "by_name", DataSource: &model.DataSource{URL: strings.Replace(stub.URL, "127.0.0.1", "localhost", 1) + "/cities",
+				Mapping: mapping}},
+		}}
+		if _, err := options.Options(ctx, guarded, "moved", nil, nil); !errors.Is(err, service.ErrUpstream) ||
+			!strings.Contains(err.Error(), "169.254.169.254") {
+			t.Fatalf("expected a redirect to a disallowed host to fail but got %v", err)
+		}
+		byName := limits
+		byName.AllowedHosts = []string{"localhost"}
+		options.SetLimits(byName)
+		defer options.SetLimits(limits)
+		if _, err := options.Options(ctx, guarded, "by_name", nil, nil); !errors.Is(err, service.ErrUpstream) ||
+			!strings.Contains(err.Error(), "address 127.0.0.1 is not allowed") {
+			t.Fatalf("expected an unlisted loopback address to fail but got %v", err)
+		}
+		options.SetLimits(service.DataSourceLimits{Timeout: time.Second})
+		if _, err := options.Options(ctx, form, "city", map[string]string{"country": "NL"}, nil); !errors.Is(err, service.ErrUpstream) {
+			t.Fatalf("expected lookups to fail without allowed hosts but got %v", err)
+		}
+	})
+
+	t.Run("OptionSet", func(t *testing.T) {
+		got, err := options.Options(ctx, form, "colour", nil, nil)
+		if err != nil || len(got) != 1 || got[0].Value != "red" {
+			t.Fatalf("unexpected options %+v, %v", got, err)
+		}
+	})
+}
+
+func TestLocaleChain(t *testing.T) {
+	for _, tc := range []struct {
+		preferred []string
+		want      []string
+	}{
+		{nil, nil},
+		{[]string{"fr-CA"}, []string{"fr-CA", "fr", "en"}},
+		{[]string{"de-AT", "fr", "de"}, []string{"de-AT", "de", "fr", "en"}},
+		{[]string{"en-GB", "fr"}, []string{"en-GB", "en", "fr"}},
+	} {
+		if got := service.LocaleChain(tc.preferred); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("LocaleChain(%v) = %v, want %v", tc.preferred, got, tc.want)
+		}
+	}
+}
+
+func TestLocalization(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("i18n_%d", time.Now().UnixNano())
+
+	t.Run("RejectsNonCanonicalLocales", func(t *testing.T) {
+		a := model.Attribute{Namespace: ns, Family: "contact", Name: "bad", DesignSpec: "{}",
+			Translations: model.AttributeTranslations{"pt_br": {Label: "Nome"}}}
+		if w := doJSON(router, "POST", "/attributes", a); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	sizes := model.OptionSet{Namespace: ns, Family: "shop", Name: "sizes", Options: model.Options{
+		{Value: "s", Label: "Small", Labels: model.Localized{"fr": "Petit"}},
+		{Value: "l", Label: "Large"},
+	}}
+	w := doJSON(router, "POST", "/optionsets", sizes)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&sizes)
+	required := model.Validation{Namespace: ns, Family: "common", Name: "required", RuleName: "required",
+		Messages: model.Localized{"fr": "est obligatoire"}}
+	db.Create(&required)
+	choice := model.Type{Namespace: ns, Family: "input", Name: "choice", ElementType: "choice", WidgetType: "choice_field"}
+	db.Create(&choice)
+
+	email := model.Attribute{Namespace: ns, Family: "contact", Name: "email", Label: "Email", HelpText: "We never share it",
+		DesignSpec: "{}", TypeID: choice.ID, Validations: []model.Validation{required},
+		Translations: model.AttributeTranslations{"fr": {Label: "Courriel"}, "fr-CA": {HelpText: "Jamais partagé"}}}
+	size := model.Attribute{Namespace: ns, Family: "shop", Name: "size", Label: "Size", DesignSpec: "{}",
+		TypeID: choice.ID, OptionSetID: &sizes.ID}
+	var attributes []model.Attribute
+	for _, a := range []*model.Attribute{&email, &size} {
+		w := doJSON(router, "POST", "/attributes", a)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+		}
+		json.NewDecoder(w.Body).Decode(a)
+		attributes = append(attributes, model.Attribute{ID: a.ID})
+	}
+	form := model.Form{Namespace: ns, Family: "shop", Name: "order", ActionName: "submit", Attributes: attributes}
+	w = doJSON(router, "POST", "/forms", form)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&form)
+
+	resolve := func(path string, header ...string) map[string]model.ResolvedAttribute {
+		var resolved model.ResolvedForm
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d/resolved%s", form.ID, path), nil, header...).Body).Decode(&resolved)
+		byName := map[string]model.ResolvedAttribute{}
+		for _, a := range resolved.Pages[0].Attributes {
+			byName[a.Name] = a
+		}
+		return byName
+	}
+
+	t.Run("FallsBack", func(t *testing.T) {
+		got := resolve("", "Accept-Language", "fr-CA, en;q=0.5")
+		if got["email"].Label != "Courriel" || got["email"].HelpText != "Jamais partagé" {
+			t.Fatalf("unexpected texts %+v", got["email"])
+		}
+		if o := got["size"].Options; o[0].Label != "Petit" || o[1].Label != "Large" {
+			t.Fatalf("unexpected options %+v", o)
+		}
+		got = resolve("?locale=de", "Accept-Language", "fr")
+		if got["email"].Label != "Email" || got["email"].HelpText != "We never share it" {
+			t.Fatalf("expected untranslated texts but got %+v", got["email"])
+		}
+	})
+
+	t.Run("ValidationMessages", func(t *testing.T) {
+		var report model.ValidationReport
+		json.NewDecoder(doJSON(router, "POST", fmt.Sprintf("/forms/%d/validate?locale=fr", form.ID),
+			model.Submission{Values: map[string]interface{}{}}).Body).Decode(&report)
+		if report.Valid || report.Errors[0].Message != "est obligatoire" {
+			t.Fatalf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("Reports", func(t *testing.T) {
+		var reports []model.TranslationReport
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d/translations", form.ID), nil).Body).Decode(&reports)
+		if len(reports) != 2 || reports[0].Locale != "fr" || reports[1].Locale != "fr-CA" {
+			t.Fatalf("unexpected reports %+v", reports)
+		}
+		// fr lacks the help text, the size label and the label of "l".
+		if fr := reports[0]; fr.Total != 6 || fr.Translated != 3 || len(fr.Missing) != 3 {
+			t.Fatalf("unexpected fr report %+v", fr)
+		}
+		if fr := reports[1]; fr.Translated != 4 {
+			t.Fatalf("unexpected fr-CA report %+v", fr)
+		}
+	})
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := jsonschema.Compile([]byte(`{
+		"type": "object",
+		"required": ["size"],
+		"additionalProperties": false,
+		"properties": {
+			"size": {"enum": ["small", "large"]},
+			"rows": {"type": "integer", "minimum": 1, "maximum": 20},
+			"placeholder": {"type": "string", "maxLength": 5},
+			"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		doc  string
+		errs int
+	}{
+		{`{"size": "small"}`, 0},
+		{`{"size": "small", "rows": 3, "tags": ["a", "b"]}`, 0},
+		{`{}`, 1},
+		{`{"size": "medium"}`, 1},
+		{`{"size": "small", "rows": 2.5}`, 1},
+		{`{"size": "small", "rows": 30, "placeholder": "too long"}`, 2},
+		{`{"size": "small", "colour": "red"}`, 1},
+		{`{"size": "small", "tags": ["ok", "NO"]}`, 1},
+		{`[]`, 1},
+	}
+	for _, c := range cases {
+		var doc interface{}
+		json.Unmarshal([]byte(c.doc), &doc)
+		if errs := schema.Validate(doc); len(errs) != c.errs {
+			t.Errorf("%s: expected %d errors but got %v", c.doc, c.errs, errs)
+		}
+	}
+	if errs := schema.ValidatePartial(map[string]interface{}{}); len(errs) != 0 {
+		t.Errorf("expected partial validation to skip required but got %v", errs)
+	}
+	for _, src := range []string{`[]`, `{"type": "text"}`, `{"minLenght": 3}`, `{"properties": {"a": {"pattern": "("}}}`} {
+		if _, err := jsonschema.Compile([]byte(src)); err == nil {
+			t.Errorf("expected %s not to compile", src)
+		}
+	}
+}
+
+func TestDesignSpecs(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("design_%d", time.Now().UnixNano())
+
+	textArea := model.Type{Namespace: ns, Family: "input", Name: "text_area", ElementType: "text", WidgetType: "text_area",
+		DesignSchema: model.Document(`{"type": "object", "additionalProperties": false, "required": ["rows"],
+			"properties": {"rows": {"type": "integer", "minimum": 1}, "resize": {"type": "boolean"}}}`),
+		DesignDefaults: model.Document(`{"rows": 4, "resize": true}`)}
+
+	t.Run("RejectsBadSchemas", func(t *testing.T) {
+		bad := textArea
+		bad.Name = "bad"
+		bad.DesignDefaults = model.Document(`{"rows": 0}`)
+		if w := doJSON(router, "POST", "/types", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		bad.DesignDefaults, bad.DesignSchema = nil, model.Document(`{"propertys": {}}`)
+		if w := doJSON(router, "POST", "/types", bad); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	w := doJSON(router, "POST", "/types", textArea)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&textArea)
+
+	notes := model.Attribute{Namespace: ns, Family: "order", Name: "notes", Label: "Notes", TypeID: textArea.ID,
+		DesignSpec: `{"rowz": 8}`}
+	t.Run("RejectsNonConformingSpecs", func(t *testing.T) {
+		w := doJSON(router, "POST", "/attributes", notes)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "/rowz") {
+			t.Fatalf("expected status code %d naming /rowz but got %d: %s", http.StatusBadRequest, w.Code, w.Body)
+		}
+	})
+
+	t.Run("ChecksInlineTypes", func(t *testing.T) {
+		inline := notes
+		inline.Name, inline.TypeID, inline.Type = "inline_notes", 0, textArea
+		inline.Type.ID, inline.Type.Name = 0, "inline_text_area"
+		if w := doJSON(router, "POST", "/attributes", inline); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		inline.DesignSpec = `{"rows": 8}`
+		inline.Type.DesignDefaults = model.Document(`{"rows": "four"}`)
+		if w := doJSON(router, "POST", "/attributes", inline); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	notes.DesignSpec = `{"rows": 8}`
+	w = doJSON(router, "POST", "/attributes", notes)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	json.NewDecoder(w.Body).Decode(&notes)
+
+	t.Run("RejectsNonConformingUpdates", func(t *testing.T) {
+		update := notes
+		update.DesignSpec = `{"rows": "many"}`
+		if w := doJSON(router, "PUT", fmt.Sprintf("/attributes/%d", notes.ID), update); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("MergesDefaults", func(t *testing.T) {
+		form := model.Form{Namespace: ns, Family: "order", Name: "checkout", ActionName: "submit",
+			Attributes: []model.Attribute{{ID: notes.ID}}}
+		w := doJSON(router, "POST", "/forms", form)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+		}
+		json.NewDecoder(w.Body).Decode(&form)
+		var resolved model.ResolvedForm
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d/resolved", form.ID), nil).Body).Decode(&resolved)
+		var spec map[string]interface{}
+		json.Unmarshal(resolved.Pages[0].Attributes[0].DesignSpec, &spec)
+		if spec["rows"] != 8.0 || spec["resize"] != true {
+			t.Fatalf("unexpected design spec %v", spec)
+		}
+	})
+}
+
+func TestTypeInheritance(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	db := setupTestDB(logger)
+	router := setupRouter(db, logger)
+	ns := fmt.Sprintf("inherit_%d", time.Now().UnixNano())
+
+	create := func(path string, v interface{}) {
+		w := doJSON(router, "POST", path, v)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusCreated, w.Code, w.Body)
+		}
+		json.NewDecoder(w.Body).Decode(v)
+	}
+
+	isEmail := model.Validation{Namespace: ns, Family: "text", Name: "email", RuleName: "email", ValidationParams: "{}"}
+	max254 := model.Validation{Namespace: ns, Family: "text", Name: "max_254", RuleName: "max_length", ValidationParams: `{"max": 254}`}
+	max100 := model.Validation{Namespace: ns, Family: "text", Name: "max_100", RuleName: "max_length", ValidationParams: `{"max": 100}`}
+	corporate := model.Validation{Namespace: ns, Family: "text", Name: "corporate", RuleName: "match_pattern",
+		ValidationParams: `{"pattern": "@example\\.com$"}`}
+	required := model.Validation{Namespace: ns, Family: "text", Name: "required", RuleName: "required", ValidationParams: "{}"}
+	for _, v := range []*model.Validation{&isEmail, &max254, &max100, &corporate, &required} {
+		create("/validations", v)
+	}
+
+	email := model.Type{Namespace: ns, Family: "input", Name: "email", ElementType: "text", WidgetType: "email",
+		DesignSchema: model.Document(`{"type": "object", "additionalProperties": false,
+			"properties": {"icon": {"type": "string"}, "placeholder": {"type": "string"}}}`),
+		DesignDefaults: model.Document(`{"icon": "mail", "placeholder": "name@host"}`),
+		Validations:    []model.Validation{isEmail, max254}}
+	create("/types", &email)
+	corporateEmail := model.Type{Namespace: ns, Family: "input", Name: "corporate_email", ParentID: &email.ID,
+		DesignDefaults: model.Document(`{"placeholder": "name@example.com"}`),
+		Validations:    []model.Validation{corporate, max100}}
+	create("/types", &corporateEmail)
+
+	t.Run("EffectiveType", func(t *testing.T) {
+		w := doJSON(router, "GET", fmt.Sprintf("/types/%d/effective", corporateEmail.ID), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusOK, w.Code, w.Body)
+		}
+		var e model.EffectiveType
+		json.NewDecoder(w.Body).Decode(&e)
+		if e.ElementType != "text" || e.WidgetType != "email" || len(e.DesignSchema) == 0 {
+			t.Fatalf("expected the parent's element, widget and schema but got %+v", e)
+		}
+		if len(e.Ancestors) != 1 || e.Ancestors[0].ID != email.ID {
+			t.Fatalf("expected ancestors [%d] but got %+v", email.ID, e.Ancestors)
+		}
+		var defaults map[string]interface{}
+		json.Unmarshal(e.DesignDefaults, &defaults)
+		if defaults["icon"] != "mail" || defaults["placeholder"] != "name@example.com" {
+			t.Fatalf("unexpected design defaults %v", defaults)
+		}
+		var names []string
+		for _, v := range e.Validations {
+			names = append(names, v.Name)
+		}
+		if want := []string{"email", "corporate", "max_100"}; !reflect.DeepEqual(names, want) {
+			t.Fatalf("expected validations %v but got %v", want, names)
+		}
+	})
+
+	t.Run("RejectsCycles", func(t *testing.T) {
+		update := email
+		update.ParentID = &corporateEmail.ID
+		if w := doJSON(router, "PUT", fmt.Sprintf("/types/%d", email.ID), update); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		missing := uint64(1 << 40)
+		orphan := model.Type{Namespace: ns, Family: "input", Name: "orphan", ParentID: &missing}
+		if w := doJSON(router, "POST", "/types", orphan); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("LimitsDescendantDepth", func(t *testing.T) {
+		chain := func(name string, n int) []model.Type {
+			types := make([]model.Type, n)
+			for i := range types {
+				types[i] = model.Type{Namespace: ns, Family: "chain", Name: fmt.Sprintf("%s_%d", name, i)}
+				if i > 0 {
+					types[i].ParentID = &types[i-1].ID
+				}
+				create("/types", &types[i])
+			}
+			return types
+		}
+		// Moving the root of a chain of four under a chain of five would make
+		// the deepest type extend eight others.
+		lower, upper := chain("lower", 4), chain("upper", 5)
+		update := lower[0]
+		update.ParentID = &upper[4].ID
+		if w := doJSON(router, "PUT", fmt.Sprintf("/types/%d", update.ID), update); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		update.ParentID = &upper[3].ID
+		if w := doJSON(router, "PUT", fmt.Sprintf("/types/%d", update.ID), update); w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusNoContent, w.Code, w.Body)
+		}
+		if w := doJSON(router, "GET", fmt.Sprintf("/types/%d/effective", lower[3].ID), nil); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d: %s", http.StatusOK, w.Code, w.Body)
+		}
+	})
+
+	t.Run("ListsExtendingTypes", func(t *testing.T) {
+		var usages model.Usages
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/types/%d/usages", email.ID), nil).Body).Decode(&usages)
+		if len(usages.Types) != 1 || usages.Types[0].ID != corporateEmail.ID {
+			t.Fatalf("expected type %d to extend it but got %+v", corporateEmail.ID, usages.Types)
+		}
+		if w := doJSON(router, "DELETE", fmt.Sprintf("/types/%d", email.ID), nil); w.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d but got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	workEmail := model.Attribute{Namespace: ns, Family: "person", Name: "work_email", Label: "Work email",
+		TypeID: corporateEmail.ID, DesignSpec: `{"placeholder": 5}`, Validations: []model.Validation{required}}
+	t.Run("ChecksInheritedSchema", func(t *testing.T) {
+		if w := doJSON(router, "POST", "/attributes", workEmail); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d but got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	workEmail.DesignSpec = `{"icon": "briefcase"}`
+	create("/attributes", &workEmail)
+	form := model.Form{Namespace: ns, Family: "person", Name: "contact", ActionName: "submit",
+		Attributes: []model.Attribute{{ID: workEmail.ID}}}
+	create("/forms", &form)
+
+	t.Run("ResolvesInheritance", func(t *testing.T) {
+		var resolved model.ResolvedForm
+		json.NewDecoder(doJSON(router, "GET", fmt.Sprintf("/forms/%d/resolved", form.ID), nil).Body).Decode(&resolved)
+		a := resolved.Pages[0].Attributes[0]
+		if a.ElementType != "text" || a.WidgetType != "email" {
+			t.Fatalf("expected the inherited element and widget but got %q and %q", a.ElementType, a.WidgetType)
+		}
+		var spec map[string]interface{}
+		json.Unmarshal(a.DesignSpec, &spec)
+		if spec["icon"] != "briefcase" || spec["placeholder"] != "name@example.com" {
+			t.Fatalf("unexpected design spec %v", spec)
+		}
+		var names []string
+		for _, v := range a.Validations {
+			names = append(names, v.Name)
+		}
+		if want := []string{"email", "corporate", "max_100", "required"}; !reflect.DeepEqual(names, want) {
+			t.Fatalf("expected validations %v but got %v", want, names)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// metrics/entities.go
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// EntityCounter counts live catalog entities per namespace.
+type EntityCounter interface {
+	CountLive(ctx context.Context) ([]model.NamespaceCount, error)
+}
+
+// EntityCollector reports live entities per resource and namespace, queried
+// afresh on each scrape so the gauge never drifts from the database.
+type EntityCollector struct {
+	counter EntityCounter
+	timeout time.Duration
+	logger  *slog.Logger
+	desc    *prometheus.Desc
+}
+
+func NewEntityCollector(counter EntityCounter, logger *slog.Logger) *EntityCollector {
+	return &EntityCollector{
+		counter: counter,
+		timeout: 5 * time.Second,
+		logger:  logger,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "live_entities"),
+			"Live (not deleted) entities by resource and namespace",
+			[]string{"resource", "namespace"}, nil),
+	}
+}
+
+func (c *EntityCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *EntityCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	counts, err := c.counter.CountLive(ctx)
+	if err != nil {
+		c.logger.Error("error collecting entity metrics", slog.Any("error", err))
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for _, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n.Count), n.Resource, n.Namespace)
+	}
+}
@@ -0,0 +1,118 @@
+// metrics/metrics.go
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+const namespace = "public_config"
+
+var (
+	dbQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Duration of database queries by operation and table",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"operation", "table", "status"},
+	)
+
+	cacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Cache lookups by cache and result (hit or miss)",
+		},
+		[]string{"cache", "result"},
+	)
+
+	// Publishes counts form versions published, by operation. Forms have no
+	// separate publish step: each create, update, clone or import of a form
+	// that commits publishes a new version of it.
+	Publishes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publishes_total",
+			Help:      "Form versions published, by operation",
+		},
+		[]string{"operation"},
+	)
+
+	// Imports counts entities imported, by resource and result.
+	Imports = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "imports_total",
+			Help:      "Entities imported, by resource and result",
+		},
+		[]string{"resource", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(dbQueryDuration, cacheRequests, Publishes, Imports)
+}
+
+// CacheHit records a lookup in the named cache that was served from it.
+func CacheHit(cache string) {
+	cacheRequests.WithLabelValues(cache, "hit").Inc()
+}
+
+// CacheMiss records a lookup in the named cache that had to go to the source.
+func CacheMiss(cache string) {
+	cacheRequests.WithLabelValues(cache, "miss").Inc()
+}
+
+// GormPlugin times every GORM statement into db_query_duration_seconds.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string { return "metrics" }
+
+const startKey = "metrics:start"
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	before := func(db *gorm.DB) { db.InstanceSet(startKey, time.Now()) }
+	after := func(operation string) func(*gorm.DB) {
+		return func(db *gorm.DB) {
+			v, ok := db.InstanceGet(startKey)
+			if !ok {
+				return
+			}
+			status := "ok"
+			if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+				status = "error"
+			}
+			table := db.Statement.Table
+			if table == "" {
+				table = "raw"
+			}
+			dbQueryDuration.WithLabelValues(operation, table, status).Observe(time.Since(v.(time.Time)).Seconds())
+		}
+	}
+
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
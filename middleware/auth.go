@@ -0,0 +1,77 @@
+// middleware/auth.go
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/exp/slog"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+)
+
+// APIKeyHeader carries an API key for clients that cannot set Authorization.
+const APIKeyHeader = "X-API-Key"
+
+// Authenticator resolves credentials to a principal.
+type Authenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error)
+	AuthenticateToken(ctx context.Context, token string) (*auth.Principal, error)
+	AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*auth.Principal, error)
+}
+
+// AuthMiddleware authenticates every request with an API key, sent as
+// "Authorization: Bearer pcs_..." or in X-API-Key, with a JWT bearer token, or
+// failing both with a client certificate verified during the TLS handshake.
+// The principal is stored in the request context for handlers to authorize
+// against; requests without valid credentials get 401.
+func AuthMiddleware(authenticator Authenticator, logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := authenticate(r, authenticator)
+			if err != nil {
+				if !errors.Is(err, auth.ErrNoCredentials) && !errors.Is(err, auth.ErrInvalidCredentials) {
+					logger.ErrorContext(r.Context(), "error authenticating request", slog.Any("error", err))
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", `Bearer realm="public-config-service"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			recordPrincipal(r.Context(), p.Subject)
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+func authenticate(r *http.Request, authenticator Authenticator) (*auth.Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return authenticator.AuthenticateAPIKey(r.Context(), key)
+	}
+	scheme, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || credentials == "" {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			return authenticator.AuthenticateCertificate(r.Context(), r.TLS.VerifiedChains[0][0])
+		}
+		return nil, auth.ErrNoCredentials
+	}
+	if auth.IsAPIKey(credentials) {
+		return authenticator.AuthenticateAPIKey(r.Context(), credentials)
+	}
+	return authenticator.AuthenticateToken(r.Context(), credentials)
+}
+
+// AnonymousMiddleware stands in for AuthMiddleware when authentication is
+// disabled: every request runs as auth.Anonymous.
+func AnonymousMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := auth.Anonymous()
+		recordPrincipal(r.Context(), p.Subject)
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
@@ -0,0 +1,163 @@
+// middleware/compress.go
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// CompressionOptions configures Compress. Its fields mirror
+// config.CompressionConfig.
+type CompressionOptions struct {
+	Enabled bool
+	// MinSize is the smallest response body, in bytes, worth compressing.
+	MinSize int
+	// Level is the gzip and brotli level; zero uses each one's default.
+	Level int
+}
+
+// Compress encodes responses with brotli or gzip, whichever the client
+// prefers, once they reach MinSize bytes. Responses that already carry a
+// Content-Encoding are left alone.
+func Compress(opts CompressionOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !opts.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, opts: opts, status: http.StatusOK}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks br or gzip from an Accept-Encoding header,
+// preferring the higher q-value and br on a tie. An encoding with q=0 is
+// refused and never picked.
+func negotiateEncoding(accept string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "br" && name != "gzip" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "br") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the body until it reaches MinSize, then decides
+// whether to compress, so small responses go out unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	opts     CompressionOptions
+	status   int
+	buf      []byte
+	started  bool
+	enc      io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if !cw.started {
+		cw.status = code
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.started {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.opts.MinSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+	h := cw.Header()
+	if compress && h.Get("Content-Encoding") == "" && cw.status != http.StatusNoContent && cw.status != http.StatusNotModified {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		cw.enc = cw.newEncoder()
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) newEncoder() io.WriteCloser {
+	if cw.encoding == "br" {
+		level := brotli.DefaultCompression
+		if cw.opts.Level > 0 {
+			level = cw.opts.Level
+		}
+		return brotli.NewWriterLevel(cw.ResponseWriter, level)
+	}
+	level := gzip.DefaultCompression
+	if cw.opts.Level > 0 {
+		level = cw.opts.Level
+	}
+	enc, err := gzip.NewWriterLevel(cw.ResponseWriter, level)
+	if err != nil {
+		enc = gzip.NewWriter(cw.ResponseWriter)
+	}
+	return enc
+}
+
+// Close sends a body that never reached MinSize uncompressed and flushes the
+// encoder.
+func (cw *compressWriter) Close() error {
+	if !cw.started {
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+	if cw.enc != nil {
+		return cw.enc.Close()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// middleware/cors.go
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOptions configures CORS. Its fields mirror config.CORSConfig so one
+// converts to the other.
+type CORSOptions struct {
+	Enabled bool
+	// AllowedOrigins lists origins allowed to call the API; "*" allows any.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how many seconds browsers may cache a preflight result.
+	MaxAge int
+}
+
+// CORS answers preflight requests and adds CORS headers to responses for
+// allowed origins. Requests from other origins pass through without them, so
+// browsers block the response.
+func CORS(opts CORSOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !opts.Enabled {
+			return next
+		}
+		methods := strings.Join(opts.AllowedMethods, ", ")
+		headers := strings.Join(opts.AllowedHeaders, ", ")
+		exposed := strings.Join(opts.ExposedHeaders, ", ")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !opts.originAllowed(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if opts.AllowCredentials || !opts.anyOrigin() {
+				h.Set("Access-Control-Allow-Origin", origin)
+			} else {
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if exposed != "" {
+					h.Set("Access-Control-Expose-Headers", exposed)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			if opts.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(opts.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+func (o CORSOptions) anyOrigin() bool {
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CORSOptions) originAllowed(origin string) bool {
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
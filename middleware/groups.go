@@ -0,0 +1,47 @@
+// middleware/groups.go
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ByPathPrefix sends each request through the middleware registered for the
+// longest prefix of its path, or through def when none matches.
+func ByPathPrefix(def func(http.Handler) http.Handler, prefixes map[string]func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		defHandler := def(next)
+		handlers := make(map[string]http.Handler, len(prefixes))
+		for prefix, mw := range prefixes {
+			handlers[prefix] = mw(next)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h, longest := defHandler, -1
+			for prefix, ph := range handlers {
+				if len(prefix) > longest && hasPathPrefix(r.URL.Path, prefix) {
+					h, longest = ph, len(prefix)
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	return len(path) >= len(prefix) && path[:len(prefix)] == prefix &&
+		(len(path) == len(prefix) || prefix[len(prefix)-1] == '/' || path[len(prefix)] == '/')
+}
+
+// Swappable serves every request through the most recently stored handler,
+// letting a config reload replace middleware without restarting the server.
+type Swappable struct {
+	current atomic.Pointer[http.Handler]
+}
+
+func (s *Swappable) Store(h http.Handler) {
+	s.current.Store(&h)
+}
+
+func (s *Swappable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*s.current.Load()).ServeHTTP(w, r)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -32,17 +33,31 @@ func init() {
 	prometheus.MustRegister(httpRequestDuration)
 }
 
+// MetricsMiddleware counts and times requests, labelled by the matched route
+// template (e.g. /api/v1/forms/{id}) so IDs don't create new series.
 func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		timer := prometheus.NewTimer(httpRequestDuration.WithLabelValues(r.Method, r.URL.Path))
+		endpoint := routeTemplate(r)
+		timer := prometheus.NewTimer(httpRequestDuration.WithLabelValues(r.Method, endpoint))
 		defer timer.ObserveDuration()
 
-		rr := &responseRecorder{w, http.StatusOK}
+		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
 		next.ServeHTTP(rr, r)
 		httpRequestsTotal.With(prometheus.Labels{
 			"method":      r.Method,
-			"endpoint":    r.URL.Path,
+			"endpoint":    endpoint,
 			"status_code": strconv.Itoa(rr.statusCode),
 		}).Inc()
 	})
 }
+
+// routeTemplate returns the path template of the route mux matched, or
+// "unmatched" so arbitrary paths can't grow the label set.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
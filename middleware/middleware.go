@@ -2,33 +2,64 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/opentracing/opentracing-go"
-	"github.com/opentracing/opentracing-go/ext"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/requestid"
 )
 
-// LoggingMiddleware logs the details of each request
+const tracerName = "stellarsky.ai/platform/public-config-service/middleware"
+
+// LoggingMiddleware writes an access log record for each request with its
+// status, response size, client address and authenticated principal. The
+// request ID is added by the logger's handler from the context.
 func LoggingMiddleware(logger *slog.Logger) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			next.ServeHTTP(w, r)
-			logger.Info("request completed",
+			entry := &accessEntry{}
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, entry)))
+			logger.InfoContext(r.Context(), "request completed",
 				slog.String("method", r.Method),
 				slog.String("uri", r.RequestURI),
+				slog.Int("status", rec.statusCode),
+				slog.Int64("bytes", rec.bytes),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("principal", entry.principal),
 				slog.Duration("duration", time.Since(start)))
 		})
 	}
 }
 
+// accessEntry collects details that only inner middleware learns, such as the
+// principal, for LoggingMiddleware to log once the request is done.
+type accessEntry struct {
+	principal string
+}
+
+type accessEntryKey struct{}
+
+// recordPrincipal notes the authenticated subject for the access log.
+func recordPrincipal(ctx context.Context, subject string) {
+	if entry, ok := ctx.Value(accessEntryKey{}).(*accessEntry); ok {
+		entry.principal = subject
+	}
+}
+
 type responseRecorder struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 func (rec *responseRecorder) WriteHeader(code int) {
@@ -36,22 +67,36 @@ func (rec *responseRecorder) WriteHeader(code int) {
 	rec.ResponseWriter.WriteHeader(code)
 }
 
-// TracingMiddleware adds OpenTracing spans to each request
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// TracingMiddleware starts a server span for each request, continuing any
+// W3C trace context sent by the caller, and records the response status.
 func TracingMiddleware(next http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		span, ctx := opentracing.StartSpanFromContext(r.Context(), "HTTP "+r.Method+" "+r.URL.Path)
-		defer span.Finish()
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
 
-		ext.HTTPMethod.Set(span, r.Method)
-		ext.HTTPUrl.Set(span, r.URL.String())
+		route := routeTemplate(r)
+		ctx, span := tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request.id", requestid.FromContext(ctx)),
+			))
+		defer span.End()
 
-		r = r.WithContext(ctx)
-		next.ServeHTTP(w, r)
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
 
-		statusCode, err := strconv.Atoi(w.Header().Get("Status-Code"))
-		if err != nil {
-			statusCode = 0
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.statusCode))
+		if rec.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.statusCode))
 		}
-		ext.HTTPStatusCode.Set(span, uint16(statusCode))
 	})
 }
@@ -0,0 +1,157 @@
+// middleware/ratelimit.go
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/ratelimit"
+)
+
+// Sources of the per-client rate limit key.
+const (
+	// RateLimitByCredential keys on the API key, or the JWT subject, and falls
+	// back to the client IP for anonymous requests.
+	RateLimitByCredential = "credential"
+	// RateLimitByIP keys on the client IP only.
+	RateLimitByIP = "ip"
+)
+
+// RateLimitKeys chooses the key requests are charged to, one of the
+// RateLimitBy constants. TrustedProxies is the number of proxies in front of
+// the service that append to X-Forwarded-For; the client IP is the entry that
+// many hops from the right, the one the outermost proxy saw. Entries further
+// left are set by the client and ignored. With zero the header is ignored.
+type RateLimitKeys struct {
+	KeyBy          string
+	TrustedProxies int
+}
+
+// RateLimitKeying holds the RateLimitKeys in use, which may be replaced while
+// requests are served, such as after a configuration reload.
+type RateLimitKeying struct {
+	keys atomic.Pointer[RateLimitKeys]
+}
+
+func NewRateLimitKeying(keys RateLimitKeys) *RateLimitKeying {
+	k := &RateLimitKeying{}
+	k.Set(keys)
+	return k
+}
+
+// Set replaces the keys for the requests that follow.
+func (k *RateLimitKeying) Set(keys RateLimitKeys) {
+	k.keys.Store(&keys)
+}
+
+// RateLimitMiddleware charges each request to its client's read or write
+// budget and rejects it with 429 when the budget is spent. It must run after
+// authentication. When namespaces is not nil it also charges, before the
+// handler queries anything, the namespaces the request is scoped to, and
+// handlers charge each further namespace they touch through
+// ratelimit.AllowNamespace.
+func RateLimitMiddleware(clients, namespaces *ratelimit.Limiter, keying *RateLimitKeying) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			class := ratelimit.ClassOf(r)
+			keys := keying.keys.Load()
+			d := clients.Allow(clientKey(r, keys.KeyBy, keys.TrustedProxies), class)
+			if !d.Allowed {
+				ratelimit.Reject(w, d)
+				return
+			}
+			ratelimit.WriteHeaders(w, d)
+			if namespaces != nil {
+				r = r.WithContext(ratelimit.WithNamespaceLimiter(r.Context(), namespaces, class))
+				if d := allowScope(r); !d.Allowed {
+					ratelimit.Reject(w, d)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowScope charges the namespace budgets of the namespaces a request is
+// scoped to: those named by namespace query parameters that the principal may
+// view, or else every namespace the principal holds a role in. A principal
+// with a role in every namespace has no scope until the handler finds the
+// namespace of what it reads or writes.
+func allowScope(r *http.Request) ratelimit.Decision {
+	p := auth.PrincipalFromContext(r.Context())
+	var scope []string
+	for _, namespace := range r.URL.Query()["namespace"] {
+		if p.Can(namespace, auth.RoleViewer) {
+			scope = append(scope, namespace)
+		}
+	}
+	if len(scope) == 0 {
+		scope, _ = p.Namespaces(auth.RoleViewer)
+	}
+	for _, namespace := range scope {
+		if d := ratelimit.AllowNamespace(r.Context(), namespace); !d.Allowed {
+			return d
+		}
+	}
+	return ratelimit.Decision{Allowed: true}
+}
+
+// IPRateLimitMiddleware charges each request to its client IP's read or write
+// budget and rejects it with 429 when the budget is spent. It runs before
+// authentication, so guessing credentials is limited and a rejected request
+// costs no credential lookup.
+func IPRateLimitMiddleware(ips *ratelimit.Limiter, keying *RateLimitKeying) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := keying.keys.Load()
+			if d := ips.Allow("ip:"+clientIP(r, keys.TrustedProxies), ratelimit.ClassOf(r)); !d.Allowed {
+				ratelimit.Reject(w, d)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request, keyBy string, trustedProxies int) string {
+	if keyBy == RateLimitByCredential {
+		switch p := auth.PrincipalFromContext(r.Context()); {
+		case p == nil:
+		case p.Method == "api_key":
+			return "api_key:" + strconv.FormatUint(p.KeyID, 10)
+		case p.Method != "anonymous":
+			return p.Method + ":" + p.Subject
+		}
+	}
+	return "ip:" + clientIP(r, trustedProxies)
+}
+
+func clientIP(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		var hops []string
+		for _, forwarded := range r.Header.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(forwarded, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			// With fewer entries than proxies every entry was appended by a
+			// trusted proxy, and the leftmost is the client.
+			return hops[max(len(hops)-trustedProxies, 0)]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
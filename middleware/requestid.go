@@ -0,0 +1,37 @@
+// middleware/requestid.go
+package middleware
+
+import (
+	"net/http"
+
+	"stellarsky.ai/platform/public-config-service/requestid"
+)
+
+// maxRequestIDLength bounds the IDs accepted from callers so they cannot be
+// used to bloat logs and audit records.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware propagates the caller's X-Request-ID, or generates one,
+// stores it in the request context and echoes it in the response.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !validRequestID(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.WithRequestID(r.Context(), id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
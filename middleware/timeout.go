@@ -0,0 +1,45 @@
+// middleware/timeout.go
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestTimeoutHeader lets a caller choose its own deadline, for example
+// "X-Request-Timeout: 500ms". Values above the configured maximum are capped.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// TimeoutMiddleware bounds each request's context with a deadline. Services and
+// repositories run their queries on that context, so when the deadline passes
+// or the client disconnects the query is cancelled on the database as well.
+// A zero defaultTimeout leaves requests without a header unbounded.
+func TimeoutMiddleware(defaultTimeout, maxTimeout time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := defaultTimeout
+			if v := r.Header.Get(RequestTimeoutHeader); v != "" {
+				d, err := time.ParseDuration(v)
+				if err != nil || d <= 0 {
+					http.Error(w, "Bad Request", http.StatusBadRequest)
+					return
+				}
+				timeout = d
+			}
+			if maxTimeout > 0 && (timeout <= 0 || timeout > maxTimeout) {
+				timeout = maxTimeout
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one mutation of a catalog entity. Entries are only ever
+// inserted; the table rejects updates and deletes.
+type AuditEntry struct {
+	ID         uint64 `gorm:"primaryKey"`
+	Actor      string `gorm:"index"`
+	RequestID  string
+	Action     string // create, update, delete or restore
+	Resource   string `gorm:"index"` // type, validation, attribute or form
+	ResourceID uint64
+	Namespace  string `gorm:"index"`
+	Family     string
+	Name       string
+	Before     json.RawMessage `gorm:"type:jsonb"`
+	After      json.RawMessage `gorm:"type:jsonb"`
+	Changes    json.RawMessage `gorm:"type:jsonb"` // {"Field": {"before": ..., "after": ...}}
+	CreatedAt  time.Time       `gorm:"autoCreateTime:milli;index"`
+}
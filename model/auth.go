@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey identifies a client by the hash of a secret key. The key itself is
+// returned once, when the APIKey is created, and never stored.
+type APIKey struct {
+	ID        uint64 `gorm:"primaryKey"`
+	Name      string
+	Principal string         `gorm:"index"`
+	Prefix    string         // first characters of the key, to help identify it
+	KeyHash   string         `gorm:"uniqueIndex" json:"-"`
+	ExpiresAt *time.Time     `json:",omitempty"`
+	CreatedAt time.Time      `gorm:"autoCreateTime:milli"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime:milli"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// RoleBinding grants a principal a role within one namespace, or within every
+// namespace when Namespace is "*".
+type RoleBinding struct {
+	ID        uint64 `gorm:"primaryKey"`
+	Principal string `gorm:"uniqueIndex:idx_principal_namespace"`
+	Namespace string `gorm:"uniqueIndex:idx_principal_namespace"`
+	Role      string
+	CreatedAt time.Time      `gorm:"autoCreateTime:milli"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime:milli"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
@@ -0,0 +1,53 @@
+package model
+
+// BatchOp is the kind of change a batch operation makes.
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// BatchOperation is one change in a batch. Create takes Item, update takes ID
+// and Item, and delete takes ID.
+type BatchOperation[T any] struct {
+	Op   BatchOp
+	ID   uint64
+	Item *T
+}
+
+type BatchRequest[T any] struct {
+	Operations []BatchOperation[T]
+}
+
+// BatchStatus is the outcome of one operation in a batch.
+type BatchStatus string
+
+const (
+	BatchCreated BatchStatus = "created"
+	BatchUpdated BatchStatus = "updated"
+	BatchDeleted BatchStatus = "deleted"
+	// BatchFailed marks the operation that aborted the batch.
+	BatchFailed BatchStatus = "failed"
+	// BatchRolledBack marks an operation that succeeded before the batch was
+	// aborted and so has no effect.
+	BatchRolledBack BatchStatus = "rolled_back"
+	// BatchSkipped marks an operation that was not attempted.
+	BatchSkipped BatchStatus = "skipped"
+)
+
+type BatchResult struct {
+	Index  int
+	Op     BatchOp
+	ID     uint64
+	Status BatchStatus
+	Error  string `json:",omitempty"`
+}
+
+// BatchResponse reports the outcome of every operation in a batch, in order.
+// Error is set when the batch was aborted.
+type BatchResponse struct {
+	Error   string `json:",omitempty"`
+	Results []BatchResult
+}
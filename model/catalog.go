@@ -0,0 +1,36 @@
+// model/catalog.go
+package model
+
+import "time"
+
+// CatalogFormatVersion is the version of the Catalog document written by
+// export and accepted by import.
+const CatalogFormatVersion = 1
+
+// Catalog is a portable snapshot of live types, validations, option sets,
+// attributes and forms. Entities refer to each other by namespace, family and
+// name, so a catalog can be imported into a database whose IDs differ: the
+// nested Type, OptionSet and Validations of an attribute, and the Attributes
+// of a form, only need those three fields.
+type Catalog struct {
+	Version     int          `json:"version"`
+	ExportedAt  time.Time    `json:"exported_at"`
+	Types       []Type       `json:"types"`
+	Validations []Validation `json:"validations"`
+	OptionSets  []OptionSet  `json:"option_sets,omitempty"`
+	Attributes  []Attribute  `json:"attributes"`
+	Forms       []Form       `json:"forms"`
+}
+
+// ImportCounts tallies what an import did with one resource.
+type ImportCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// ImportReport is the outcome of importing a Catalog, keyed by resource.
+type ImportReport struct {
+	DryRun    bool                    `json:"dry_run"`
+	Resources map[string]ImportCounts `json:"resources"`
+}
@@ -0,0 +1,41 @@
+package model
+
+// Strategies for a clone whose target namespace, family and name are already
+// held by a live entity.
+const (
+	// CloneFail aborts the clone.
+	CloneFail = "fail"
+	// CloneSuffix appends _copy, then _copy_2 and so on, to the name until it
+	// is free.
+	CloneSuffix = "suffix"
+	// CloneReuse uses the live entity instead of making a copy, and leaves it
+	// unchanged. A reused form keeps its own attributes, pages, groups and
+	// conditions, and the source's attributes are not copied.
+	CloneReuse = "reuse"
+)
+
+// CloneRequest says where to clone a form. Empty Namespace, Family and Name
+// keep the source's.
+type CloneRequest struct {
+	Namespace string
+	Family    string
+	Name      string
+	// DeepCopy copies the form's attributes into Namespace, keeping their
+	// families, names, types and validation bindings. Otherwise the clone
+	// refers to the source's attributes.
+	DeepCopy bool
+	// OnConflict is CloneFail, CloneSuffix or CloneReuse; empty means
+	// CloneFail. It applies to the form and to each copied attribute.
+	OnConflict string
+}
+
+// CloneResult maps the IDs of the source form and its attributes to those of
+// the clone. When the clone reused a live form, Reused is set and each source
+// attribute maps to the reused form's attribute of the same family and name,
+// if it has one.
+type CloneResult struct {
+	Form       *Form
+	Forms      map[uint64]uint64
+	Attributes map[uint64]uint64
+	Reused     bool
+}
@@ -0,0 +1,28 @@
+package model
+
+import "database/sql/driver"
+
+// AttributeConditions makes one of a form's attributes depend on the answers
+// to its siblings. Each condition is an expression in the language of package
+// expr.
+type AttributeConditions struct {
+	// VisibleIf hides the attribute, and skips its validations, unless it
+	// holds.
+	VisibleIf string `json:",omitempty"`
+	// RequiredIf makes the attribute required when it holds.
+	RequiredIf string `json:",omitempty"`
+}
+
+// Conditions maps attribute names to their conditions, stored as JSON.
+type Conditions map[string]AttributeConditions
+
+func (c Conditions) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return jsonValue(c)
+}
+
+func (c *Conditions) Scan(src interface{}) error {
+	return scanJSON(src, c)
+}
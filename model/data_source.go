@@ -0,0 +1,40 @@
+package model
+
+import "database/sql/driver"
+
+// DataSource fetches the options of a choice attribute from another system
+// instead of an option set.
+type DataSource struct {
+	// URL is an http or https endpoint template. Each {name} in it is replaced
+	// with the escaped answer to the attribute called name, which must be
+	// listed in DependsOn.
+	URL string
+	// DependsOn names the attributes whose answers the options depend on,
+	// such as country for a list of cities. Clients fetch the options again
+	// when one of them changes.
+	DependsOn []string `json:",omitempty"`
+	Mapping   ResponseMapping
+	// CacheTTL is how long responses are reused, as a duration such as "10m".
+	// Empty uses the service's default and "0s" disables caching.
+	CacheTTL string `json:",omitempty"`
+}
+
+// ResponseMapping turns a JSON response into options: Items locates the list
+// of items in it and Value, Label and Group name the fields of each item.
+type ResponseMapping struct {
+	// Items is the dotted path to the list, such as "data.cities"; empty when
+	// the response is the list itself.
+	Items string `json:",omitempty"`
+	Value string
+	// Label defaults to Value.
+	Label string `json:",omitempty"`
+	Group string `json:",omitempty"`
+}
+
+func (d DataSource) Value() (driver.Value, error) {
+	return jsonValue(d)
+}
+
+func (d *DataSource) Scan(src interface{}) error {
+	return scanJSON(src, d)
+}
@@ -0,0 +1,17 @@
+package model
+
+// EffectiveType is a type with what it inherits from the types it extends
+// merged in: the element type, widget, design and validations its attributes
+// get. Ancestors lists those types, parent first.
+type EffectiveType struct {
+	ID             uint64
+	Namespace      string
+	Family         string
+	Name           string
+	Ancestors      []UsageRef
+	ElementType    string
+	WidgetType     string
+	DesignSchema   Document
+	DesignDefaults Document
+	Validations    []Validation
+}
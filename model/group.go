@@ -0,0 +1,30 @@
+package model
+
+import "database/sql/driver"
+
+// Group is a repeatable set of a form's attributes, such as one entry of an
+// address list. Its answers are a list with one object per occurrence, keyed
+// by attribute name. Pages place a group by its name.
+type Group struct {
+	Name       string
+	Title      string
+	Attributes []string
+	// MinOccurs and MaxOccurs bound the number of occurrences; a MaxOccurs of
+	// zero leaves it unbounded.
+	MinOccurs int
+	MaxOccurs int
+}
+
+// Groups is a form's repeatable groups, stored as JSON.
+type Groups []Group
+
+func (g Groups) Value() (driver.Value, error) {
+	if g == nil {
+		return nil, nil
+	}
+	return jsonValue(g)
+}
+
+func (g *Groups) Scan(src interface{}) error {
+	return scanJSON(src, g)
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonValue stores v in a json column.
+func jsonValue(v interface{}) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// scanJSON reads a json column into dst, leaving it zero for NULL.
+func scanJSON(src, dst interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dst)
+	}
+}
+
+// Document is a JSON document kept as written, inline in API bodies and in a
+// json column. An empty Document is stored as NULL and encoded as null.
+type Document []byte
+
+func (d Document) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+func (d *Document) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*d = nil
+		return nil
+	}
+	*d = append((*d)[:0], b...)
+	return nil
+}
+
+func (d Document) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	return string(d), nil
+}
+
+func (d *Document) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = nil
+	case []byte:
+		*d = append((*d)[:0], v...)
+	case string:
+		*d = Document(v)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, d)
+	}
+	return nil
+}
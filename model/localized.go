@@ -0,0 +1,59 @@
+package model
+
+import "database/sql/driver"
+
+// Localized holds translations of one text keyed by locale, such as "fr" or
+// "pt-BR".
+type Localized map[string]string
+
+func (l Localized) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return jsonValue(l)
+}
+
+func (l *Localized) Scan(src interface{}) error {
+	return scanJSON(src, l)
+}
+
+// AttributeText is an attribute's label and help text in one locale. Empty
+// fields fall back like missing translations.
+type AttributeText struct {
+	Label    string `json:",omitempty"`
+	HelpText string `json:",omitempty"`
+}
+
+// AttributeTranslations holds an attribute's texts keyed by locale.
+type AttributeTranslations map[string]AttributeText
+
+func (t AttributeTranslations) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	return jsonValue(t)
+}
+
+func (t *AttributeTranslations) Scan(src interface{}) error {
+	return scanJSON(src, t)
+}
+
+// TranslationReport lists the texts of a form that readers in Locale see
+// untranslated: those with no translation into Locale or a locale it falls
+// back to. Total counts the texts that need one.
+type TranslationReport struct {
+	Locale     string
+	Total      int
+	Translated int
+	Missing    []MissingTranslation
+}
+
+// MissingTranslation names one untranslated text: the label or help text of
+// an attribute, the label of one of its options, or the message of one of
+// its validations.
+type MissingTranslation struct {
+	Attribute  string
+	Text       string
+	Option     string `json:",omitempty"`
+	Validation string `json:",omitempty"`
+}
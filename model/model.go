@@ -8,53 +8,90 @@ import (
 
 type Type struct {
 	ID          uint64 `gorm:"primaryKey"`
-	Namespace   string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Family      string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Name        string `gorm:"uniqueIndex:idx_namespace_family_name"`
+	Namespace   string `gorm:"uniqueIndex:idx_types_namespace_family_name,where:deleted_at IS NULL"`
+	Family      string `gorm:"uniqueIndex:idx_types_namespace_family_name,where:deleted_at IS NULL"`
+	Name        string `gorm:"uniqueIndex:idx_types_namespace_family_name,where:deleted_at IS NULL"`
 	ElementType string
 	WidgetType  string
-	CreatedAt   time.Time      `gorm:"autoCreateTime:milli"`
-	UpdatedAt   time.Time      `gorm:"autoUpdateTime:milli"`
-	DeletedAt   gorm.DeletedAt `gorm:"index"`
-	Version     int            `gorm:"default:1"`
+	// ParentID names the type this one extends. Parent is only read when
+	// importing a catalog, which refers to it by natural key.
+	ParentID *uint64
+	Parent   *Type `json:",omitempty"`
+	// DesignSchema is the JSON Schema the design specs of the type's
+	// attributes must conform to, after DesignDefaults are merged under them.
+	DesignSchema   Document       `gorm:"type:json"`
+	DesignDefaults Document       `gorm:"type:json"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime:milli"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime:milli"`
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+	Version        int            `gorm:"default:1"`
+	// Validations apply to every attribute of the type and of the types
+	// extending it.
+	Validations []Validation `gorm:"many2many:type_validations;"`
 }
 
 type Validation struct {
 	ID               uint64 `gorm:"primaryKey"`
-	Namespace        string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Family           string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Name             string `gorm:"uniqueIndex:idx_namespace_family_name"`
+	Namespace        string `gorm:"uniqueIndex:idx_validations_namespace_family_name,where:deleted_at IS NULL"`
+	Family           string `gorm:"uniqueIndex:idx_validations_namespace_family_name,where:deleted_at IS NULL"`
+	Name             string `gorm:"uniqueIndex:idx_validations_namespace_family_name,where:deleted_at IS NULL"`
 	RuleName         string
 	ValidationParams string
-	CreatedAt        time.Time      `gorm:"autoCreateTime:milli"`
-	UpdatedAt        time.Time      `gorm:"autoUpdateTime:milli"`
-	DeletedAt        gorm.DeletedAt `gorm:"index"`
-	Version          int            `gorm:"default:1"`
+	// Messages replace the message of a broken rule, keyed by locale.
+	Messages  Localized      `gorm:"type:json"`
+	CreatedAt time.Time      `gorm:"autoCreateTime:milli"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime:milli"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Version   int            `gorm:"default:1"`
 	// Attributes       []Attribute    `gorm:"many2many:attribute_validations;"`
 }
 
 type Attribute struct {
-	ID          uint64 `gorm:"primaryKey"`
-	Namespace   string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Family      string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Name        string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Label       string
-	DesignSpec  string `gorm:"type:json"`
-	TypeID      uint64
+	ID        uint64 `gorm:"primaryKey"`
+	Namespace string `gorm:"uniqueIndex:idx_attributes_namespace_family_name,where:deleted_at IS NULL"`
+	Family    string `gorm:"uniqueIndex:idx_attributes_namespace_family_name,where:deleted_at IS NULL"`
+	Name      string `gorm:"uniqueIndex:idx_attributes_namespace_family_name,where:deleted_at IS NULL"`
+	Label     string
+	HelpText  string
+	// Translations holds Label and HelpText in other locales.
+	Translations AttributeTranslations `gorm:"type:json"`
+	DesignSpec   string                `gorm:"type:json"`
+	TypeID       uint64
+	// OptionSetID names the options of a choice attribute, if any. OptionSet
+	// is only read; option sets are created on their own.
+	OptionSetID *uint64
+	// DataSource fetches the options of a choice attribute instead.
+	DataSource  *DataSource    `gorm:"type:json"`
 	CreatedAt   time.Time      `gorm:"autoCreateTime:milli"`
 	UpdatedAt   time.Time      `gorm:"autoUpdateTime:milli"`
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
 	Version     int            `gorm:"default:1"`
 	Type        Type
+	OptionSet   *OptionSet
 	Validations []Validation `gorm:"many2many:attribute_validations;"`
 }
 
+type OptionSet struct {
+	ID        uint64         `gorm:"primaryKey"`
+	Namespace string         `gorm:"uniqueIndex:idx_option_sets_namespace_family_name,where:deleted_at IS NULL"`
+	Family    string         `gorm:"uniqueIndex:idx_option_sets_namespace_family_name,where:deleted_at IS NULL"`
+	Name      string         `gorm:"uniqueIndex:idx_option_sets_namespace_family_name,where:deleted_at IS NULL"`
+	Options   Options        `gorm:"type:json"`
+	CreatedAt time.Time      `gorm:"autoCreateTime:milli"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime:milli"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Version   int            `gorm:"default:1"`
+}
+
 type Form struct {
 	ID         uint64 `gorm:"primaryKey"`
-	Namespace  string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Family     string `gorm:"uniqueIndex:idx_namespace_family_name"`
-	Name       string `gorm:"uniqueIndex:idx_namespace_family_name"`
+	Namespace  string `gorm:"uniqueIndex:idx_forms_namespace_family_name,where:deleted_at IS NULL"`
+	Family     string `gorm:"uniqueIndex:idx_forms_namespace_family_name,where:deleted_at IS NULL"`
+	Name       string `gorm:"uniqueIndex:idx_forms_namespace_family_name,where:deleted_at IS NULL"`
 	ActionName string
+	Pages      Pages          `gorm:"type:json"`
+	Conditions Conditions     `gorm:"type:json"`
+	Groups     Groups         `gorm:"type:json"`
 	CreatedAt  time.Time      `gorm:"autoCreateTime:milli"`
 	UpdatedAt  time.Time      `gorm:"autoUpdateTime:milli"`
 	DeletedAt  gorm.DeletedAt `gorm:"index"`
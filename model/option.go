@@ -0,0 +1,29 @@
+package model
+
+import "database/sql/driver"
+
+// Option is one of the answers a choice attribute offers. Group, when set,
+// clusters options under a heading. Disabled options are still listed, so that
+// stored answers keep their label, but can no longer be submitted. Labels
+// translates Label, keyed by locale.
+type Option struct {
+	Value    string
+	Label    string
+	Labels   Localized `json:",omitempty"`
+	Group    string    `json:",omitempty"`
+	Disabled bool      `json:",omitempty"`
+}
+
+// Options is an option set's options in display order, stored as JSON.
+type Options []Option
+
+func (o Options) Value() (driver.Value, error) {
+	if o == nil {
+		return nil, nil
+	}
+	return jsonValue(o)
+}
+
+func (o *Options) Scan(src interface{}) error {
+	return scanJSON(src, o)
+}
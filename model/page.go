@@ -0,0 +1,55 @@
+package model
+
+import "database/sql/driver"
+
+// Page is one step of a multi-page form.
+type Page struct {
+	Name  string
+	Title string
+	// Attributes lists the names of the form's attributes and groups shown
+	// on the page, in display order.
+	Attributes []string
+	// Navigation picks the page after this one: the first rule whose
+	// condition holds wins, and without one the next page in order follows.
+	Navigation []NavigationRule
+}
+
+// NavigationRule skips ahead to GoTo, a later page, when When holds.
+type NavigationRule struct {
+	When Condition
+	GoTo string
+}
+
+// Condition operators.
+const (
+	OpEquals    = "eq"
+	OpNotEquals = "ne"
+	OpIn        = "in"
+	OpNotIn     = "not_in"
+	OpSet       = "set"
+	OpUnset     = "unset"
+)
+
+// Condition tests the answer given for an attribute on the current or an
+// earlier page. Value is a single value for eq and ne, a list for in and
+// not_in, and unused for set and unset.
+type Condition struct {
+	Attribute string
+	Op        string
+	Value     interface{} `json:",omitempty"`
+}
+
+// Pages is a form's page layout, stored as JSON. A form without pages shows
+// all its attributes on one page.
+type Pages []Page
+
+func (p Pages) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return jsonValue(p)
+}
+
+func (p *Pages) Scan(src interface{}) error {
+	return scanJSON(src, p)
+}
@@ -0,0 +1,93 @@
+package model
+
+import "encoding/json"
+
+// ResolvedForm is a form as clients render it: its pages with every
+// attribute's type and validations inlined. Locales lists the locales its
+// texts were translated from, most preferred first, when one was asked for.
+type ResolvedForm struct {
+	ID         uint64
+	Namespace  string
+	Family     string
+	Name       string
+	ActionName string
+	Version    int
+	Locales    []string `json:",omitempty"`
+	Pages      []ResolvedPage
+}
+
+type ResolvedPage struct {
+	Name       string
+	Title      string
+	Attributes []ResolvedAttribute
+	Groups     []ResolvedGroup
+	// Order lists the names of the page's attributes and groups in display
+	// order.
+	Order      []string
+	Navigation []NavigationRule
+}
+
+type ResolvedGroup struct {
+	Name       string
+	Title      string
+	MinOccurs  int
+	MaxOccurs  int
+	Attributes []ResolvedAttribute
+}
+
+type ResolvedAttribute struct {
+	ID          uint64
+	Namespace   string
+	Family      string
+	Name        string
+	Label       string
+	HelpText    string `json:",omitempty"`
+	DesignSpec  json.RawMessage
+	ElementType string
+	WidgetType  string
+	// Options are those of the attribute's option set, if it has one. Those
+	// of a data source are served by the form's options endpoint instead.
+	Options     []Option    `json:",omitempty"`
+	DataSource  *DataSource `json:",omitempty"`
+	VisibleIf   string      `json:",omitempty"`
+	RequiredIf  string      `json:",omitempty"`
+	Validations []ResolvedValidation
+}
+
+// ResolvedValidation carries Message when the validation has one in the
+// form's locale; otherwise broken rules report the rule's own message.
+type ResolvedValidation struct {
+	Name     string
+	RuleName string
+	Params   json.RawMessage
+	Message  string `json:",omitempty"`
+}
+
+// Submission holds the answers to a form keyed by attribute name. With Page
+// set only that page is validated; otherwise every page on the path the
+// answers take through the form is.
+type Submission struct {
+	Page   string
+	Values map[string]interface{}
+}
+
+// FieldError reports an answer that breaks a validation rule. For an
+// attribute in a group, Occurrence is the index of the group's answer.
+type FieldError struct {
+	Page       string
+	Group      string `json:",omitempty"`
+	Occurrence *int   `json:",omitempty"`
+	Attribute  string
+	Rule       string
+	Message    string
+}
+
+// ValidationReport is the outcome of validating a submission. Pages lists the
+// pages validated, in order. NextPage is the page that follows Submission.Page,
+// empty after the last.
+type ValidationReport struct {
+	Valid    bool
+	Errors   []FieldError
+	Pages    []string
+	NextPage string `json:",omitempty"`
+}
@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+// SchemaMigration records that the schema was brought up to Version.
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time `gorm:"autoCreateTime:milli"`
+}
@@ -0,0 +1,9 @@
+// model/stats.go
+package model
+
+// NamespaceCount is the number of live entities of one resource in a namespace.
+type NamespaceCount struct {
+	Resource  string
+	Namespace string
+	Count     int64
+}
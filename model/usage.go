@@ -0,0 +1,23 @@
+package model
+
+// UsageRef identifies an entity that depends on another.
+type UsageRef struct {
+	ID        uint64
+	Namespace string
+	Family    string
+	Name      string
+}
+
+// Usages lists the live attributes and forms that depend on an entity, either
+// directly or through one of the listed attributes, and the live types that
+// extend it or apply it to their attributes.
+type Usages struct {
+	Types      []UsageRef `json:",omitempty"`
+	Attributes []UsageRef
+	Forms      []UsageRef
+}
+
+// Empty reports whether nothing depends on the entity.
+func (u *Usages) Empty() bool {
+	return len(u.Types) == 0 && len(u.Attributes) == 0 && len(u.Forms) == 0
+}
@@ -0,0 +1,72 @@
+// ratelimit/http.go
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ClassOf returns Read for safe methods and Write for everything else.
+func ClassOf(r *http.Request) Class {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return Read
+	default:
+		return Write
+	}
+}
+
+// WriteHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers from d, plus Retry-After when d was rejected.
+func WriteHeaders(w http.ResponseWriter, d Decision) {
+	if d.Limit == 0 {
+		return
+	}
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+	if !d.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(seconds(d.RetryAfter), 1)))
+	}
+}
+
+// Reject writes d's headers and a 429 response.
+func Reject(w http.ResponseWriter, d Decision) {
+	WriteHeaders(w, d)
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type namespaceKey struct{}
+
+// namespaceState charges each namespace at most once per request, however
+// many times a handler checks it.
+type namespaceState struct {
+	limiter *Limiter
+	class   Class
+	charged map[string]bool
+}
+
+// WithNamespaceLimiter returns a copy of ctx in which AllowNamespace charges
+// l's budgets for class.
+func WithNamespaceLimiter(ctx context.Context, l *Limiter, class Class) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, &namespaceState{limiter: l, class: class, charged: map[string]bool{}})
+}
+
+// AllowNamespace takes a token from namespace's budget unless this request
+// already did. Without a namespace limiter in ctx every namespace is allowed.
+func AllowNamespace(ctx context.Context, namespace string) Decision {
+	s, ok := ctx.Value(namespaceKey{}).(*namespaceState)
+	if !ok || s.charged[namespace] {
+		return Decision{Allowed: true}
+	}
+	s.charged[namespace] = true
+	return s.limiter.Allow(namespace, s.class)
+}
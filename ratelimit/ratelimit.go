@@ -0,0 +1,136 @@
+// ratelimit/ratelimit.go
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Class separates the read and write budgets of a key.
+type Class int
+
+const (
+	Read Class = iota
+	Write
+)
+
+// Budget is a token bucket: Rate tokens per second refill a bucket holding at
+// most Burst. A zero Rate disables limiting for the class.
+type Budget struct {
+	Rate  float64
+	Burst int
+}
+
+// Decision is the outcome of one Allow call, with the figures reported in the
+// RateLimit response headers.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed; zero
+	// when Allowed.
+	RetryAfter time.Duration
+}
+
+// idleTTL is how long a key's buckets are kept after its last request. A
+// bucket idle this long has refilled, so dropping it loses nothing.
+const idleTTL = 10 * time.Minute
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter keeps a token bucket per key and class.
+type Limiter struct {
+	mu        sync.Mutex
+	budgets   [2]Budget
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucketKey struct {
+	key   string
+	class Class
+}
+
+func NewLimiter(read, write Budget) *Limiter {
+	l := &Limiter{
+		buckets: map[bucketKey]*bucket{},
+		now:     time.Now,
+	}
+	l.SetBudgets(read, write)
+	return l
+}
+
+// SetBudgets changes the budgets, including those of buckets already in use.
+func (l *Limiter) SetBudgets(read, write Budget) {
+	if read.Burst < 1 {
+		read.Burst = 1
+	}
+	if write.Burst < 1 {
+		write.Burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.budgets = [2]Budget{Read: read, Write: write}
+	now := l.now()
+	for k, b := range l.buckets {
+		budget := l.budgets[k.class]
+		b.limiter.SetLimitAt(now, rate.Limit(budget.Rate))
+		b.limiter.SetBurstAt(now, budget.Burst)
+	}
+}
+
+// Allow takes one token from key's bucket for class.
+func (l *Limiter) Allow(key string, class Class) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	budget := l.budgets[class]
+	if budget.Rate <= 0 {
+		return Decision{Allowed: true}
+	}
+	now := l.now()
+	l.sweep(now)
+
+	k := bucketKey{key, class}
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(budget.Rate), budget.Burst)}
+		l.buckets[k] = b
+	}
+	b.lastSeen = now
+
+	d := Decision{Limit: budget.Burst}
+	r := b.limiter.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); !r.OK() || delay > 0 {
+		r.CancelAt(now)
+		d.RetryAfter = delay
+	} else {
+		d.Allowed = true
+	}
+	tokens := math.Max(b.limiter.TokensAt(now), 0)
+	d.Remaining = int(tokens)
+	d.Reset = time.Duration((float64(budget.Burst) - tokens) / budget.Rate * float64(time.Second))
+	return d
+}
+
+// sweep drops idle buckets at most once per idleTTL.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleTTL {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= idleTTL {
+			delete(l.buckets, k)
+		}
+	}
+}
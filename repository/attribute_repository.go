@@ -3,11 +3,14 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"stellarsky.ai/platform/public-config-service/model"
 
 	"golang.org/x/exp/slog"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AttributeRepository struct {
@@ -22,79 +25,190 @@ func NewAttributeRepository(db *gorm.DB, logger *slog.Logger) *AttributeReposito
 	}
 }
 
-func (r *AttributeRepository) GetAll(ctx context.Context) ([]model.Attribute, error) {
+// Transaction runs fn with a copy of the repository whose queries all run in
+// one database transaction, which is rolled back if fn returns an error.
+func (r *AttributeRepository) Transaction(ctx context.Context, fn func(*AttributeRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewAttributeRepository(tx, r.logger))
+	})
+}
+
+func (r *AttributeRepository) GetAll(ctx context.Context, includeDeleted bool) ([]model.Attribute, error) {
 	var attributes []model.Attribute
-	result := r.db.WithContext(ctx).
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).
 		// Joins("LEFT JOIN attribute_validations avs ON attributes.id = avs.attribute_id").
 		// Joins("LEFT JOIN validations ON avs.validation_id = validations.id AND validations.deleted_at is NULL").
 		// Joins("LEFT JOIN types ON attributes.type_id = types.id AND types.deleted_at is NULL").
-		Preload("Type").
-		Preload("Validations").
-		Find(&attributes, "attributes.deleted_at IS NULL")
+		Preload("Type", liveOnly).
+		Preload("OptionSet", liveOnly).
+		Preload("Validations", liveOnly).
+		Find(&attributes)
 
 	if result.Error != nil {
-		r.logger.Error("error querying all attributes", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying all attributes", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return attributes, nil
 }
 
-func (r *AttributeRepository) GetByID(ctx context.Context, id int64) (*model.Attribute, error) {
+func (r *AttributeRepository) GetByID(ctx context.Context, id int64, includeDeleted bool) (*model.Attribute, error) {
 	var a model.Attribute
-	result := r.db.WithContext(ctx).
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).
 		// Joins("LEFT JOIN attribute_validations avs ON attributes.id = avs.attribute_id AND avs.attribute_id = ?", id).
 		// Joins("LEFT JOIN validations ON avs.validation_id = validations.id AND validations.deleted_at is NULL").
 		// Joins("LEFT JOIN types ON attributes.type_id = types.id AND types.deleted_at is NULL").
-		Preload("Type").
-		Preload("Validations").
-		First(&a, "attributes.id = ? AND attributes.deleted_at IS NULL", id)
+		Preload("Type", liveOnly).
+		Preload("OptionSet", liveOnly).
+		Preload("Validations", liveOnly).
+		First(&a, "attributes.id = ?", id)
 	if result.Error == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
 	if result.Error != nil {
-		r.logger.Error("error querying attribute by id", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying attribute by id", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return &a, nil
 }
 
 func (r *AttributeRepository) Create(ctx context.Context, a *model.Attribute) error {
-	result := r.db.WithContext(ctx).Create(a)
-	if result.Error != nil {
-		r.logger.Error("error creating attribute", slog.Any("error", result.Error))
-		return result.Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createAttribute(ctx, tx, a)
+	})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error creating attribute", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
+// OptionSetExists reports whether option set id exists and is live.
+func (r *AttributeRepository) OptionSetExists(ctx context.Context, id uint64) (bool, error) {
+	var n int64
+	if err := r.db.WithContext(ctx).Model(&model.OptionSet{}).Where("id = ?", id).Count(&n).Error; err != nil {
+		r.logger.ErrorContext(ctx, "error querying option set", slog.Any("error", err))
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// TypeLineage returns live type id and its ancestors as TypeRepository.Lineage
+// does.
+func (r *AttributeRepository) TypeLineage(ctx context.Context, id uint64) ([]model.Type, error) {
+	return typeLineage(r.db.WithContext(ctx), r.logger, id, false)
+}
+
+// Update writes a. A renamed attribute is renamed in the pages, groups and
+// conditions of the live forms containing it too. The option set it names is
+// locked FOR SHARE.
 func (r *AttributeRepository) Update(ctx context.Context, a *model.Attribute) error {
-	result := r.db.WithContext(ctx).Model(a).Updates(map[string]interface{}{
-		"namespace":   a.Namespace,
-		"family":      a.Family,
-		"name":        a.Name,
-		"label":       a.Label,
-		"design_spec": a.DesignSpec,
-		"updated_at":  gorm.Expr("CURRENT_TIMESTAMP"),
-		"version":     gorm.Expr("version + 1"),
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before model.Attribute
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, a.ID).Error; err != nil {
+			return err
+		}
+		if a.OptionSetID != nil {
+			if err := lockReferenced(tx, "option_sets", *a.OptionSetID); err != nil {
+				return err
+			}
+		}
+		err := updateAudited[model.Attribute](ctx, tx, "attribute", a.ID, map[string]interface{}{
+			"namespace":     a.Namespace,
+			"family":        a.Family,
+			"name":          a.Name,
+			"label":         a.Label,
+			"help_text":     a.HelpText,
+			"translations":  a.Translations,
+			"design_spec":   a.DesignSpec,
+			"option_set_id": a.OptionSetID,
+			"data_source":   a.DataSource,
+			"updated_at":    gorm.Expr("CURRENT_TIMESTAMP"),
+			"version":       gorm.Expr("version + 1"),
+		})
+		if err != nil {
+			return err
+		}
+		return renameInForms(ctx, tx, a.ID, before.Name, a.Name)
 	})
-	if result.Error != nil {
-		r.logger.Error("error updating attribute", slog.Any("error", result.Error))
-		return result.Error
+	if err == gorm.ErrRecordNotFound || errors.Is(err, ErrReferenced) || errors.Is(err, ErrMissingReference) {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating attribute", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
 func (r *AttributeRepository) Delete(ctx context.Context, id int64) error {
-	result := r.db.WithContext(ctx).Model(&model.Attribute{}).Where("id = ? AND deleted_at IS NULL", id).Update("deleted_at", gorm.Expr("CURRENT_TIMESTAMP"))
-	if result.Error != nil {
-		r.logger.Error("error deleting attribute", slog.Any("error", result.Error))
-		return result.Error
+	err := deleteAudited[model.Attribute](ctx, r.db, "attribute", uint64(id))
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting attribute", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *AttributeRepository) Restore(ctx context.Context, id int64) error {
+	err := restoreAudited[model.Attribute](ctx, r.db, "attribute", uint64(id))
+	if err == gorm.ErrRecordNotFound || err == gorm.ErrDuplicatedKey {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error restoring attribute", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// Purge permanently removes a batch of attributes soft-deleted before cutoff.
+func (r *AttributeRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	n, err := purgeAudited[model.Attribute](ctx, r.db, "attribute", cutoff, "", map[string]string{"attribute_validations": "attribute_id", "form_attributes": "attribute_id"})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error purging attributes", slog.Any("error", err))
+		return 0, err
+	}
+	return n, nil
+}
+
+// Usages lists the live forms containing attribute id.
+func (r *AttributeRepository) Usages(ctx context.Context, id int64) (*model.Usages, error) {
+	forms, err := liveFormRefs(r.db.WithContext(ctx), "a.id = ?", id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error querying attribute usages", slog.Any("error", err))
+		return nil, err
+	}
+	return &model.Usages{Attributes: []model.UsageRef{}, Forms: forms}, nil
+}
+
+// DeleteChecked deletes attribute id as DeleteCascade does when cascade is set
+// and as Delete does otherwise, once check accepts what Usages lists. The attribute
+// stays locked from listing its usages until it is deleted. With dryRun only
+// the usages are listed and checked.
+func (r *AttributeRepository) DeleteChecked(ctx context.Context, id int64, cascade, dryRun bool,
+	check func(*model.Usages) error) (*model.Usages, error) {
+	bind := func(tx *gorm.DB) deleter { return NewAttributeRepository(tx, r.logger) }
+	return deleteChecked[model.Attribute](ctx, r.db, bind, id, cascade, dryRun, check)
+}
+
+// DeleteCascade deletes attribute id and removes it from every form, as
+// detachFromForms does.
+func (r *AttributeRepository) DeleteCascade(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := detachFromForms(ctx, tx, uint64(id)); err != nil {
+			return err
+		}
+		return deleteAudited[model.Attribute](ctx, tx, "attribute", uint64(id))
+	})
+	if err == gorm.ErrRecordNotFound || errors.Is(err, ErrReferenced) {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting attribute", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
@@ -0,0 +1,188 @@
+// repository/audit.go
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/requestid"
+)
+
+// Audit actions.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// systemActor is recorded for mutations that do not come from a request, such
+// as background jobs.
+const systemActor = "system"
+
+// createAudited inserts v and records the creation in the same transaction.
+// The entities v refers to are not saved with it: nested ones are created
+// through createAudited first, so that each has its own audit entry, and
+// linked explicitly.
+func createAudited[T any](ctx context.Context, db *gorm.DB, resource string, v *T) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(v).Error; err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, ActionCreate, resource, nil, v)
+	})
+}
+
+// updateAudited applies values to the live row with id and records the row
+// before and after the change. It returns gorm.ErrRecordNotFound when there is
+// no live row.
+func updateAudited[T any](ctx context.Context, db *gorm.DB, resource string, id uint64, values map[string]interface{}) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before, after T
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(new(T)).Where("id = ?", id).Updates(values).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&after, id).Error; err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, ActionUpdate, resource, &before, &after)
+	})
+}
+
+// deleteAudited soft-deletes the live row with id and records what it held.
+// It returns gorm.ErrRecordNotFound when there is no live row.
+func deleteAudited[T any](ctx context.Context, db *gorm.DB, resource string, id uint64) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before T
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, id).Error; err != nil {
+			return err
+		}
+		result := tx.Model(new(T)).Where("id = ? AND deleted_at IS NULL", id).Update("deleted_at", gorm.Expr("CURRENT_TIMESTAMP"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return writeAudit(ctx, tx, ActionDelete, resource, &before, nil)
+	})
+}
+
+// restoreAudited clears deleted_at on the soft-deleted row with id and records
+// the row before and after. It returns gorm.ErrRecordNotFound when there is no
+// deleted row with id, and gorm.ErrDuplicatedKey when a live row has taken its
+// natural key in the meantime.
+func restoreAudited[T any](ctx context.Context, db *gorm.DB, resource string, id uint64) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before, after T
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted_at IS NOT NULL").First(&before, id).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Model(new(T)).Where("id = ?", id).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&after, id).Error; err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, ActionRestore, resource, &before, &after)
+	})
+}
+
+// writeAudit inserts an audit entry in tx. before is nil for creations and
+// after is nil for deletions; when both are present the entry also carries
+// the fields that changed. The entity's ID and natural key are read from the
+// snapshots.
+func writeAudit(ctx context.Context, tx *gorm.DB, action, resource string, before, after interface{}) error {
+	entry := model.AuditEntry{
+		Actor:     systemActor,
+		RequestID: requestid.FromContext(ctx),
+		Action:    action,
+		Resource:  resource,
+	}
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		entry.Actor = p.Subject
+	}
+
+	var beforeFields, afterFields map[string]interface{}
+	var err error
+	if before != nil {
+		if entry.Before, beforeFields, err = snapshot(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if entry.After, afterFields, err = snapshot(after); err != nil {
+			return err
+		}
+	}
+
+	key := afterFields
+	if key == nil {
+		key = beforeFields
+	}
+	if id, ok := key["ID"].(json.Number); ok {
+		entry.ResourceID, _ = strconv.ParseUint(id.String(), 10, 64)
+	}
+	entry.Namespace, _ = key["Namespace"].(string)
+	entry.Family, _ = key["Family"].(string)
+	entry.Name, _ = key["Name"].(string)
+
+	if beforeFields != nil && afterFields != nil {
+		if entry.Changes, err = json.Marshal(diff(beforeFields, afterFields)); err != nil {
+			return err
+		}
+	}
+	return tx.Create(&entry).Error
+}
+
+func snapshot(v interface{}) (json.RawMessage, map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, nil, err
+	}
+	return raw, fields, nil
+}
+
+type change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+func diff(before, after map[string]interface{}) map[string]change {
+	changes := map[string]change{}
+	for k, b := range before {
+		if a := after[k]; !reflect.DeepEqual(a, b) {
+			changes[k] = change{Before: b, After: a}
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = change{After: a}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,72 @@
+// repository/audit_repository.go
+package repository
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// AuditFilter selects audit entries. Zero fields match every entry.
+type AuditFilter struct {
+	Actor     string
+	Namespace string
+	// Namespaces, when not nil, limits entries to those in the listed
+	// namespaces, such as the ones the caller may view.
+	Namespaces []string
+	Resource   string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+type AuditRepository struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewAuditRepository(db *gorm.DB, logger *slog.Logger) *AuditRepository {
+	return &AuditRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Find returns the entries matching f, newest first.
+func (r *AuditRepository) Find(ctx context.Context, f AuditFilter) ([]model.AuditEntry, error) {
+	q := r.db.WithContext(ctx).Order("id DESC")
+	if f.Actor != "" {
+		q = q.Where("actor = ?", f.Actor)
+	}
+	if f.Namespace != "" {
+		q = q.Where("namespace = ?", f.Namespace)
+	}
+	if f.Namespaces != nil {
+		if len(f.Namespaces) == 0 {
+			return []model.AuditEntry{}, nil
+		}
+		q = q.Where("namespace IN ?", f.Namespaces)
+	}
+	if f.Resource != "" {
+		q = q.Where("resource = ?", f.Resource)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+
+	var entries []model.AuditEntry
+	if result := q.Find(&entries); result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying audit entries", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return entries, nil
+}
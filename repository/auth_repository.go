@@ -0,0 +1,135 @@
+// repository/auth_repository.go
+package repository
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+type AuthRepository struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewAuthRepository(db *gorm.DB, logger *slog.Logger) *AuthRepository {
+	return &AuthRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *AuthRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var k model.APIKey
+	result := r.db.WithContext(ctx).First(&k, "key_hash = ? AND deleted_at IS NULL", hash)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying api key by hash", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return &k, nil
+}
+
+func (r *AuthRepository) GetAllAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	result := r.db.WithContext(ctx).Where("deleted_at IS NULL").Find(&keys)
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying all api keys", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return keys, nil
+}
+
+func (r *AuthRepository) CreateAPIKey(ctx context.Context, k *model.APIKey) error {
+	if err := createAudited(ctx, r.db, "api_key", k); err != nil {
+		r.logger.ErrorContext(ctx, "error creating api key", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepository) DeleteAPIKey(ctx context.Context, id int64) error {
+	err := deleteAudited[model.APIKey](ctx, r.db, "api_key", uint64(id))
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting api key", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepository) GetRoleBindingsByPrincipal(ctx context.Context, principal string) ([]model.RoleBinding, error) {
+	var bindings []model.RoleBinding
+	result := r.db.WithContext(ctx).Where("principal = ? AND deleted_at IS NULL", principal).Find(&bindings)
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying role bindings by principal", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return bindings, nil
+}
+
+func (r *AuthRepository) GetAllRoleBindings(ctx context.Context) ([]model.RoleBinding, error) {
+	var bindings []model.RoleBinding
+	result := r.db.WithContext(ctx).Where("deleted_at IS NULL").Find(&bindings)
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying all role bindings", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return bindings, nil
+}
+
+func (r *AuthRepository) GetRoleBindingByID(ctx context.Context, id int64) (*model.RoleBinding, error) {
+	var b model.RoleBinding
+	result := r.db.WithContext(ctx).First(&b, "id = ? AND deleted_at IS NULL", id)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying role binding by id", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return &b, nil
+}
+
+// SaveRoleBinding grants b.Role to b.Principal in b.Namespace, replacing the
+// role of an existing binding for the pair, including a revoked one.
+func (r *AuthRepository) SaveRoleBinding(ctx context.Context, b *model.RoleBinding) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "principal"}, {Name: "namespace"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"role":       b.Role,
+				"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+				"deleted_at": nil,
+			}),
+		}).Create(b).Error
+		if err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, ActionCreate, "role_binding", nil, b)
+	})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error saving role binding", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *AuthRepository) DeleteRoleBinding(ctx context.Context, id int64) error {
+	err := deleteAudited[model.RoleBinding](ctx, r.db, "role_binding", uint64(id))
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting role binding", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
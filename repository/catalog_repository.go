@@ -0,0 +1,320 @@
+// repository/catalog_repository.go
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// ErrMissingReference is wrapped by errors for an entity that refers to one
+// that is not live, or when importing, neither in the catalog nor live in the
+// database.
+var ErrMissingReference = errors.New("missing reference")
+
+// errDryRun rolls back a dry-run import after it has run in full.
+var errDryRun = errors.New("dry run")
+
+type CatalogRepository struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewCatalogRepository(db *gorm.DB, logger *slog.Logger) *CatalogRepository {
+	return &CatalogRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+type naturalKey struct {
+	namespace, family, name string
+}
+
+func (k naturalKey) String() string {
+	return k.namespace + "/" + k.family + "/" + k.name
+}
+
+// Import upserts every entity in c by namespace, family and name, in one
+// transaction, and reports what changed. With dryRun the transaction is rolled
+// back after the report is built.
+func (r *CatalogRepository) Import(ctx context.Context, c *model.Catalog, dryRun bool) (*model.ImportReport, error) {
+	report := &model.ImportReport{DryRun: dryRun, Resources: map[string]model.ImportCounts{}}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		validations := map[naturalKey]uint64{}
+		for i := range c.Validations {
+			v := c.Validations[i]
+			id, outcome, err := upsertByKey(ctx, tx, "validation", &v, naturalKey{v.Namespace, v.Family, v.Name},
+				func(e *model.Validation) bool {
+					return e.RuleName == v.RuleName && e.ValidationParams == v.ValidationParams &&
+						reflect.DeepEqual(e.Messages, v.Messages)
+				},
+				map[string]interface{}{"rule_name": v.RuleName, "validation_params": v.ValidationParams,
+					"messages": v.Messages})
+			if err != nil {
+				return err
+			}
+			validations[naturalKey{v.Namespace, v.Family, v.Name}] = id
+			count(report, "validation", outcome)
+		}
+
+		types := map[naturalKey]uint64{}
+		inCatalog := map[naturalKey]bool{}
+		for _, t := range c.Types {
+			inCatalog[naturalKey{t.Namespace, t.Family, t.Name}] = true
+		}
+		// Types are imported after the types they extend.
+		pending := c.Types
+		for len(pending) > 0 {
+			var later []model.Type
+			for i := range pending {
+				t := pending[i]
+				key := naturalKey{t.Namespace, t.Family, t.Name}
+				var parentID *uint64
+				if p := t.Parent; p != nil {
+					parent := naturalKey{p.Namespace, p.Family, p.Name}
+					if _, done := types[parent]; inCatalog[parent] && !done {
+						later = append(later, t)
+						continue
+					}
+					id, err := resolve[model.Type](tx, types, parent)
+					if err != nil {
+						return fmt.Errorf("type %s: parent %w", key, err)
+					}
+					parentID = &id
+				}
+				validationIDs := make([]uint64, 0, len(t.Validations))
+				for _, v := range t.Validations {
+					id, err := resolve[model.Validation](tx, validations, naturalKey{v.Namespace, v.Family, v.Name})
+					if err != nil {
+						return fmt.Errorf("type %s: validation %w", key, err)
+					}
+					validationIDs = append(validationIDs, id)
+				}
+				// Links are written by replaceLinksAudited below, not by Create.
+				t.ParentID, t.Parent, t.Validations = parentID, nil, nil
+
+				id, outcome, err := upsertByKey(ctx, tx, "type", &t, key,
+					func(e *model.Type) bool {
+						return e.ElementType == t.ElementType && e.WidgetType == t.WidgetType &&
+							reflect.DeepEqual(e.ParentID, parentID) && bytes.Equal(e.DesignSchema, t.DesignSchema) &&
+							bytes.Equal(e.DesignDefaults, t.DesignDefaults)
+					},
+					map[string]interface{}{"element_type": t.ElementType, "widget_type": t.WidgetType,
+						"parent_id": parentID, "design_schema": t.DesignSchema, "design_defaults": t.DesignDefaults})
+				if err != nil {
+					return err
+				}
+				linked, err := replaceLinksAudited(ctx, tx, typeValidations, id, validationIDs)
+				if err != nil {
+					return err
+				}
+				if linked && outcome == outcomeUnchanged {
+					outcome = outcomeUpdated
+				}
+				types[key] = id
+				count(report, "type", outcome)
+			}
+			if len(later) == len(pending) {
+				t := later[0]
+				return fmt.Errorf("type %s: types extending each other: %w",
+					naturalKey{t.Namespace, t.Family, t.Name}, ErrMissingReference)
+			}
+			pending = later
+		}
+
+		optionSets := map[naturalKey]uint64{}
+		for i := range c.OptionSets {
+			o := c.OptionSets[i]
+			id, outcome, err := upsertByKey(ctx, tx, "option_set", &o, naturalKey{o.Namespace, o.Family, o.Name},
+				func(e *model.OptionSet) bool { return reflect.DeepEqual(e.Options, o.Options) },
+				map[string]interface{}{"options": o.Options})
+			if err != nil {
+				return err
+			}
+			optionSets[naturalKey{o.Namespace, o.Family, o.Name}] = id
+			count(report, "option_set", outcome)
+		}
+
+		attributes := map[naturalKey]uint64{}
+		for i := range c.Attributes {
+			a := c.Attributes[i]
+			key := naturalKey{a.Namespace, a.Family, a.Name}
+			typeID, err := resolve[model.Type](tx, types, naturalKey{a.Type.Namespace, a.Type.Family, a.Type.Name})
+			if err != nil {
+				return fmt.Errorf("attribute %s: type %w", key, err)
+			}
+			validationIDs := make([]uint64, 0, len(a.Validations))
+			for _, v := range a.Validations {
+				id, err := resolve[model.Validation](tx, validations, naturalKey{v.Namespace, v.Family, v.Name})
+				if err != nil {
+					return fmt.Errorf("attribute %s: validation %w", key, err)
+				}
+				validationIDs = append(validationIDs, id)
+			}
+			var optionSetID *uint64
+			if o := a.OptionSet; o != nil {
+				id, err := resolve[model.OptionSet](tx, optionSets, naturalKey{o.Namespace, o.Family, o.Name})
+				if err != nil {
+					return fmt.Errorf("attribute %s: option set %w", key, err)
+				}
+				optionSetID = &id
+			}
+			// Links are written by replaceLinksAudited below, not by Create.
+			a.TypeID, a.Type, a.Validations = typeID, model.Type{}, nil
+			a.OptionSetID, a.OptionSet = optionSetID, nil
+
+			id, outcome, err := upsertByKey(ctx, tx, "attribute", &a, key,
+				func(e *model.Attribute) bool {
+					return e.Label == a.Label && e.HelpText == a.HelpText &&
+						reflect.DeepEqual(e.Translations, a.Translations) && e.DesignSpec == a.DesignSpec &&
+						e.TypeID == typeID && reflect.DeepEqual(e.OptionSetID, optionSetID) &&
+						reflect.DeepEqual(e.DataSource, a.DataSource)
+				},
+				map[string]interface{}{"label": a.Label, "help_text": a.HelpText, "translations": a.Translations,
+					"design_spec": a.DesignSpec, "type_id": typeID, "option_set_id": optionSetID,
+					"data_source": a.DataSource})
+			if err != nil {
+				return err
+			}
+			linked, err := replaceLinksAudited(ctx, tx, attributeValidations, id, validationIDs)
+			if err != nil {
+				return err
+			}
+			if linked && outcome == outcomeUnchanged {
+				outcome = outcomeUpdated
+			}
+			attributes[key] = id
+			count(report, "attribute", outcome)
+		}
+
+		for i := range c.Forms {
+			f := c.Forms[i]
+			key := naturalKey{f.Namespace, f.Family, f.Name}
+			attributeIDs := make([]uint64, 0, len(f.Attributes))
+			for _, a := range f.Attributes {
+				id, err := resolve[model.Attribute](tx, attributes, naturalKey{a.Namespace, a.Family, a.Name})
+				if err != nil {
+					return fmt.Errorf("form %s: attribute %w", key, err)
+				}
+				attributeIDs = append(attributeIDs, id)
+			}
+			f.Attributes = nil
+
+			id, outcome, err := upsertByKey(ctx, tx, "form", &f, key,
+				func(e *model.Form) bool {
+					return e.ActionName == f.ActionName && reflect.DeepEqual(e.Pages, f.Pages) &&
+						reflect.DeepEqual(e.Conditions, f.Conditions) && reflect.DeepEqual(e.Groups, f.Groups)
+				},
+				map[string]interface{}{"action_name": f.ActionName, "pages": f.Pages, "conditions": f.Conditions,
+					"groups": f.Groups})
+			if err != nil {
+				return err
+			}
+			linked, err := replaceLinksAudited(ctx, tx, formAttributes, id, attributeIDs)
+			if err != nil {
+				return err
+			}
+			if linked && outcome == outcomeUnchanged {
+				outcome = outcomeUpdated
+			}
+			count(report, "form", outcome)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		if !errors.Is(err, ErrMissingReference) {
+			r.logger.ErrorContext(ctx, "error importing catalog", slog.Any("error", err))
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+type outcome int
+
+const (
+	outcomeCreated outcome = iota
+	outcomeUpdated
+	outcomeUnchanged
+)
+
+func count(report *model.ImportReport, resource string, o outcome) {
+	c := report.Resources[resource]
+	switch o {
+	case outcomeCreated:
+		c.Created++
+	case outcomeUpdated:
+		c.Updated++
+	default:
+		c.Unchanged++
+	}
+	report.Resources[resource] = c
+}
+
+// upsertByKey creates v when no live row has its natural key, or applies
+// values to the live row when same reports a difference. Both go through the
+// audited helpers.
+func upsertByKey[T any](ctx context.Context, tx *gorm.DB, resource string, v *T, key naturalKey,
+	same func(existing *T) bool, values map[string]interface{}) (uint64, outcome, error) {
+	var existing T
+	err := tx.Where("namespace = ? AND family = ? AND name = ?", key.namespace, key.family, key.name).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		clearEntityID(v)
+		if err := createAudited(ctx, tx, resource, v); err != nil {
+			return 0, 0, fmt.Errorf("creating %s %s: %w", resource, key, err)
+		}
+		return entityID(v), outcomeCreated, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	id := entityID(&existing)
+	if same(&existing) {
+		return id, outcomeUnchanged, nil
+	}
+	values["updated_at"] = gorm.Expr("CURRENT_TIMESTAMP")
+	values["version"] = gorm.Expr("version + 1")
+	if err := updateAudited[T](ctx, tx, resource, id, values); err != nil {
+		return 0, 0, fmt.Errorf("updating %s %s: %w", resource, key, err)
+	}
+	return id, outcomeUpdated, nil
+}
+
+// resolve returns the ID of the entity with key, imported earlier in this
+// catalog or already live in the database, which it locks FOR SHARE.
+func resolve[T any](tx *gorm.DB, imported map[naturalKey]uint64, key naturalKey) (uint64, error) {
+	if id, ok := imported[key]; ok {
+		return id, nil
+	}
+	var existing T
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+		Where("namespace = ? AND family = ? AND name = ?", key.namespace, key.family, key.name).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("%s: %w", key, ErrMissingReference)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entityID(&existing), nil
+}
+
+// entityID reads the ID field every model has.
+func entityID[T any](v *T) uint64 {
+	return reflect.ValueOf(v).Elem().FieldByName("ID").Uint()
+}
+
+func clearEntityID[T any](v *T) {
+	reflect.ValueOf(v).Elem().FieldByName("ID").SetUint(0)
+}
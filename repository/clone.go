@@ -0,0 +1,234 @@
+// repository/clone.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"stellarsky.ai/platform/public-config-service/expr"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// ErrAlreadyExists is wrapped by clone errors for a target that a live entity
+// already holds when the conflict strategy is model.CloneFail.
+var ErrAlreadyExists = errors.New("already exists")
+
+// maxCloneSuffix bounds the search for a free name with model.CloneSuffix.
+const maxCloneSuffix = 100
+
+// Clone copies form id to req's target in one transaction and maps the source
+// IDs to the clone's. req must have its target and strategy filled in.
+func (r *FormRepository) Clone(ctx context.Context, id int64, req *model.CloneRequest) (*model.CloneResult, error) {
+	result := &model.CloneResult{Forms: map[uint64]uint64{}, Attributes: map[uint64]uint64{}}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var src model.Form
+		err := tx.Preload("Attributes", liveOnly).Preload("Attributes.Validations", liveOnly).First(&src, id).Error
+		if err != nil {
+			return err
+		}
+
+		name, formID, err := claimKey[model.Form](tx, "form", naturalKey{req.Namespace, req.Family, req.Name}, req.OnConflict)
+		if err != nil {
+			return err
+		}
+		if formID != 0 {
+			result.Forms[src.ID] = formID
+			result.Reused = true
+			return mapReusedAttributes(tx, formID, src.Attributes, result)
+		}
+
+		attributeIDs := make([]uint64, 0, len(src.Attributes))
+		renamed := map[string]string{}
+		for _, a := range src.Attributes {
+			cloneID, ok := result.Attributes[a.ID]
+			if !ok {
+				var name string
+				if cloneID, name, err = cloneAttribute(ctx, tx, &a, req); err != nil {
+					return err
+				}
+				result.Attributes[a.ID] = cloneID
+				renamed[a.Name] = name
+			}
+			attributeIDs = append(attributeIDs, cloneID)
+		}
+		pages := renamePages(src.Pages, renamed)
+		groups := renameGroups(src.Groups, renamed)
+		conditions, err := renameConditions(src.Conditions, renamed)
+		if err != nil {
+			return err
+		}
+
+		f := model.Form{Namespace: req.Namespace, Family: req.Family, Name: name, ActionName: src.ActionName,
+			Pages: pages, Conditions: conditions, Groups: groups}
+		if err := createAudited(ctx, tx, "form", &f); err != nil {
+			return err
+		}
+		if _, err := replaceLinksAudited(ctx, tx, formAttributes, f.ID, attributeIDs); err != nil {
+			return err
+		}
+		result.Forms[src.ID] = f.ID
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, ErrAlreadyExists) {
+			r.logger.ErrorContext(ctx, "error cloning form", slog.Any("error", err))
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// mapReusedAttributes maps each of attributes, a source form's, to the
+// attribute of the same family and name of reused form id, if it has one.
+func mapReusedAttributes(tx *gorm.DB, id uint64, attributes []model.Attribute, result *model.CloneResult) error {
+	var reused model.Form
+	if err := tx.Preload("Attributes", liveOnly).First(&reused, id).Error; err != nil {
+		return err
+	}
+	for _, a := range attributes {
+		for _, r := range reused.Attributes {
+			if r.Family == a.Family && r.Name == a.Name {
+				result.Attributes[a.ID] = r.ID
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// cloneAttribute returns the ID and name of a's counterpart in the clone: a
+// itself unless req asks for a deep copy.
+func cloneAttribute(ctx context.Context, tx *gorm.DB, a *model.Attribute, req *model.CloneRequest) (uint64, string, error) {
+	if !req.DeepCopy {
+		return a.ID, a.Name, nil
+	}
+	name, id, err := claimKey[model.Attribute](tx, "attribute", naturalKey{req.Namespace, a.Family, a.Name}, req.OnConflict)
+	if err != nil || id != 0 {
+		return id, name, err
+	}
+	c := model.Attribute{
+		Namespace:    req.Namespace,
+		Family:       a.Family,
+		Name:         name,
+		Label:        a.Label,
+		HelpText:     a.HelpText,
+		Translations: a.Translations,
+		DesignSpec:   a.DesignSpec,
+		TypeID:       a.TypeID,
+		OptionSetID:  a.OptionSetID,
+		DataSource:   a.DataSource,
+		Validations:  a.Validations,
+	}
+	if err := createAttribute(ctx, tx, &c); err != nil {
+		return 0, "", err
+	}
+	return c.ID, name, nil
+}
+
+// renamePages copies pages with the attributes renamed by a deep copy.
+func renamePages(pages model.Pages, renamed map[string]string) model.Pages {
+	if pages == nil {
+		return nil
+	}
+	out := make(model.Pages, len(pages))
+	for i, p := range pages {
+		c := model.Page{Name: p.Name, Title: p.Title}
+		for _, name := range p.Attributes {
+			c.Attributes = append(c.Attributes, rename(renamed, name))
+		}
+		for _, rule := range p.Navigation {
+			rule.When.Attribute = rename(renamed, rule.When.Attribute)
+			c.Navigation = append(c.Navigation, rule)
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// renameGroups copies groups with the attributes renamed by a deep copy.
+func renameGroups(groups model.Groups, renamed map[string]string) model.Groups {
+	if groups == nil {
+		return nil
+	}
+	out := make(model.Groups, len(groups))
+	for i, g := range groups {
+		c := g
+		c.Attributes = make([]string, len(g.Attributes))
+		for j, name := range g.Attributes {
+			c.Attributes[j] = rename(renamed, name)
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// renameConditions copies conditions with the attributes renamed by a deep
+// copy, both as keys and within expressions.
+func renameConditions(conditions model.Conditions, renamed map[string]string) (model.Conditions, error) {
+	if conditions == nil {
+		return nil, nil
+	}
+	out := make(model.Conditions, len(conditions))
+	for name, c := range conditions {
+		visibleIf, err := expr.Rename(c.VisibleIf, renamed)
+		if err != nil {
+			return nil, err
+		}
+		requiredIf, err := expr.Rename(c.RequiredIf, renamed)
+		if err != nil {
+			return nil, err
+		}
+		out[rename(renamed, name)] = model.AttributeConditions{VisibleIf: visibleIf, RequiredIf: requiredIf}
+	}
+	return out, nil
+}
+
+func rename(renamed map[string]string, name string) string {
+	if to, ok := renamed[name]; ok {
+		return to
+	}
+	return name
+}
+
+// claimKey decides what a clone does about key. It returns the name to create
+// the copy under, or the ID of the live entity to reuse instead.
+func claimKey[T any](tx *gorm.DB, resource string, key naturalKey, strategy string) (string, uint64, error) {
+	id, err := liveID[T](tx, key)
+	if err != nil || id == 0 {
+		return key.name, 0, err
+	}
+	switch strategy {
+	case model.CloneReuse:
+		return key.name, id, nil
+	case model.CloneSuffix:
+		for i := 1; i <= maxCloneSuffix; i++ {
+			name := key.name + "_copy"
+			if i > 1 {
+				name = fmt.Sprintf("%s_copy_%d", key.name, i)
+			}
+			id, err := liveID[T](tx, naturalKey{key.namespace, key.family, name})
+			if err != nil || id == 0 {
+				return name, 0, err
+			}
+		}
+		return "", 0, fmt.Errorf("%s %s: no free name after %d suffixes: %w", resource, key, maxCloneSuffix, ErrAlreadyExists)
+	default:
+		return "", 0, fmt.Errorf("%s %s %w", resource, key, ErrAlreadyExists)
+	}
+}
+
+// liveID returns the ID of the live entity with key, or zero if there is none.
+func liveID[T any](tx *gorm.DB, key naturalKey) (uint64, error) {
+	var existing T
+	err := tx.Where("namespace = ? AND family = ? AND name = ?", key.namespace, key.family, key.name).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return entityID(&existing), nil
+}
@@ -3,6 +3,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"golang.org/x/exp/slog"
 	"gorm.io/gorm"
@@ -21,84 +22,141 @@ func NewFormRepository(db *gorm.DB, logger *slog.Logger) *FormRepository {
 	}
 }
 
-func (r *FormRepository) GetAll(ctx context.Context) ([]model.Form, error) {
+// Transaction runs fn with a copy of the repository whose queries all run in
+// one database transaction, which is rolled back if fn returns an error.
+func (r *FormRepository) Transaction(ctx context.Context, fn func(*FormRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewFormRepository(tx, r.logger))
+	})
+}
+
+func (r *FormRepository) GetAll(ctx context.Context, includeDeleted bool) ([]model.Form, error) {
 	var forms []model.Form
-	result := r.db.WithContext(ctx).
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).
 		Joins("LEFT JOIN form_attributes fas ON fas.form_id = forms.id").
 		Joins("LEFT JOIN attributes ON fas.attribute_id = attributes.id AND attributes.deleted_at is NULL").
 		Joins("LEFT JOIN attribute_validations avs ON attributes.id = avs.attribute_id").
 		Joins("LEFT JOIN validations ON avs.validation_id = validations.id AND validations.deleted_at is NULL").
 		Joins("LEFT JOIN types ON attributes.type_id = types.id AND types.deleted_at is NULL").
-		Preload("Attributes").
-		Preload("Attributes.Type").
-		Preload("Attributes.Validations").
-		Find(&forms, "forms.deleted_at IS NULL")
+		Preload("Attributes", liveOnly).
+		Preload("Attributes.Type", liveOnly).
+		Preload("Attributes.OptionSet", liveOnly).
+		Preload("Attributes.Validations", liveOnly).
+		Find(&forms)
 	if result.Error != nil {
-		r.logger.Error("error querying all forms", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying all forms", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return forms, nil
 }
 
-func (r *FormRepository) GetByID(ctx context.Context, id int64) (*model.Form, error) {
+func (r *FormRepository) GetByID(ctx context.Context, id int64, includeDeleted bool) (*model.Form, error) {
 	var f model.Form
-	result := r.db.WithContext(ctx).
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).
 		// Joins("LEFT JOIN form_attributes fas ON fas.form_id = forms.id").
 		// Joins("LEFT JOIN attributes ON fas.attribute_id = attributes.id AND attributes.deleted_at is NULL").
 		// Joins("LEFT JOIN attribute_validations avs ON attributes.id = avs.attribute_id").
 		// Joins("LEFT JOIN validations ON avs.validation_id = validations.id AND validations.deleted_at is NULL").
 		// Joins("LEFT JOIN types ON attributes.type_id = types.id AND types.deleted_at is NULL").
-		Preload("Attributes").
-		Preload("Attributes.Type").
-		Preload("Attributes.Validations").
-		First(&f, "forms.id = ? AND forms.deleted_at IS NULL", id)
+		Preload("Attributes", liveOnly).
+		Preload("Attributes.Type", liveOnly).
+		Preload("Attributes.OptionSet", liveOnly).
+		Preload("Attributes.Validations", liveOnly).
+		First(&f, "forms.id = ?", id)
 	if result.Error == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
 	if result.Error != nil {
-		r.logger.Error("error querying form by id", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying form by id", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return &f, nil
 }
 
+// TypeLineage returns live type id and its ancestors as TypeRepository.Lineage
+// does.
+func (r *FormRepository) TypeLineage(ctx context.Context, id uint64) ([]model.Type, error) {
+	return typeLineage(r.db.WithContext(ctx), r.logger, id, false)
+}
+
 func (r *FormRepository) Create(ctx context.Context, f *model.Form) error {
-	// r.logger.Info("Form to be created", slog.Any("form", f))
-	result := r.db.WithContext(ctx).Create(f)
-	if result.Error != nil {
-		r.logger.Error("error creating form", slog.Any("error", result.Error))
-		return result.Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createForm(ctx, tx, f)
+	})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error creating form", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
+// AttributeNames returns the names of the live attributes with the given IDs.
+func (r *FormRepository) AttributeNames(ctx context.Context, ids []uint64) (map[uint64]string, error) {
+	var refs []model.UsageRef
+	err := r.db.WithContext(ctx).Model(&model.Attribute{}).Select("id, name").Where("id IN ?", ids).Scan(&refs).Error
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error querying attribute names", slog.Any("error", err))
+		return nil, err
+	}
+	names := make(map[uint64]string, len(refs))
+	for _, ref := range refs {
+		names[ref.ID] = ref.Name
+	}
+	return names, nil
+}
+
 func (r *FormRepository) Update(ctx context.Context, f *model.Form) error {
-	result := r.db.WithContext(ctx).Model(f).Updates(map[string]interface{}{
+	err := updateAudited[model.Form](ctx, r.db, "form", f.ID, map[string]interface{}{
 		"namespace":   f.Namespace,
 		"family":      f.Family,
 		"name":        f.Name,
 		"action_name": f.ActionName,
+		"pages":       f.Pages,
+		"conditions":  f.Conditions,
+		"groups":      f.Groups,
 		"updated_at":  gorm.Expr("CURRENT_TIMESTAMP"),
 		"version":     gorm.Expr("version + 1"),
 	})
-	if result.Error != nil {
-		r.logger.Error("error updating form", slog.Any("error", result.Error))
-		return result.Error
+	if err == gorm.ErrRecordNotFound {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating form", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
 func (r *FormRepository) Delete(ctx context.Context, id int64) error {
-	result := r.db.WithContext(ctx).Model(&model.Form{}).Where("id = ? AND deleted_at IS NULL", id).Update("deleted_at", gorm.Expr("CURRENT_TIMESTAMP"))
-	if result.Error != nil {
-		r.logger.Error("error deleting form", slog.Any("error", result.Error))
-		return result.Error
+	err := deleteAudited[model.Form](ctx, r.db, "form", uint64(id))
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting form", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *FormRepository) Restore(ctx context.Context, id int64) error {
+	err := restoreAudited[model.Form](ctx, r.db, "form", uint64(id))
+	if err == gorm.ErrRecordNotFound || err == gorm.ErrDuplicatedKey {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error restoring form", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
+
+// Purge permanently removes a batch of forms soft-deleted before cutoff.
+func (r *FormRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	n, err := purgeAudited[model.Form](ctx, r.db, "form", cutoff, "", map[string]string{"form_attributes": "form_id"})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error purging forms", slog.Any("error", err))
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,51 @@
+// repository/health_repository.go
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+type HealthRepository struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewHealthRepository(db *gorm.DB, logger *slog.Logger) *HealthRepository {
+	return &HealthRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *HealthRepository) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (r *HealthRepository) PoolStats() (sql.DBStats, error) {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+// SchemaVersion returns the highest schema version Migrate has recorded, or 0
+// for a database that was never migrated.
+func (r *HealthRepository) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	result := r.db.WithContext(ctx).Model(&model.SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version)
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying schema version", slog.Any("error", result.Error))
+		return 0, result.Error
+	}
+	return version, nil
+}
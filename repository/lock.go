@@ -0,0 +1,45 @@
+// repository/lock.go
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockLive locks the live rows of table with ids, in ID order, until tx ends:
+// with strength "UPDATE" against any change, with "SHARE" against changes
+// and deletes while other transactions may still share them. It returns
+// gorm.ErrRecordNotFound when any of them is not live, which includes one
+// deleted by a transaction the lock waited for.
+func lockLive(tx *gorm.DB, table, strength string, ids ...uint64) error {
+	want := map[uint64]bool{}
+	for _, id := range ids {
+		want[id] = true
+	}
+	if len(want) == 0 {
+		return nil
+	}
+	var locked []uint64
+	err := tx.Table(table).Clauses(clause.Locking{Strength: strength}).
+		Where("id IN ? AND deleted_at IS NULL", ids).Order("id").Pluck("id", &locked).Error
+	if err != nil {
+		return err
+	}
+	if len(locked) != len(want) {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// lockReferenced locks the live rows of table with ids FOR SHARE, so that
+// none of them can be deleted before a row referring to them is written and
+// tx ends. It fails with ErrMissingReference when any of them is not live.
+func lockReferenced(tx *gorm.DB, table string, ids ...uint64) error {
+	err := lockLive(tx, table, "SHARE", ids...)
+	if err == gorm.ErrRecordNotFound {
+		return fmt.Errorf("%w: no live %s with IDs %v", ErrMissingReference, table, ids)
+	}
+	return err
+}
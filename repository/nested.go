@@ -0,0 +1,91 @@
+// repository/nested.go
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// createValidations creates the validations in vs that have no ID yet and
+// returns the IDs of all of them.
+func createValidations(ctx context.Context, tx *gorm.DB, vs []model.Validation) ([]uint64, error) {
+	ids := make([]uint64, 0, len(vs))
+	for i := range vs {
+		v := &vs[i]
+		if v.ID == 0 {
+			if err := createAudited(ctx, tx, "validation", v); err != nil {
+				return nil, err
+			}
+		}
+		ids = append(ids, v.ID)
+	}
+	return ids, nil
+}
+
+// createType creates t with its new validations and links it to all of them.
+// The type it extends is locked FOR SHARE.
+func createType(ctx context.Context, tx *gorm.DB, t *model.Type) error {
+	if t.ParentID != nil {
+		if err := lockReferenced(tx, "types", *t.ParentID); err != nil {
+			return err
+		}
+	}
+	ids, err := createValidations(ctx, tx, t.Validations)
+	if err != nil {
+		return err
+	}
+	if err := createAudited(ctx, tx, "type", t); err != nil {
+		return err
+	}
+	_, err = replaceLinksAudited(ctx, tx, typeValidations, t.ID, ids)
+	return err
+}
+
+// createAttribute creates a with its new validations and, when it names no
+// type by ID, the type it brings, and links it to all of its validations. The
+// type and option set it names are locked FOR SHARE.
+func createAttribute(ctx context.Context, tx *gorm.DB, a *model.Attribute) error {
+	if a.TypeID == 0 {
+		if err := createType(ctx, tx, &a.Type); err != nil {
+			return err
+		}
+		a.TypeID = a.Type.ID
+	} else if err := lockReferenced(tx, "types", a.TypeID); err != nil {
+		return err
+	}
+	if a.OptionSetID != nil {
+		if err := lockReferenced(tx, "option_sets", *a.OptionSetID); err != nil {
+			return err
+		}
+	}
+	ids, err := createValidations(ctx, tx, a.Validations)
+	if err != nil {
+		return err
+	}
+	if err := createAudited(ctx, tx, "attribute", a); err != nil {
+		return err
+	}
+	_, err = replaceLinksAudited(ctx, tx, attributeValidations, a.ID, ids)
+	return err
+}
+
+// createForm creates f with its new attributes and links it to all of them.
+func createForm(ctx context.Context, tx *gorm.DB, f *model.Form) error {
+	ids := make([]uint64, 0, len(f.Attributes))
+	for i := range f.Attributes {
+		a := &f.Attributes[i]
+		if a.ID == 0 {
+			if err := createAttribute(ctx, tx, a); err != nil {
+				return err
+			}
+		}
+		ids = append(ids, a.ID)
+	}
+	if err := createAudited(ctx, tx, "form", f); err != nil {
+		return err
+	}
+	_, err := replaceLinksAudited(ctx, tx, formAttributes, f.ID, ids)
+	return err
+}
@@ -0,0 +1,156 @@
+// repository/option_set_repository.go
+package repository
+
+import (
+	"context"
+	"time"
+
+	"stellarsky.ai/platform/public-config-service/model"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+type OptionSetRepository struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewOptionSetRepository(db *gorm.DB, logger *slog.Logger) *OptionSetRepository {
+	return &OptionSetRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *OptionSetRepository) GetAll(ctx context.Context, includeDeleted bool) ([]model.OptionSet, error) {
+	var sets []model.OptionSet
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).Find(&sets)
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying all option sets", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return sets, nil
+}
+
+func (r *OptionSetRepository) GetByID(ctx context.Context, id int64, includeDeleted bool) (*model.OptionSet, error) {
+	var o model.OptionSet
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).First(&o, "id = ?", id)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error querying option set by id", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return &o, nil
+}
+
+func (r *OptionSetRepository) Create(ctx context.Context, o *model.OptionSet) error {
+	if err := createAudited(ctx, r.db, "option_set", o); err != nil {
+		r.logger.ErrorContext(ctx, "error creating option set", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *OptionSetRepository) Update(ctx context.Context, o *model.OptionSet) error {
+	err := updateAudited[model.OptionSet](ctx, r.db, "option_set", o.ID, map[string]interface{}{
+		"namespace":  o.Namespace,
+		"family":     o.Family,
+		"name":       o.Name,
+		"options":    o.Options,
+		"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		"version":    gorm.Expr("version + 1"),
+	})
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating option set", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *OptionSetRepository) Delete(ctx context.Context, id int64) error {
+	err := deleteAudited[model.OptionSet](ctx, r.db, "option_set", uint64(id))
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting option set", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *OptionSetRepository) Restore(ctx context.Context, id int64) error {
+	err := restoreAudited[model.OptionSet](ctx, r.db, "option_set", uint64(id))
+	if err == gorm.ErrRecordNotFound || err == gorm.ErrDuplicatedKey {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error restoring option set", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// Purge permanently removes a batch of option sets soft-deleted before
+// cutoff. Option sets still referenced by an attribute, even a deleted one,
+// are kept.
+func (r *OptionSetRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	n, err := purgeAudited[model.OptionSet](ctx, r.db, "option_set", cutoff,
+		"NOT EXISTS (SELECT 1 FROM attributes WHERE attributes.option_set_id = option_sets.id)", nil)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error purging option sets", slog.Any("error", err))
+		return 0, err
+	}
+	return n, nil
+}
+
+// Usages lists the live attributes offering option set id and the forms
+// containing them.
+func (r *OptionSetRepository) Usages(ctx context.Context, id int64) (*model.Usages, error) {
+	return usages(r.db.WithContext(ctx), r.logger, "option set", "a.option_set_id = ?", id)
+}
+
+// DeleteChecked deletes option set id as DeleteCascade does when cascade is set
+// and as Delete does otherwise, once check accepts what Usages lists. The option set
+// stays locked from listing its usages until it is deleted. With dryRun only
+// the usages are listed and checked.
+func (r *OptionSetRepository) DeleteChecked(ctx context.Context, id int64, cascade, dryRun bool,
+	check func(*model.Usages) error) (*model.Usages, error) {
+	bind := func(tx *gorm.DB) deleter { return NewOptionSetRepository(tx, r.logger) }
+	return deleteChecked[model.OptionSet](ctx, r.db, bind, id, cascade, dryRun, check)
+}
+
+// DeleteCascade deletes option set id and clears it from every attribute.
+func (r *OptionSetRepository) DeleteCascade(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var attributes []uint64
+		if err := tx.Model(&model.Attribute{}).Where("option_set_id = ?", id).Pluck("id", &attributes).Error; err != nil {
+			return err
+		}
+		for _, a := range attributes {
+			err := updateAudited[model.Attribute](ctx, tx, "attribute", a, map[string]interface{}{
+				"option_set_id": nil,
+				"updated_at":    gorm.Expr("CURRENT_TIMESTAMP"),
+				"version":       gorm.Expr("version + 1"),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return deleteAudited[model.OptionSet](ctx, tx, "option_set", uint64(id))
+	})
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting option set", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// repository/purge.go
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// purgeBatchSize bounds the rows removed in one transaction so that a large
+// backlog does not hold locks for long.
+const purgeBatchSize = 500
+
+// purgeAudited permanently removes up to purgeBatchSize rows of T that were
+// soft-deleted before cutoff, together with their rows in the join tables
+// (table name to the column that references T), and records each removal.
+// Rows matching guard, when set, are skipped. It returns the number of rows
+// removed; callers repeat until it returns zero.
+func purgeAudited[T any](ctx context.Context, db *gorm.DB, resource string, cutoff time.Time, guard string, joins map[string]string) (int64, error) {
+	var purged int64
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Unscoped().Model(new(T)).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+		if guard != "" {
+			q = q.Where(guard)
+		}
+		var ids []uint64
+		if err := q.Limit(purgeBatchSize).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		var rows []T
+		if err := tx.Unscoped().Find(&rows, ids).Error; err != nil {
+			return err
+		}
+		for i := range rows {
+			if err := writeAudit(ctx, tx, ActionPurge, resource, &rows[i], nil); err != nil {
+				return err
+			}
+		}
+		for table, column := range joins {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE "+column+" IN ?", ids).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Delete(new(T), ids).Error; err != nil {
+			return err
+		}
+		purged = int64(len(ids))
+		return nil
+	})
+	return purged, err
+}
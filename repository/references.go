@@ -0,0 +1,234 @@
+// repository/references.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"stellarsky.ai/platform/public-config-service/expr"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// ErrReferenced is wrapped by errors for a change that would leave a live form
+// referring by name to an attribute it no longer has.
+var ErrReferenced = errors.New("still referenced by name")
+
+// formsWith locks the live forms containing attribute id and returns them
+// with their live attributes.
+func formsWith(tx *gorm.DB, id uint64) ([]model.Form, error) {
+	var ids []uint64
+	err := tx.Model(&model.Form{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN (SELECT form_id FROM form_attributes WHERE attribute_id = ?)", id).
+		Order("id").Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	var forms []model.Form
+	err = tx.Preload("Attributes", liveOnly).Order("id").Find(&forms, ids).Error
+	return forms, err
+}
+
+// namedByOthers reports whether form f has an attribute other than id, or a
+// group, called name, so that its references to name are not to id.
+func namedByOthers(f *model.Form, id uint64, name string) bool {
+	for _, a := range f.Attributes {
+		if a.ID != id && a.Name == name {
+			return true
+		}
+	}
+	for _, g := range f.Groups {
+		if g.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renameInForms rewrites the pages, groups and conditions of the live forms
+// containing attribute id to refer to it as to instead of from.
+func renameInForms(ctx context.Context, tx *gorm.DB, id uint64, from, to string) error {
+	if from == to {
+		return nil
+	}
+	forms, err := formsWith(tx, id)
+	if err != nil {
+		return err
+	}
+	renamed := map[string]string{from: to}
+	for i := range forms {
+		f := &forms[i]
+		if namedByOthers(f, id, from) {
+			continue
+		}
+		refers, err := refersTo(f, from)
+		if err != nil || !refers {
+			return err
+		}
+		if namedByOthers(f, id, to) {
+			return fmt.Errorf("form %d refers to %q and already has an attribute or group called %q: %w", f.ID, from, to, ErrReferenced)
+		}
+		f.Pages = renamePages(f.Pages, renamed)
+		f.Groups = renameGroups(f.Groups, renamed)
+		if f.Conditions, err = renameConditions(f.Conditions, renamed); err != nil {
+			return err
+		}
+		if err := updateLayout(ctx, tx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detachFromForms removes attribute id from every form that contains it,
+// together with its place on their pages and in their groups and its own
+// conditions. It fails with ErrReferenced while a form's conditions or page
+// navigation read the attribute's answer, or it is the last attribute of a
+// group.
+func detachFromForms(ctx context.Context, tx *gorm.DB, id uint64) error {
+	var a model.Attribute
+	if err := tx.Unscoped().Select("id", "name").First(&a, id).Error; err != nil {
+		return err
+	}
+	forms, err := formsWith(tx, id)
+	if err != nil {
+		return err
+	}
+	for i := range forms {
+		f := &forms[i]
+		if namedByOthers(f, id, a.Name) {
+			continue
+		}
+		changed, err := dropReferences(f, a.Name)
+		if err != nil {
+			return err
+		}
+		if changed {
+			if err := updateLayout(ctx, tx, f); err != nil {
+				return err
+			}
+		}
+	}
+	return detachAudited(ctx, tx, formAttributes, id)
+}
+
+// dropReferences removes name from f's pages and groups and drops its
+// conditions, reporting whether f changed.
+func dropReferences(f *model.Form, name string) (bool, error) {
+	for attribute, c := range f.Conditions {
+		if attribute == name {
+			continue
+		}
+		for _, src := range []string{c.VisibleIf, c.RequiredIf} {
+			names, err := exprNames(src)
+			if err != nil {
+				return false, err
+			}
+			for _, n := range names {
+				if n == name {
+					return false, fmt.Errorf("form %d: the conditions of %q read %q: %w", f.ID, attribute, name, ErrReferenced)
+				}
+			}
+		}
+	}
+	changed := false
+	if _, ok := f.Conditions[name]; ok {
+		delete(f.Conditions, name)
+		changed = true
+	}
+	for i := range f.Pages {
+		p := &f.Pages[i]
+		for _, rule := range p.Navigation {
+			if rule.When.Attribute == name {
+				return false, fmt.Errorf("form %d: the navigation of page %q reads %q: %w", f.ID, p.Name, name, ErrReferenced)
+			}
+		}
+		if kept := without(p.Attributes, name); len(kept) != len(p.Attributes) {
+			p.Attributes, changed = kept, true
+		}
+	}
+	for i := range f.Groups {
+		g := &f.Groups[i]
+		kept := without(g.Attributes, name)
+		if len(kept) == len(g.Attributes) {
+			continue
+		}
+		if len(kept) == 0 {
+			return false, fmt.Errorf("form %d: %q is the last attribute of group %q: %w", f.ID, name, g.Name, ErrReferenced)
+		}
+		g.Attributes, changed = kept, true
+	}
+	return changed, nil
+}
+
+// refersTo reports whether f's pages, groups or conditions mention name.
+func refersTo(f *model.Form, name string) (bool, error) {
+	if _, ok := f.Conditions[name]; ok {
+		return true, nil
+	}
+	for _, c := range f.Conditions {
+		for _, src := range []string{c.VisibleIf, c.RequiredIf} {
+			names, err := exprNames(src)
+			if err != nil {
+				return false, err
+			}
+			for _, n := range names {
+				if n == name {
+					return true, nil
+				}
+			}
+		}
+	}
+	for _, p := range f.Pages {
+		for _, rule := range p.Navigation {
+			if rule.When.Attribute == name {
+				return true, nil
+			}
+		}
+		if len(without(p.Attributes, name)) != len(p.Attributes) {
+			return true, nil
+		}
+	}
+	for _, g := range f.Groups {
+		if len(without(g.Attributes, name)) != len(g.Attributes) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// exprNames returns the attribute names condition src reads; none when it is
+// empty.
+func exprNames(src string) ([]string, error) {
+	if src == "" {
+		return nil, nil
+	}
+	e, err := expr.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Names(), nil
+}
+
+func without(names []string, name string) []string {
+	kept := make([]string, 0, len(names))
+	for _, n := range names {
+		if n != name {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+// updateLayout writes f's pages, groups and conditions.
+func updateLayout(ctx context.Context, tx *gorm.DB, f *model.Form) error {
+	return updateAudited[model.Form](ctx, tx, "form", f.ID, map[string]interface{}{
+		"pages":      f.Pages,
+		"conditions": f.Conditions,
+		"groups":     f.Groups,
+		"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		"version":    gorm.Expr("version + 1"),
+	})
+}
@@ -0,0 +1,17 @@
+// repository/scopes.go
+package repository
+
+import "gorm.io/gorm"
+
+// liveOnly is a preload condition that keeps soft-deleted associations out of
+// results even when the parent query includes deleted rows.
+const liveOnly = "deleted_at IS NULL"
+
+// withDeleted widens q to soft-deleted rows when includeDeleted is set. GORM
+// otherwise limits every query on a model with DeletedAt to live rows.
+func withDeleted(q *gorm.DB, includeDeleted bool) *gorm.DB {
+	if includeDeleted {
+		return q.Unscoped()
+	}
+	return q
+}
@@ -0,0 +1,43 @@
+// repository/stats_repository.go
+package repository
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+type StatsRepository struct {
+	db     *gorm.DB
+	logger *slog.Logger
+}
+
+func NewStatsRepository(db *gorm.DB, logger *slog.Logger) *StatsRepository {
+	return &StatsRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// CountLive returns the number of live types, validations, option sets,
+// attributes and forms in each namespace.
+func (r *StatsRepository) CountLive(ctx context.Context) ([]model.NamespaceCount, error) {
+	var counts []model.NamespaceCount
+	result := r.db.WithContext(ctx).Raw(`
+		SELECT 'type' AS resource, namespace, COUNT(*) AS count FROM types WHERE deleted_at IS NULL GROUP BY namespace
+		UNION ALL
+		SELECT 'validation', namespace, COUNT(*) FROM validations WHERE deleted_at IS NULL GROUP BY namespace
+		UNION ALL
+		SELECT 'option_set', namespace, COUNT(*) FROM option_sets WHERE deleted_at IS NULL GROUP BY namespace
+		UNION ALL
+		SELECT 'attribute', namespace, COUNT(*) FROM attributes WHERE deleted_at IS NULL GROUP BY namespace
+		UNION ALL
+		SELECT 'form', namespace, COUNT(*) FROM forms WHERE deleted_at IS NULL GROUP BY namespace`).Scan(&counts)
+	if result.Error != nil {
+		r.logger.ErrorContext(ctx, "error counting live entities", slog.Any("error", result.Error))
+		return nil, result.Error
+	}
+	return counts, nil
+}
@@ -3,12 +3,18 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"golang.org/x/exp/slog"
 	"gorm.io/gorm"
 	"stellarsky.ai/platform/public-config-service/model"
 )
 
+// MaxTypeDepth bounds how many types a type and its ancestors may chain.
+const MaxTypeDepth = 8
+
 type TypeRepository struct {
 	db     *gorm.DB
 	logger *slog.Logger
@@ -21,66 +27,256 @@ func NewTypeRepository(db *gorm.DB, logger *slog.Logger) *TypeRepository {
 	}
 }
 
-func (r *TypeRepository) GetAll(ctx context.Context) ([]model.Type, error) {
+// Transaction runs fn with a copy of the repository whose queries all run in
+// one database transaction, which is rolled back if fn returns an error.
+func (r *TypeRepository) Transaction(ctx context.Context, fn func(*TypeRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewTypeRepository(tx, r.logger))
+	})
+}
+
+func (r *TypeRepository) GetAll(ctx context.Context, includeDeleted bool) ([]model.Type, error) {
 	var types []model.Type
-	result := r.db.WithContext(ctx).Where("deleted_at IS NULL").Find(&types)
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).
+		Preload("Parent", liveOnly).
+		Preload("Validations", liveOnly).
+		Find(&types)
 	if result.Error != nil {
-		r.logger.Error("error querying all types", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying all types", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return types, nil
 }
 
-func (r *TypeRepository) GetByID(ctx context.Context, id int64) (*model.Type, error) {
+func (r *TypeRepository) GetByID(ctx context.Context, id int64, includeDeleted bool) (*model.Type, error) {
 	var t model.Type
-	result := r.db.WithContext(ctx).First(&t, "id = ? AND deleted_at IS NULL", id)
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).
+		Preload("Parent", liveOnly).
+		Preload("Validations", liveOnly).
+		First(&t, "types.id = ?", id)
 	if result.Error == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
 	if result.Error != nil {
-		r.logger.Error("error querying type by id", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying type by id", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return &t, nil
 }
 
 func (r *TypeRepository) Create(ctx context.Context, t *model.Type) error {
-	result := r.db.WithContext(ctx).Create(t)
-	if result.Error != nil {
-		r.logger.Error("error creating type", slog.Any("error", result.Error))
-		return result.Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createType(ctx, tx, t)
+	})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error creating type", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
+// Update writes t. Its validations are replaced when t lists any, or an
+// empty list, and kept when Validations is nil.
 func (r *TypeRepository) Update(ctx context.Context, t *model.Type) error {
-	result := r.db.WithContext(ctx).Model(t).Updates(map[string]interface{}{
-		"namespace":    t.Namespace,
-		"family":       t.Family,
-		"name":         t.Name,
-		"element_type": t.ElementType,
-		"widget_type":  t.WidgetType,
-		"updated_at":   gorm.Expr("CURRENT_TIMESTAMP"),
-		"version":      gorm.Expr("version + 1"),
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := updateAudited[model.Type](ctx, tx, "type", t.ID, map[string]interface{}{
+			"namespace":       t.Namespace,
+			"family":          t.Family,
+			"name":            t.Name,
+			"element_type":    t.ElementType,
+			"widget_type":     t.WidgetType,
+			"parent_id":       t.ParentID,
+			"design_schema":   t.DesignSchema,
+			"design_defaults": t.DesignDefaults,
+			"updated_at":      gorm.Expr("CURRENT_TIMESTAMP"),
+			"version":         gorm.Expr("version + 1"),
+		})
+		if err != nil || t.Validations == nil {
+			return err
+		}
+		ids := make([]uint64, 0, len(t.Validations))
+		for _, v := range t.Validations {
+			ids = append(ids, v.ID)
+		}
+		_, err = replaceLinksAudited(ctx, tx, typeValidations, t.ID, ids)
+		return err
 	})
-	if result.Error != nil {
-		r.logger.Error("error updating type", slog.Any("error", result.Error))
-		return result.Error
+	if err == gorm.ErrRecordNotFound {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating type", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
 func (r *TypeRepository) Delete(ctx context.Context, id int64) error {
-	result := r.db.WithContext(ctx).Model(&model.Type{}).Where("id = ? AND deleted_at IS NULL", id).Update("deleted_at", gorm.Expr("CURRENT_TIMESTAMP"))
-	if result.Error != nil {
-		r.logger.Error("error deleting type", slog.Any("error", result.Error))
-		return result.Error
+	err := deleteAudited[model.Type](ctx, r.db, "type", uint64(id))
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting type", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *TypeRepository) Restore(ctx context.Context, id int64) error {
+	err := restoreAudited[model.Type](ctx, r.db, "type", uint64(id))
+	if err == gorm.ErrRecordNotFound || err == gorm.ErrDuplicatedKey {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error restoring type", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// Purge permanently removes a batch of types soft-deleted before cutoff. Types
+// still referenced by an attribute or extended by a type, even a deleted one,
+// are kept.
+func (r *TypeRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	n, err := purgeAudited[model.Type](ctx, r.db, "type", cutoff,
+		"NOT EXISTS (SELECT 1 FROM attributes WHERE attributes.type_id = types.id) AND "+
+			"NOT EXISTS (SELECT 1 FROM types c WHERE c.parent_id = types.id)",
+		map[string]string{"type_validations": "type_id"})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error purging types", slog.Any("error", err))
+		return 0, err
+	}
+	return n, nil
+}
+
+// Usages lists the live types extending type id, its live attributes and the
+// forms containing them.
+func (r *TypeRepository) Usages(ctx context.Context, id int64) (*model.Usages, error) {
+	u, err := usages(r.db.WithContext(ctx), r.logger, "type", "a.type_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Types, err = liveTypeRefs(r.db.WithContext(ctx), "t.parent_id = ?", id); err != nil {
+		r.logger.ErrorContext(ctx, "error querying type usages", slog.Any("error", err))
+		return nil, err
+	}
+	return u, nil
+}
+
+// Lineage returns live type id followed by its live ancestors, nearest
+// first, each with its validations. It returns nil if type id is not live,
+// and stops at a deleted ancestor.
+func (r *TypeRepository) Lineage(ctx context.Context, id uint64) ([]model.Type, error) {
+	return typeLineage(r.db.WithContext(ctx), r.logger, id, false)
+}
+
+// LockedLineage is Lineage for a transaction about to make a type extend type
+// id. It locks each type of the lineage FOR SHARE, so that none of them can be
+// deleted or made to extend another type before the transaction ends.
+func (r *TypeRepository) LockedLineage(ctx context.Context, id uint64) ([]model.Type, error) {
+	return typeLineage(r.db.WithContext(ctx), r.logger, id, true)
+}
+
+// Lock locks live type id FOR UPDATE until the transaction ends. It returns
+// gorm.ErrRecordNotFound when type id is not live.
+func (r *TypeRepository) Lock(ctx context.Context, id uint64) error {
+	return lockLive(r.db.WithContext(ctx), "types", "UPDATE", id)
+}
+
+// DescendantDepth returns how many generations of live types extend type id,
+// 0 when none does, looking no further than MaxTypeDepth generations.
+func (r *TypeRepository) DescendantDepth(ctx context.Context, id uint64) (int, error) {
+	var depth int
+	err := r.db.WithContext(ctx).Raw(`WITH RECURSIVE descendants (id, depth) AS (
+			SELECT id, 1 FROM types WHERE parent_id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT t.id, d.depth + 1 FROM types t JOIN descendants d ON t.parent_id = d.id
+			WHERE t.deleted_at IS NULL AND d.depth < ?)
+		SELECT COALESCE(MAX(depth), 0) FROM descendants`, id, MaxTypeDepth).Scan(&depth).Error
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error querying type descendants", slog.Any("error", err))
+		return 0, err
+	}
+	return depth, nil
+}
+
+func typeLineage(db *gorm.DB, logger *slog.Logger, id uint64, lock bool) ([]model.Type, error) {
+	var lineage []model.Type
+	seen := map[uint64]bool{}
+	for next := &id; next != nil; next = lineage[len(lineage)-1].ParentID {
+		if seen[*next] || len(lineage) > MaxTypeDepth {
+			return nil, fmt.Errorf("type %d extends itself or more than %d types", id, MaxTypeDepth)
+		}
+		seen[*next] = true
+		if lock {
+			err := lockLive(db, "types", "SHARE", *next)
+			if err == gorm.ErrRecordNotFound {
+				break
+			}
+			if err != nil {
+				logger.ErrorContext(db.Statement.Context, "error locking type lineage", slog.Any("error", err))
+				return nil, err
+			}
+		}
+		var t model.Type
+		err := db.Preload("Validations", liveOnly).First(&t, "types.id = ?", *next).Error
+		if err == gorm.ErrRecordNotFound {
+			break
+		}
+		if err != nil {
+			logger.ErrorContext(db.Statement.Context, "error querying type lineage", slog.Any("error", err))
+			return nil, err
+		}
+		lineage = append(lineage, t)
+	}
+	return lineage, nil
+}
+
+// DeleteChecked deletes type id as DeleteCascade does when cascade is set
+// and as Delete does otherwise, once check accepts what Usages lists. The type
+// stays locked from listing its usages until it is deleted. With dryRun only
+// the usages are listed and checked.
+func (r *TypeRepository) DeleteChecked(ctx context.Context, id int64, cascade, dryRun bool,
+	check func(*model.Usages) error) (*model.Usages, error) {
+	bind := func(tx *gorm.DB) deleter { return NewTypeRepository(tx, r.logger) }
+	return deleteChecked[model.Type](ctx, r.db, bind, id, cascade, dryRun, check)
+}
+
+// DeleteCascade deletes type id together with its attributes, which are also
+// removed from every form as detachFromForms does. Types extending it are
+// left extending nothing.
+func (r *TypeRepository) DeleteCascade(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var children []uint64
+		if err := tx.Model(&model.Type{}).Where("parent_id = ?", id).Pluck("id", &children).Error; err != nil {
+			return err
+		}
+		for _, c := range children {
+			if err := updateAudited[model.Type](ctx, tx, "type", c, map[string]interface{}{"parent_id": nil}); err != nil {
+				return err
+			}
+		}
+		var attributes []uint64
+		if err := tx.Model(&model.Attribute{}).Where("type_id = ?", id).Pluck("id", &attributes).Error; err != nil {
+			return err
+		}
+		for _, a := range attributes {
+			if err := detachFromForms(ctx, tx, a); err != nil {
+				return err
+			}
+			if err := deleteAudited[model.Attribute](ctx, tx, "attribute", a); err != nil {
+				return err
+			}
+		}
+		return deleteAudited[model.Type](ctx, tx, "type", uint64(id))
+	})
+	if err == gorm.ErrRecordNotFound || errors.Is(err, ErrReferenced) {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting type", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
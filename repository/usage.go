@@ -0,0 +1,240 @@
+// repository/usage.go
+package repository
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// link describes a join table between an owner and its members, such as a
+// form and its attributes.
+type link struct {
+	table         string // join table
+	ownerTable    string
+	ownerColumn   string // column in table referencing the owner
+	memberTable   string
+	memberColumn  string // column in table referencing the member
+	ownerResource string // audit resource name of the owner
+	field         string // name of the member list in audit snapshots
+}
+
+var (
+	formAttributes = link{
+		table:         "form_attributes",
+		ownerTable:    "forms",
+		ownerColumn:   "form_id",
+		memberTable:   "attributes",
+		memberColumn:  "attribute_id",
+		ownerResource: "form",
+		field:         "AttributeIDs",
+	}
+	attributeValidations = link{
+		table:         "attribute_validations",
+		ownerTable:    "attributes",
+		ownerColumn:   "attribute_id",
+		memberTable:   "validations",
+		memberColumn:  "validation_id",
+		ownerResource: "attribute",
+		field:         "ValidationIDs",
+	}
+	typeValidations = link{
+		table:         "type_validations",
+		ownerTable:    "types",
+		ownerColumn:   "type_id",
+		memberTable:   "validations",
+		memberColumn:  "validation_id",
+		ownerResource: "type",
+		field:         "ValidationIDs",
+	}
+)
+
+// detachAudited removes member from every owner that links to it and records
+// an update of each owner's member list.
+func detachAudited(ctx context.Context, tx *gorm.DB, l link, member uint64) error {
+	var owners []uint64
+	if err := tx.Table(l.table).Where(l.memberColumn+" = ?", member).Pluck(l.ownerColumn, &owners).Error; err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		before, err := l.snapshot(tx, owner)
+		if err != nil {
+			return err
+		}
+		err = tx.Exec("DELETE FROM "+l.table+" WHERE "+l.ownerColumn+" = ? AND "+l.memberColumn+" = ?", owner, member).Error
+		if err != nil {
+			return err
+		}
+		after, err := l.snapshot(tx, owner)
+		if err != nil {
+			return err
+		}
+		if err := writeAudit(ctx, tx, ActionUpdate, l.ownerResource, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceLinksAudited makes members the exact set linked to owner, recording
+// an update of the owner's member list when it changes. It reports whether
+// anything changed. Members it links are locked FOR SHARE, and it fails with
+// ErrMissingReference when one is not live.
+func replaceLinksAudited(ctx context.Context, tx *gorm.DB, l link, owner uint64, members []uint64) (bool, error) {
+	before, err := l.snapshot(tx, owner)
+	if err != nil {
+		return false, err
+	}
+	want := map[uint64]bool{}
+	for _, m := range members {
+		want[m] = true
+	}
+	have := map[uint64]bool{}
+	for _, m := range before[l.field].([]uint64) {
+		have[m] = true
+	}
+	var added []uint64
+	for m := range want {
+		if !have[m] {
+			added = append(added, m)
+		}
+	}
+	if err := lockReferenced(tx, l.memberTable, added...); err != nil {
+		return false, err
+	}
+	changed := false
+	for m := range have {
+		if !want[m] {
+			err := tx.Exec("DELETE FROM "+l.table+" WHERE "+l.ownerColumn+" = ? AND "+l.memberColumn+" = ?", owner, m).Error
+			if err != nil {
+				return false, err
+			}
+			changed = true
+		}
+	}
+	for m := range want {
+		if !have[m] {
+			err := tx.Exec("INSERT INTO "+l.table+" ("+l.ownerColumn+", "+l.memberColumn+") VALUES (?, ?)", owner, m).Error
+			if err != nil {
+				return false, err
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	after, err := l.snapshot(tx, owner)
+	if err != nil {
+		return false, err
+	}
+	return true, writeAudit(ctx, tx, ActionUpdate, l.ownerResource, before, after)
+}
+
+// snapshot returns the owner's natural key and member list for the audit log.
+func (l link) snapshot(tx *gorm.DB, owner uint64) (map[string]interface{}, error) {
+	var ref model.UsageRef
+	if err := tx.Table(l.ownerTable).Select("id, namespace, family, name").Where("id = ?", owner).Take(&ref).Error; err != nil {
+		return nil, err
+	}
+	var members []uint64
+	if err := tx.Table(l.table).Where(l.ownerColumn+" = ?", owner).Order(l.memberColumn).Pluck(l.memberColumn, &members).Error; err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"ID":        ref.ID,
+		"Namespace": ref.Namespace,
+		"Family":    ref.Family,
+		"Name":      ref.Name,
+		l.field:     members,
+	}, nil
+}
+
+// liveTypeRefs returns the live types matching the condition, which may refer
+// to the types table as t.
+func liveTypeRefs(tx *gorm.DB, query string, args ...interface{}) ([]model.UsageRef, error) {
+	refs := []model.UsageRef{}
+	err := tx.Table("types t").Select("t.id, t.namespace, t.family, t.name").
+		Where("t.deleted_at IS NULL").Where(query, args...).Order("t.id").Scan(&refs).Error
+	return refs, err
+}
+
+// liveAttributeRefs returns the live attributes matching the condition, which
+// may refer to the attributes table as a.
+func liveAttributeRefs(tx *gorm.DB, query string, args ...interface{}) ([]model.UsageRef, error) {
+	refs := []model.UsageRef{}
+	err := tx.Table("attributes a").Select("a.id, a.namespace, a.family, a.name").
+		Where("a.deleted_at IS NULL").Where(query, args...).Order("a.id").Scan(&refs).Error
+	return refs, err
+}
+
+// liveFormRefs returns the live forms containing any live attribute matching
+// the condition, which may refer to the attributes table as a.
+func liveFormRefs(tx *gorm.DB, query string, args ...interface{}) ([]model.UsageRef, error) {
+	refs := []model.UsageRef{}
+	err := tx.Table("forms f").Distinct("f.id, f.namespace, f.family, f.name").
+		Joins("JOIN form_attributes fa ON fa.form_id = f.id").
+		Joins("JOIN attributes a ON a.id = fa.attribute_id AND a.deleted_at IS NULL").
+		Where("f.deleted_at IS NULL").Where(query, args...).Order("f.id").Scan(&refs).Error
+	return refs, err
+}
+
+// usages lists the live attributes matching the condition and the live forms
+// containing them.
+func usages(db *gorm.DB, logger *slog.Logger, resource, query string, args ...interface{}) (*model.Usages, error) {
+	attributes, err := liveAttributeRefs(db, query, args...)
+	if err != nil {
+		logger.ErrorContext(db.Statement.Context, "error querying "+resource+" usages", slog.Any("error", err))
+		return nil, err
+	}
+	forms, err := liveFormRefs(db, query, args...)
+	if err != nil {
+		logger.ErrorContext(db.Statement.Context, "error querying "+resource+" usages", slog.Any("error", err))
+		return nil, err
+	}
+	return &model.Usages{Attributes: attributes, Forms: forms}, nil
+}
+
+// deleter is implemented by the repositories of entities that other entities
+// depend on.
+type deleter interface {
+	Usages(ctx context.Context, id int64) (*model.Usages, error)
+	Delete(ctx context.Context, id int64) error
+	DeleteCascade(ctx context.Context, id int64) error
+}
+
+// deleteChecked locks live row id of T, lists its usages and passes them to
+// check, then deletes the row, with its dependents when cascade is set,
+// unless check fails or dryRun is set. It runs in one transaction, with bind
+// giving the repository that works in it, so that the usages check cannot go
+// stale before the delete. It returns gorm.ErrRecordNotFound when there is no
+// live row.
+func deleteChecked[T any](ctx context.Context, db *gorm.DB, bind func(tx *gorm.DB) deleter, id int64,
+	cascade, dryRun bool, check func(*model.Usages) error) (*model.Usages, error) {
+	var usages *model.Usages
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var row T
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&row, id).Error; err != nil {
+			return err
+		}
+		repo := bind(tx)
+		var err error
+		if usages, err = repo.Usages(ctx, id); err != nil {
+			return err
+		}
+		if err := check(usages); err != nil || dryRun {
+			return err
+		}
+		if cascade {
+			return repo.DeleteCascade(ctx, id)
+		}
+		return repo.Delete(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
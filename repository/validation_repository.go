@@ -3,6 +3,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"stellarsky.ai/platform/public-config-service/model"
 
@@ -22,66 +23,145 @@ func NewValidationRepository(db *gorm.DB, logger *slog.Logger) *ValidationReposi
 	}
 }
 
-func (r *ValidationRepository) GetAll(ctx context.Context) ([]model.Validation, error) {
+// Transaction runs fn with a copy of the repository whose queries all run in
+// one database transaction, which is rolled back if fn returns an error.
+func (r *ValidationRepository) Transaction(ctx context.Context, fn func(*ValidationRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewValidationRepository(tx, r.logger))
+	})
+}
+
+func (r *ValidationRepository) GetAll(ctx context.Context, includeDeleted bool) ([]model.Validation, error) {
 	var validations []model.Validation
-	result := r.db.WithContext(ctx).Where("deleted_at IS NULL").Find(&validations)
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).Find(&validations)
 	if result.Error != nil {
-		r.logger.Error("error querying all validations", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying all validations", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return validations, nil
 }
 
-func (r *ValidationRepository) GetByID(ctx context.Context, id int64) (*model.Validation, error) {
+func (r *ValidationRepository) GetByID(ctx context.Context, id int64, includeDeleted bool) (*model.Validation, error) {
 	var v model.Validation
-	result := r.db.WithContext(ctx).First(&v, "id = ? AND deleted_at IS NULL", id)
+	result := withDeleted(r.db.WithContext(ctx), includeDeleted).First(&v, "id = ?", id)
 	if result.Error == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
 	if result.Error != nil {
-		r.logger.Error("error querying validation by id", slog.Any("error", result.Error))
+		r.logger.ErrorContext(ctx, "error querying validation by id", slog.Any("error", result.Error))
 		return nil, result.Error
 	}
 	return &v, nil
 }
 
 func (r *ValidationRepository) Create(ctx context.Context, v *model.Validation) error {
-	result := r.db.WithContext(ctx).Create(v)
-	if result.Error != nil {
-		r.logger.Error("error creating validation", slog.Any("error", result.Error))
-		return result.Error
+	if err := createAudited(ctx, r.db, "validation", v); err != nil {
+		r.logger.ErrorContext(ctx, "error creating validation", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
 func (r *ValidationRepository) Update(ctx context.Context, v *model.Validation) error {
-	result := r.db.WithContext(ctx).Model(v).Updates(map[string]interface{}{
+	err := updateAudited[model.Validation](ctx, r.db, "validation", v.ID, map[string]interface{}{
 		"namespace":         v.Namespace,
 		"family":            v.Family,
 		"name":              v.Name,
 		"rule_name":         v.RuleName,
 		"validation_params": v.ValidationParams,
+		"messages":          v.Messages,
 		"updated_at":        gorm.Expr("CURRENT_TIMESTAMP"),
 		"version":           gorm.Expr("version + 1"),
 	})
-	if result.Error != nil {
-		r.logger.Error("error updating validation", slog.Any("error", result.Error))
-		return result.Error
+	if err == gorm.ErrRecordNotFound {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error updating validation", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
 
 func (r *ValidationRepository) Delete(ctx context.Context, id int64) error {
-	result := r.db.WithContext(ctx).Model(&model.Validation{}).Where("id = ? AND deleted_at IS NULL", id).Update("deleted_at", gorm.Expr("CURRENT_TIMESTAMP"))
-	if result.Error != nil {
-		r.logger.Error("error deleting validation", slog.Any("error", result.Error))
-		return result.Error
+	err := deleteAudited[model.Validation](ctx, r.db, "validation", uint64(id))
+	if err == gorm.ErrRecordNotFound {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting validation", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (r *ValidationRepository) Restore(ctx context.Context, id int64) error {
+	err := restoreAudited[model.Validation](ctx, r.db, "validation", uint64(id))
+	if err == gorm.ErrRecordNotFound || err == gorm.ErrDuplicatedKey {
+		return err
+	}
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error restoring validation", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+// Purge permanently removes a batch of validations soft-deleted before cutoff.
+func (r *ValidationRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	n, err := purgeAudited[model.Validation](ctx, r.db, "validation", cutoff, "", map[string]string{"attribute_validations": "validation_id", "type_validations": "validation_id"})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error purging validations", slog.Any("error", err))
+		return 0, err
+	}
+	return n, nil
+}
+
+// Usages lists the live types and attributes bound to validation id and the
+// forms containing those attributes.
+func (r *ValidationRepository) Usages(ctx context.Context, id int64) (*model.Usages, error) {
+	u, err := usages(r.db.WithContext(ctx), r.logger, "validation",
+		"EXISTS (SELECT 1 FROM attribute_validations av WHERE av.attribute_id = a.id AND av.validation_id = ?)", id)
+	if err != nil {
+		return nil, err
+	}
+	u.Types, err = liveTypeRefs(r.db.WithContext(ctx),
+		"EXISTS (SELECT 1 FROM type_validations tv WHERE tv.type_id = t.id AND tv.validation_id = ?)", id)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error querying validation usages", slog.Any("error", err))
+		return nil, err
+	}
+	return u, nil
+}
+
+// DeleteChecked deletes validation id as DeleteCascade does when cascade is set
+// and as Delete does otherwise, once check accepts what Usages lists. The validation
+// stays locked from listing its usages until it is deleted. With dryRun only
+// the usages are listed and checked.
+func (r *ValidationRepository) DeleteChecked(ctx context.Context, id int64, cascade, dryRun bool,
+	check func(*model.Usages) error) (*model.Usages, error) {
+	bind := func(tx *gorm.DB) deleter { return NewValidationRepository(tx, r.logger) }
+	return deleteChecked[model.Validation](ctx, r.db, bind, id, cascade, dryRun, check)
+}
+
+// DeleteCascade deletes validation id and unbinds it from every type and
+// attribute.
+func (r *ValidationRepository) DeleteCascade(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := detachAudited(ctx, tx, typeValidations, uint64(id)); err != nil {
+			return err
+		}
+		if err := detachAudited(ctx, tx, attributeValidations, uint64(id)); err != nil {
+			return err
+		}
+		return deleteAudited[model.Validation](ctx, tx, "validation", uint64(id))
+	})
+	if err == gorm.ErrRecordNotFound {
+		return err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	if err != nil {
+		r.logger.ErrorContext(ctx, "error deleting validation", slog.Any("error", err))
+		return err
 	}
 	return nil
 }
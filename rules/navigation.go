@@ -0,0 +1,60 @@
+// rules/navigation.go
+package rules
+
+import "stellarsky.ai/platform/public-config-service/model"
+
+// Holds reports whether c holds for the answers in values.
+func Holds(c model.Condition, values map[string]interface{}) bool {
+	v := values[c.Attribute]
+	switch c.Op {
+	case model.OpEquals:
+		return Equal(v, c.Value)
+	case model.OpNotEquals:
+		return !Equal(v, c.Value)
+	case model.OpIn:
+		return in(v, c.Value)
+	case model.OpNotIn:
+		return !in(v, c.Value)
+	case model.OpSet:
+		return !Empty(v)
+	case model.OpUnset:
+		return Empty(v)
+	}
+	return false
+}
+
+// Next returns the index of the page that follows page current given values,
+// or len(pages) after the last.
+func Next(pages model.Pages, current int, values map[string]interface{}) int {
+	for _, rule := range pages[current].Navigation {
+		if !Holds(rule.When, values) {
+			continue
+		}
+		for i := current + 1; i < len(pages); i++ {
+			if pages[i].Name == rule.GoTo {
+				return i
+			}
+		}
+	}
+	return current + 1
+}
+
+// Equal compares answers by their text, so that 1 and "1" match, and treats
+// every unanswered value as equal to every other. Navigation conditions and
+// the expr package both compare with it.
+func Equal(a, b interface{}) bool {
+	if Empty(a) || Empty(b) {
+		return Empty(a) && Empty(b)
+	}
+	return Text(a) == Text(b)
+}
+
+func in(v, list interface{}) bool {
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		if Equal(v, item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+// rules/rules.go
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Params holds the ValidationParams a rule may read. A rule whose parameter
+// is missing passes, as the seed validations carry none.
+type Params struct {
+	Min     *float64
+	Max     *float64
+	Length  *int
+	Count   *int
+	Pattern *string
+	Value   *string
+}
+
+// Check reports whether value satisfies the rule named ruleName, configured by
+// the JSON rawParams, and describes the failure if not. Rules other than
+// required pass empty values, and rules this package does not know pass
+// everything: they are left to clients. A list is checked element by element.
+func Check(ruleName, rawParams string, value interface{}) (bool, string) {
+	if ruleName == "required" {
+		if Empty(value) {
+			return false, "is required"
+		}
+		return true, ""
+	}
+	if Empty(value) {
+		return true, ""
+	}
+	p, err := ParseParams(rawParams)
+	if err != nil {
+		return false, fmt.Sprintf("has invalid parameters for %s", ruleName)
+	}
+	if list, ok := value.([]interface{}); ok {
+		for _, v := range list {
+			if ok, msg := check(ruleName, &p, v); !ok {
+				return false, msg
+			}
+		}
+		return true, ""
+	}
+	return check(ruleName, &p, value)
+}
+
+// ParseParams decodes the JSON ValidationParams of a rule; empty ones set
+// nothing.
+func ParseParams(raw string) (Params, error) {
+	var p Params
+	if raw == "" {
+		return p, nil
+	}
+	err := json.Unmarshal([]byte(raw), &p)
+	return p, err
+}
+
+func check(ruleName string, p *Params, value interface{}) (bool, string) {
+	s := Text(value)
+	switch ruleName {
+	case "min_length":
+		if p.Min != nil && float64(utf8.RuneCountInString(s)) < *p.Min {
+			return false, fmt.Sprintf("must be at least %v characters", *p.Min)
+		}
+	case "max_length":
+		if p.Max != nil && float64(utf8.RuneCountInString(s)) > *p.Max {
+			return false, fmt.Sprintf("must be at most %v characters", *p.Max)
+		}
+	case "exact_length":
+		if p.Length != nil && utf8.RuneCountInString(s) != *p.Length {
+			return false, fmt.Sprintf("must be exactly %d characters", *p.Length)
+		}
+	case "email":
+		if a, err := mail.ParseAddress(s); err != nil || a.Address != s {
+			return false, "must be an email address"
+		}
+	case "numeric":
+		if _, ok := number(value); !ok {
+			return false, "must be a number"
+		}
+	case "alpha_only":
+		if !all(s, unicode.IsLetter) {
+			return false, "must contain only letters"
+		}
+	case "alpha_numeric":
+		if !all(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			return false, "must contain only letters and digits"
+		}
+	case "alpha_numeric_special":
+		if !all(s, func(r rune) bool { return unicode.IsPrint(r) }) {
+			return false, "must contain only letters, digits, spaces and punctuation"
+		}
+	case "match_pattern":
+		if p.Pattern != nil {
+			re, err := regexp.Compile(*p.Pattern)
+			if err != nil {
+				return false, "cannot be checked against an invalid pattern"
+			}
+			if !re.MatchString(s) {
+				return false, "does not match the required pattern"
+			}
+		}
+	case "valid_url":
+		if u, err := url.ParseRequestURI(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return false, "must be a URL"
+		}
+	case "valid_ip":
+		if net.ParseIP(s) == nil {
+			return false, "must be an IP address"
+		}
+	case "valid_credit_card":
+		if !luhn(s) {
+			return false, "must be a card number"
+		}
+	case "min_value":
+		if n, ok := number(value); p.Min != nil && (!ok || n < *p.Min) {
+			return false, fmt.Sprintf("must be at least %v", *p.Min)
+		}
+	case "max_value":
+		if n, ok := number(value); p.Max != nil && (!ok || n > *p.Max) {
+			return false, fmt.Sprintf("must be at most %v", *p.Max)
+		}
+	case "equals":
+		if p.Value != nil && s != *p.Value {
+			return false, fmt.Sprintf("must be %q", *p.Value)
+		}
+	case "equals_nocase":
+		if p.Value != nil && !strings.EqualFold(s, *p.Value) {
+			return false, fmt.Sprintf("must be %q", *p.Value)
+		}
+	case "min_words_count":
+		if p.Min != nil && float64(len(strings.Fields(s))) < *p.Min {
+			return false, fmt.Sprintf("must have at least %v words", *p.Min)
+		}
+	case "max_words_count":
+		if p.Max != nil && float64(len(strings.Fields(s))) > *p.Max {
+			return false, fmt.Sprintf("must have at most %v words", *p.Max)
+		}
+	case "exact_words_count":
+		if p.Count != nil && len(strings.Fields(s)) != *p.Count {
+			return false, fmt.Sprintf("must have exactly %d words", *p.Count)
+		}
+	}
+	return true, ""
+}
+
+// Empty reports whether value counts as unanswered.
+func Empty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// Text renders a decoded JSON value as the string rules compare.
+func Text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func number(value interface{}) (float64, bool) {
+	if n, ok := value.(float64); ok {
+		return n, true
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(Text(value)), 64)
+	return n, err == nil
+}
+
+func all(s string, f func(rune) bool) bool {
+	for _, r := range s {
+		if !f(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// luhn checks a card number of 12 to 19 digits, ignoring spaces and dashes.
+func luhn(s string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(s)
+	if len(digits) < 12 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if d < '0' || d > '9' {
+			return false
+		}
+		n := int(d - '0')
+		if i%2 == 1 {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
@@ -3,7 +3,7 @@ package service
 
 import (
 	"context"
-	"errors"
+	"fmt"
 
 	"golang.org/x/exp/slog"
 	"stellarsky.ai/platform/public-config-service/model"
@@ -22,47 +22,222 @@ func NewAttributeService(repo *repository.AttributeRepository, logger *slog.Logg
 	}
 }
 
-func (s *AttributeService) GetAllAttributes() ([]model.Attribute, error) {
-	attributes, err := s.repo.GetAll(context.Background())
+func (s *AttributeService) GetAllAttributes(ctx context.Context, includeDeleted bool) ([]model.Attribute, error) {
+	ctx, span := tracer.Start(ctx, "AttributeService.GetAllAttributes")
+	defer span.End()
+
+	attributes, err := s.repo.GetAll(ctx, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting all attributes", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting all attributes", slog.Any("error", err))
 		return nil, err
 	}
 	return attributes, nil
 }
 
-func (s *AttributeService) GetAttribute(id int64) (*model.Attribute, error) {
-	a, err := s.repo.GetByID(context.Background(), id)
+func (s *AttributeService) GetAttribute(ctx context.Context, id int64, includeDeleted bool) (*model.Attribute, error) {
+	ctx, span := tracer.Start(ctx, "AttributeService.GetAttribute")
+	defer span.End()
+
+	a, err := s.repo.GetByID(ctx, id, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting attribute by id", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting attribute by id", slog.Any("error", err))
 		return nil, err
 	}
 	if a == nil {
-		return nil, errors.New("attribute not found")
+		return nil, fmt.Errorf("attribute %w", ErrNotFound)
 	}
 	return a, nil
 }
 
-func (s *AttributeService) CreateAttribute(a *model.Attribute) error {
-	if err := s.repo.Create(context.Background(), a); err != nil {
-		s.logger.Error("error creating attribute", slog.Any("error", err))
+func (s *AttributeService) CreateAttribute(ctx context.Context, a *model.Attribute) error {
+	ctx, span := tracer.Start(ctx, "AttributeService.CreateAttribute")
+	defer span.End()
+
+	if err := checkTranslations(a); err != nil {
+		return err
+	}
+	if err := s.checkChoices(ctx, a); err != nil {
+		return err
+	}
+	if a.TypeID == 0 {
+		// The attribute brings its own type, which is created with it.
+		lineage, err := checkType(ctx, &a.Type, s.repo.TypeLineage)
+		if err != nil {
+			return err
+		}
+		if err := checkLineageSpec(lineage, a.DesignSpec); err != nil {
+			return err
+		}
+	} else if err := s.checkDesignSpec(ctx, a.TypeID, a.DesignSpec); err != nil {
 		return err
 	}
+	if err := s.repo.Create(ctx, a); err != nil {
+		s.logger.ErrorContext(ctx, "error creating attribute", slog.Any("error", err))
+		return translate("attribute", err)
+	}
 	return nil
 }
 
-func (s *AttributeService) UpdateAttribute(a *model.Attribute) error {
-	if err := s.repo.Update(context.Background(), a); err != nil {
-		s.logger.Error("error updating attribute", slog.Any("error", err))
+// UpdateAttribute writes a, renaming it in the pages, groups and conditions of
+// its forms when its name changes. It fails with ErrConflict when one of them
+// already has another attribute or group of the new name.
+func (s *AttributeService) UpdateAttribute(ctx context.Context, a *model.Attribute) error {
+	ctx, span := tracer.Start(ctx, "AttributeService.UpdateAttribute")
+	defer span.End()
+
+	if err := checkTranslations(a); err != nil {
+		return err
+	}
+	if err := s.checkChoices(ctx, a); err != nil {
 		return err
 	}
+	// Updates keep the stored attribute's type.
+	stored, err := s.repo.GetByID(ctx, int64(a.ID), false)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		if err := s.checkDesignSpec(ctx, stored.TypeID, a.DesignSpec); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.Update(ctx, a); err != nil {
+		s.logger.ErrorContext(ctx, "error updating attribute", slog.Any("error", err))
+		return translate("attribute", err)
+	}
 	return nil
 }
 
-func (s *AttributeService) DeleteAttribute(id int64) error {
-	if err := s.repo.Delete(context.Background(), id); err != nil {
-		s.logger.Error("error deleting attribute", slog.Any("error", err))
+// checkDesignSpec rejects a design spec that does not conform to the design
+// type typeID has with what it inherits.
+func (s *AttributeService) checkDesignSpec(ctx context.Context, typeID uint64, spec string) error {
+	lineage, err := s.repo.TypeLineage(ctx, typeID)
+	if err != nil || len(lineage) == 0 {
+		return err
+	}
+	return checkLineageSpec(lineage, spec)
+}
+
+// checkLineageSpec checks spec against the first type of lineage with what it
+// inherits from the rest.
+func checkLineageSpec(lineage []model.Type, spec string) error {
+	t, err := inheritedType(lineage)
+	if err != nil {
 		return err
 	}
+	return checkDesignSpec(&t, spec)
+}
+
+// checkChoices rejects an attribute that takes its options from both an
+// option set and a data source, from an option set that does not exist or has
+// been deleted, or from an invalid data source.
+func (s *AttributeService) checkChoices(ctx context.Context, a *model.Attribute) error {
+	if a.DataSource != nil {
+		if a.OptionSetID != nil {
+			return fmt.Errorf("%w: an attribute takes its options from an option set or a data source, not both", ErrInvalid)
+		}
+		return checkDataSource(a.DataSource)
+	}
+	if a.OptionSetID == nil {
+		return nil
+	}
+	ok, err := s.repo.OptionSetExists(ctx, *a.OptionSetID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: option set %d does not exist", ErrInvalid, *a.OptionSetID)
+	}
 	return nil
 }
+
+// DeleteAttribute deletes attribute id according to opts and returns the
+// forms that contained it, or with opts.DryRun contain it. A restricted
+// delete of an attribute on a form fails with an *InUseError, and a cascading
+// one with ErrConflict while a form's conditions or page navigation read the
+// attribute or it is the last attribute of a group.
+func (s *AttributeService) DeleteAttribute(ctx context.Context, id int64, opts DeleteOptions) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "AttributeService.DeleteAttribute")
+	defer span.End()
+
+	usages, err := deleteWithUsages(ctx, s.repo, "attribute", id, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error deleting attribute", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// GetAttributeUsages lists the live forms containing attribute id.
+func (s *AttributeService) GetAttributeUsages(ctx context.Context, id int64) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "AttributeService.GetAttributeUsages")
+	defer span.End()
+
+	if _, err := s.GetAttribute(ctx, id, false); err != nil {
+		return nil, err
+	}
+	usages, err := s.repo.Usages(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting attribute usages", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// RestoreAttribute undeletes a soft-deleted attribute and returns it. It fails
+// with ErrConflict when the attribute is not deleted or a live attribute has
+// taken its name.
+func (s *AttributeService) RestoreAttribute(ctx context.Context, id int64) (*model.Attribute, error) {
+	ctx, span := tracer.Start(ctx, "AttributeService.RestoreAttribute")
+	defer span.End()
+
+	a, err := s.GetAttribute(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !a.DeletedAt.Valid {
+		return nil, fmt.Errorf("%w: attribute %d is not deleted", ErrConflict, id)
+	}
+	if err := s.repo.Restore(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error restoring attribute", slog.Any("error", err))
+		return nil, translate("attribute", err)
+	}
+	return s.GetAttribute(ctx, id, false)
+}
+
+// BatchAttributes applies ops in one transaction: either every operation
+// succeeds or none has any effect and a *BatchError reports which one failed.
+// Deletes are restricted, failing for an attribute that is still on a form.
+func (s *AttributeService) BatchAttributes(ctx context.Context, ops []model.BatchOperation[model.Attribute]) ([]model.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "AttributeService.BatchAttributes")
+	defer span.End()
+
+	if err := CheckBatch(ops); err != nil {
+		return nil, err
+	}
+	var results []model.BatchResult
+	err := s.repo.Transaction(ctx, func(repo *repository.AttributeRepository) error {
+		tx := NewAttributeService(repo, s.logger)
+		var err error
+		results, err = runBatch(ctx, ops, batchActions[model.Attribute]{
+			create: func(ctx context.Context, a *model.Attribute) (uint64, error) {
+				err := tx.CreateAttribute(ctx, a)
+				return a.ID, err
+			},
+			update: func(ctx context.Context, id uint64, a *model.Attribute) error {
+				a.ID = id
+				return tx.UpdateAttribute(ctx, a)
+			},
+			delete: func(ctx context.Context, id uint64) error {
+				_, err := tx.DeleteAttribute(ctx, int64(id), DeleteOptions{})
+				return err
+			},
+		})
+		return err
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error applying attribute batch", slog.Any("error", err))
+		return nil, err
+	}
+	return results, nil
+}
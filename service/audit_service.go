@@ -0,0 +1,49 @@
+// service/audit_service.go
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditService struct {
+	repo   *repository.AuditRepository
+	logger *slog.Logger
+}
+
+func NewAuditService(repo *repository.AuditRepository, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *AuditService) FindAuditEntries(ctx context.Context, f repository.AuditFilter) ([]model.AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.FindAuditEntries")
+	defer span.End()
+
+	if f.Limit == 0 {
+		f.Limit = defaultAuditLimit
+	}
+	if f.Limit < 0 || f.Limit > maxAuditLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalid, maxAuditLimit)
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return nil, fmt.Errorf("%w: since must be before until", ErrInvalid)
+	}
+	entries, err := s.repo.Find(ctx, f)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error finding audit entries", slog.Any("error", err))
+		return nil, err
+	}
+	return entries, nil
+}
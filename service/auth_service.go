@@ -0,0 +1,205 @@
+// service/auth_service.go
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/auth"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+type AuthService struct {
+	repo   *repository.AuthRepository
+	jwt    *auth.JWTVerifier
+	certs  *auth.CertificateMapper
+	logger *slog.Logger
+}
+
+// NewAuthService returns an AuthService. jwt may be nil, in which case every
+// bearer token that is not an API key is rejected; certs may be nil, in which
+// case client certificates are never accepted as credentials.
+func NewAuthService(repo *repository.AuthRepository, jwt *auth.JWTVerifier, certs *auth.CertificateMapper, logger *slog.Logger) *AuthService {
+	return &AuthService{
+		repo:   repo,
+		jwt:    jwt,
+		certs:  certs,
+		logger: logger,
+	}
+}
+
+// AuthenticateAPIKey resolves a plaintext API key to its principal and the
+// roles bound to it.
+func (s *AuthService) AuthenticateAPIKey(ctx context.Context, key string) (*auth.Principal, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.AuthenticateAPIKey")
+	defer span.End()
+
+	k, err := s.repo.GetAPIKeyByHash(ctx, auth.HashAPIKey(key))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error looking up api key", slog.Any("error", err))
+		return nil, err
+	}
+	if k == nil || (k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now())) {
+		return nil, auth.ErrInvalidCredentials
+	}
+	p := &auth.Principal{Subject: k.Principal, Method: "api_key", KeyID: k.ID}
+	if err := s.grantBoundRoles(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AuthenticateToken verifies a JWT and adds the roles bound to its subject to
+// those carried in the token.
+func (s *AuthService) AuthenticateToken(ctx context.Context, token string) (*auth.Principal, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.AuthenticateToken")
+	defer span.End()
+
+	if s.jwt == nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+	p, err := s.jwt.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.grantBoundRoles(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AuthenticateCertificate resolves a verified client certificate to the
+// service identity its subject is mapped to, with the roles bound to it.
+func (s *AuthService) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*auth.Principal, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.AuthenticateCertificate")
+	defer span.End()
+
+	identity, ok := s.certs.Identity(cert)
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	p := &auth.Principal{Subject: identity, Method: "mtls"}
+	if err := s.grantBoundRoles(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *AuthService) grantBoundRoles(ctx context.Context, p *auth.Principal) error {
+	bindings, err := s.repo.GetRoleBindingsByPrincipal(ctx, p.Subject)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting role bindings", slog.Any("error", err))
+		return err
+	}
+	for _, b := range bindings {
+		if role := auth.Role(b.Role); role.Valid() {
+			p.Grant(b.Namespace, role)
+		}
+	}
+	return nil
+}
+
+func (s *AuthService) GetAllAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GetAllAPIKeys")
+	defer span.End()
+
+	keys, err := s.repo.GetAllAPIKeys(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting all api keys", slog.Any("error", err))
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateAPIKey issues a new key for k.Principal and returns it. The key cannot
+// be recovered later.
+func (s *AuthService) CreateAPIKey(ctx context.Context, k *model.APIKey) (string, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.CreateAPIKey")
+	defer span.End()
+
+	if k.Principal == "" {
+		return "", fmt.Errorf("%w: principal is required", ErrInvalid)
+	}
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error generating api key", slog.Any("error", err))
+		return "", err
+	}
+	k.KeyHash = auth.HashAPIKey(key)
+	k.Prefix = key[:len(auth.APIKeyPrefix)+6]
+	if err := s.repo.CreateAPIKey(ctx, k); err != nil {
+		s.logger.ErrorContext(ctx, "error creating api key", slog.Any("error", err))
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *AuthService) DeleteAPIKey(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DeleteAPIKey")
+	defer span.End()
+
+	if err := s.repo.DeleteAPIKey(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error deleting api key", slog.Any("error", err))
+		return translate("api key", err)
+	}
+	return nil
+}
+
+func (s *AuthService) GetAllRoleBindings(ctx context.Context) ([]model.RoleBinding, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GetAllRoleBindings")
+	defer span.End()
+
+	bindings, err := s.repo.GetAllRoleBindings(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting all role bindings", slog.Any("error", err))
+		return nil, err
+	}
+	return bindings, nil
+}
+
+func (s *AuthService) GetRoleBinding(ctx context.Context, id int64) (*model.RoleBinding, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GetRoleBinding")
+	defer span.End()
+
+	b, err := s.repo.GetRoleBindingByID(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting role binding by id", slog.Any("error", err))
+		return nil, err
+	}
+	if b == nil {
+		return nil, fmt.Errorf("role binding %w", ErrNotFound)
+	}
+	return b, nil
+}
+
+func (s *AuthService) SaveRoleBinding(ctx context.Context, b *model.RoleBinding) error {
+	ctx, span := tracer.Start(ctx, "AuthService.SaveRoleBinding")
+	defer span.End()
+
+	if b.Principal == "" || b.Namespace == "" {
+		return fmt.Errorf("%w: principal and namespace are required", ErrInvalid)
+	}
+	if !auth.Role(b.Role).Valid() {
+		return fmt.Errorf("%w: unknown role %q", ErrInvalid, b.Role)
+	}
+	if err := s.repo.SaveRoleBinding(ctx, b); err != nil {
+		s.logger.ErrorContext(ctx, "error saving role binding", slog.Any("error", err))
+		return err
+	}
+	return nil
+}
+
+func (s *AuthService) DeleteRoleBinding(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DeleteRoleBinding")
+	defer span.End()
+
+	if err := s.repo.DeleteRoleBinding(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error deleting role binding", slog.Any("error", err))
+		return translate("role binding", err)
+	}
+	return nil
+}
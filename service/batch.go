@@ -0,0 +1,114 @@
+// service/batch.go
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// BatchError is returned when an operation aborts a batch. Results reports
+// the outcome of every operation.
+type BatchError struct {
+	Index   int
+	Err     error
+	Results []model.BatchResult
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// batchActions applies the operations of a batch of one resource. The
+// functions are called inside the batch's transaction.
+type batchActions[T any] struct {
+	create func(ctx context.Context, item *T) (uint64, error)
+	update func(ctx context.Context, id uint64, item *T) error
+	delete func(ctx context.Context, id uint64) error
+}
+
+// NewBatchError reports a batch aborted by operation index before any
+// operation was applied.
+func NewBatchError[T any](ops []model.BatchOperation[T], index int, err error) *BatchError {
+	return &BatchError{Index: index, Err: err, Results: abortedResults(ops, nil, index)}
+}
+
+// CheckBatch rejects an empty batch or malformed operations, reporting the
+// first with a *BatchError.
+func CheckBatch[T any](ops []model.BatchOperation[T]) error {
+	if len(ops) == 0 {
+		return fmt.Errorf("%w: a batch needs at least one operation", ErrInvalid)
+	}
+	for i, op := range ops {
+		var err error
+		switch op.Op {
+		case model.BatchCreate:
+			if op.Item == nil {
+				err = fmt.Errorf("%w: create needs an Item", ErrInvalid)
+			}
+		case model.BatchUpdate:
+			if op.ID == 0 || op.Item == nil {
+				err = fmt.Errorf("%w: update needs an ID and an Item", ErrInvalid)
+			}
+		case model.BatchDelete:
+			if op.ID == 0 {
+				err = fmt.Errorf("%w: delete needs an ID", ErrInvalid)
+			}
+		default:
+			err = fmt.Errorf("%w: unknown op %q", ErrInvalid, op.Op)
+		}
+		if err != nil {
+			return NewBatchError(ops, i, err)
+		}
+	}
+	return nil
+}
+
+// runBatch applies ops in order and stops at the first failure, returning a
+// *BatchError. The caller runs it in a transaction and rolls back on error.
+func runBatch[T any](ctx context.Context, ops []model.BatchOperation[T], a batchActions[T]) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, 0, len(ops))
+	for i, op := range ops {
+		r := model.BatchResult{Index: i, Op: op.Op, ID: op.ID}
+		var err error
+		switch op.Op {
+		case model.BatchCreate:
+			r.ID, err = a.create(ctx, op.Item)
+			r.Status = model.BatchCreated
+		case model.BatchUpdate:
+			err = a.update(ctx, op.ID, op.Item)
+			r.Status = model.BatchUpdated
+		case model.BatchDelete:
+			err = a.delete(ctx, op.ID)
+			r.Status = model.BatchDeleted
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err, Results: abortedResults(ops, results, i)}
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// abortedResults reports a batch aborted by operation failed, given the
+// results of the operations before it.
+func abortedResults[T any](ops []model.BatchOperation[T], done []model.BatchResult, failed int) []model.BatchResult {
+	results := make([]model.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = model.BatchResult{Index: i, Op: op.Op, ID: op.ID, Status: model.BatchSkipped}
+	}
+	for _, r := range done {
+		r.Status = model.BatchRolledBack
+		if r.Op == model.BatchCreate {
+			r.ID = 0
+		}
+		results[r.Index] = r
+	}
+	results[failed].Status = model.BatchFailed
+	return results
+}
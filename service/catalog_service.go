@@ -0,0 +1,158 @@
+// service/catalog_service.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/metrics"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+type CatalogService struct {
+	typeRepo       *repository.TypeRepository
+	validationRepo *repository.ValidationRepository
+	optionSetRepo  *repository.OptionSetRepository
+	attributeRepo  *repository.AttributeRepository
+	formRepo       *repository.FormRepository
+	repo           *repository.CatalogRepository
+	logger         *slog.Logger
+}
+
+func NewCatalogService(typeRepo *repository.TypeRepository, validationRepo *repository.ValidationRepository,
+	optionSetRepo *repository.OptionSetRepository, attributeRepo *repository.AttributeRepository, formRepo *repository.FormRepository,
+	repo *repository.CatalogRepository, logger *slog.Logger) *CatalogService {
+	return &CatalogService{
+		typeRepo:       typeRepo,
+		validationRepo: validationRepo,
+		optionSetRepo:  optionSetRepo,
+		attributeRepo:  attributeRepo,
+		formRepo:       formRepo,
+		repo:           repo,
+		logger:         logger,
+	}
+}
+
+// Export returns every live entity, or only those in namespace when it is
+// not empty.
+func (s *CatalogService) Export(ctx context.Context, namespace string) (*model.Catalog, error) {
+	ctx, span := tracer.Start(ctx, "CatalogService.Export")
+	defer span.End()
+
+	types, err := s.typeRepo.GetAll(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	validations, err := s.validationRepo.GetAll(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	optionSets, err := s.optionSetRepo.GetAll(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := s.attributeRepo.GetAll(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	forms, err := s.formRepo.GetAll(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Catalog{
+		Version:     model.CatalogFormatVersion,
+		ExportedAt:  time.Now().UTC(),
+		Types:       inNamespace(types, namespace, func(t model.Type) string { return t.Namespace }),
+		Validations: inNamespace(validations, namespace, func(v model.Validation) string { return v.Namespace }),
+		OptionSets:  inNamespace(optionSets, namespace, func(o model.OptionSet) string { return o.Namespace }),
+		Attributes:  inNamespace(attributes, namespace, func(a model.Attribute) string { return a.Namespace }),
+		Forms:       inNamespace(forms, namespace, func(f model.Form) string { return f.Namespace }),
+	}, nil
+}
+
+func inNamespace[T any](items []T, namespace string, of func(T) string) []T {
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		if namespace == "" || of(item) == namespace {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Import upserts c by natural key in a single transaction. A reference to an
+// entity that exists neither in c nor in the database fails the whole import
+// with ErrInvalid.
+func (s *CatalogService) Import(ctx context.Context, c *model.Catalog, dryRun bool) (*model.ImportReport, error) {
+	ctx, span := tracer.Start(ctx, "CatalogService.Import")
+	defer span.End()
+
+	if c.Version != model.CatalogFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported catalog version %d, expected %d", ErrInvalid, c.Version, model.CatalogFormatVersion)
+	}
+	types := map[[3]string]*model.Type{}
+	for i, t := range c.Types {
+		if err := checkDesign(&t); err != nil {
+			return nil, fmt.Errorf("type %s/%s/%s: %w", t.Namespace, t.Family, t.Name, err)
+		}
+		types[[3]string{t.Namespace, t.Family, t.Name}] = &c.Types[i]
+	}
+	for _, v := range c.Validations {
+		if err := checkMessages(&v); err != nil {
+			return nil, fmt.Errorf("validation %s/%s/%s: %w", v.Namespace, v.Family, v.Name, err)
+		}
+	}
+	for _, o := range c.OptionSets {
+		if err := checkOptions(o.Options); err != nil {
+			return nil, fmt.Errorf("option set %s/%s/%s: %w", o.Namespace, o.Family, o.Name, err)
+		}
+	}
+	for _, a := range c.Attributes {
+		if err := checkTranslations(&a); err != nil {
+			return nil, fmt.Errorf("attribute %s/%s/%s: %w", a.Namespace, a.Family, a.Name, err)
+		}
+		// Only design specs of types in the catalog that extend no other type
+		// are checked here.
+		if t, ok := types[[3]string{a.Type.Namespace, a.Type.Family, a.Type.Name}]; ok && t.Parent == nil {
+			if err := checkDesignSpec(t, a.DesignSpec); err != nil {
+				return nil, fmt.Errorf("attribute %s/%s/%s: %w", a.Namespace, a.Family, a.Name, err)
+			}
+		}
+		if a.DataSource != nil {
+			if err := checkDataSource(a.DataSource); err != nil {
+				return nil, fmt.Errorf("attribute %s/%s/%s: %w", a.Namespace, a.Family, a.Name, err)
+			}
+		}
+	}
+	for _, f := range c.Forms {
+		names := make([]string, 0, len(f.Attributes))
+		for _, a := range f.Attributes {
+			names = append(names, a.Name)
+		}
+		if err := checkLayout(&f, names); err != nil {
+			return nil, fmt.Errorf("form %s/%s/%s: %w", f.Namespace, f.Family, f.Name, err)
+		}
+	}
+	report, err := s.repo.Import(ctx, c, dryRun)
+	if errors.Is(err, repository.ErrMissingReference) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error importing catalog", slog.Any("error", err))
+		return nil, translate("catalog entity", err)
+	}
+	if !dryRun {
+		for resource, counts := range report.Resources {
+			metrics.Imports.WithLabelValues(resource, "created").Add(float64(counts.Created))
+			metrics.Imports.WithLabelValues(resource, "updated").Add(float64(counts.Updated))
+			metrics.Imports.WithLabelValues(resource, "unchanged").Add(float64(counts.Unchanged))
+		}
+		forms := report.Resources["form"]
+		metrics.Publishes.WithLabelValues("import").Add(float64(forms.Created + forms.Updated))
+	}
+	return report, nil
+}
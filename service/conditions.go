@@ -0,0 +1,55 @@
+// service/conditions.go
+package service
+
+import (
+	"fmt"
+
+	"stellarsky.ai/platform/public-config-service/expr"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// checkConditions rejects conditions on attributes not in names, the form's
+// attribute names, and expressions that do not parse or refer to anything
+// but the attribute's siblings.
+func checkConditions(conditions model.Conditions, names []string) error {
+	known := map[string]bool{}
+	for _, name := range names {
+		known[name] = true
+	}
+	for attribute, c := range conditions {
+		if !known[attribute] {
+			return fmt.Errorf("%w: conditions for %q, which is not one of the form's attributes", ErrInvalid, attribute)
+		}
+		for field, src := range map[string]string{"VisibleIf": c.VisibleIf, "RequiredIf": c.RequiredIf} {
+			if src == "" {
+				continue
+			}
+			e, err := expr.Parse(src)
+			if err != nil {
+				return fmt.Errorf("%w: %s of %q: %v", ErrInvalid, field, attribute, err)
+			}
+			for _, name := range e.Names() {
+				if name == attribute {
+					return fmt.Errorf("%w: %s of %q refers to the attribute itself", ErrInvalid, field, attribute)
+				}
+				if !known[name] {
+					return fmt.Errorf("%w: %s of %q refers to %q, which is not one of the form's attributes", ErrInvalid, field, attribute, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// holds evaluates a condition that checkConditions accepted; an empty one
+// holds.
+func holds(src string, values map[string]interface{}) bool {
+	if src == "" {
+		return true
+	}
+	e, err := expr.Parse(src)
+	if err != nil {
+		return false
+	}
+	return e.Eval(values)
+}
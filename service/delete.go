@@ -0,0 +1,69 @@
+// service/delete.go
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// DeleteMode chooses what happens to the entities that depend on one being
+// deleted.
+type DeleteMode int
+
+const (
+	// DeleteRestrict refuses to delete an entity that is still in use.
+	DeleteRestrict DeleteMode = iota
+	// DeleteCascade also deletes a type's attributes and detaches the types
+	// extending it, and unlinks a deleted validation or option set from its
+	// types and attributes or a deleted attribute from its forms.
+	DeleteCascade
+	// DeleteForce deletes the entity and leaves its dependents referring to it.
+	DeleteForce
+)
+
+type DeleteOptions struct {
+	Mode DeleteMode
+	// DryRun reports what the delete would affect without deleting anything.
+	DryRun bool
+}
+
+// InUseError is returned when a restricted delete finds dependents.
+type InUseError struct {
+	Resource string
+	ID       int64
+	Usages   *model.Usages
+}
+
+func (e *InUseError) Error() string {
+	return fmt.Sprintf("%s %d is used by %d types, %d attributes and %d forms; delete with cascade or force",
+		e.Resource, e.ID, len(e.Usages.Types), len(e.Usages.Attributes), len(e.Usages.Forms))
+}
+
+func (e *InUseError) Unwrap() error {
+	return ErrConflict
+}
+
+// usageRepository is implemented by the repositories of entities that other
+// entities depend on.
+type usageRepository interface {
+	DeleteChecked(ctx context.Context, id int64, cascade, dryRun bool, check func(*model.Usages) error) (*model.Usages, error)
+}
+
+// deleteWithUsages deletes entity id according to opts and returns the
+// dependents that were, or with DryRun would be, affected. The repository
+// checks the usages and deletes in one transaction, so nothing can come to
+// depend on a restricted delete in between.
+func deleteWithUsages(ctx context.Context, repo usageRepository, resource string, id int64, opts DeleteOptions) (*model.Usages, error) {
+	usages, err := repo.DeleteChecked(ctx, id, opts.Mode == DeleteCascade, opts.DryRun, func(u *model.Usages) error {
+		if opts.Mode == DeleteRestrict && !u.Empty() {
+			return &InUseError{Resource: resource, ID: id, Usages: u}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, translate(resource, err)
+	}
+	return usages, nil
+}
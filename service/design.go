@@ -0,0 +1,125 @@
+// service/design.go
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"stellarsky.ai/platform/public-config-service/jsonschema"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// maxDesignErrors caps the violations a rejected design spec reports.
+const maxDesignErrors = 5
+
+// checkDesign rejects a type whose DesignSchema is not a supported JSON
+// Schema, or whose DesignDefaults are not an object it allows. Defaults may
+// leave out required properties for attributes to supply.
+func checkDesign(t *model.Type) error {
+	var defaults interface{} = map[string]interface{}{}
+	if len(t.DesignDefaults) > 0 {
+		if err := json.Unmarshal(t.DesignDefaults, &defaults); err != nil {
+			return fmt.Errorf("%w: design defaults are not JSON", ErrInvalid)
+		}
+		if _, ok := defaults.(map[string]interface{}); !ok {
+			return fmt.Errorf("%w: design defaults must be an object", ErrInvalid)
+		}
+	}
+	if len(t.DesignSchema) == 0 {
+		return nil
+	}
+	schema, err := jsonschema.Compile(t.DesignSchema)
+	if err != nil {
+		return fmt.Errorf("%w: design %v", ErrInvalid, err)
+	}
+	if errs := schema.ValidatePartial(defaults); len(errs) > 0 {
+		return fmt.Errorf("%w: design defaults %s", ErrInvalid, describe(errs))
+	}
+	return nil
+}
+
+// checkDesignSpec rejects a design spec that, merged over the defaults of t,
+// does not conform to t's DesignSchema. Types without one accept any spec.
+func checkDesignSpec(t *model.Type, spec string) error {
+	if len(t.DesignSchema) == 0 {
+		return nil
+	}
+	schema, err := jsonschema.Compile(t.DesignSchema)
+	if err != nil {
+		return fmt.Errorf("%w: type %q has an invalid design schema: %v", ErrInvalid, t.Name, err)
+	}
+	merged, err := mergedDesign(t, spec)
+	if err != nil {
+		return err
+	}
+	if errs := schema.Validate(merged); len(errs) > 0 {
+		return fmt.Errorf("%w: design spec %s", ErrInvalid, describe(errs))
+	}
+	return nil
+}
+
+// designSpec returns spec merged over the defaults of t, as resolved
+// attributes carry it. A spec that cannot be merged is passed through.
+func designSpec(t *model.Type, spec string) []byte {
+	if len(t.DesignDefaults) == 0 {
+		return rawJSON(spec)
+	}
+	merged, err := mergedDesign(t, spec)
+	if err != nil {
+		return rawJSON(spec)
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return rawJSON(spec)
+	}
+	return b
+}
+
+func mergedDesign(t *model.Type, spec string) (interface{}, error) {
+	var doc interface{} = map[string]interface{}{}
+	if spec != "" {
+		if err := json.Unmarshal([]byte(spec), &doc); err != nil {
+			return nil, fmt.Errorf("%w: design spec is not JSON", ErrInvalid)
+		}
+	}
+	if len(t.DesignDefaults) == 0 {
+		return doc, nil
+	}
+	var defaults interface{}
+	if err := json.Unmarshal(t.DesignDefaults, &defaults); err != nil {
+		return nil, fmt.Errorf("%w: type %q has invalid design defaults", ErrInvalid, t.Name)
+	}
+	return mergeDesign(defaults, doc), nil
+}
+
+// mergeDesign returns spec over defaults: objects are merged property by
+// property, and any other value in spec replaces the default.
+func mergeDesign(defaults, spec interface{}) interface{} {
+	d, ok := defaults.(map[string]interface{})
+	s, isObject := spec.(map[string]interface{})
+	if !ok || !isObject {
+		return spec
+	}
+	merged := make(map[string]interface{}, len(d)+len(s))
+	for k, v := range d {
+		merged[k] = v
+	}
+	for k, v := range s {
+		merged[k] = mergeDesign(d[k], v)
+	}
+	return merged
+}
+
+// describe lists the first violations of a schema.
+func describe(errs []jsonschema.Error) string {
+	parts := make([]string, 0, maxDesignErrors+1)
+	for i, e := range errs {
+		if i == maxDesignErrors {
+			parts = append(parts, fmt.Sprintf("and %d more", len(errs)-i))
+			break
+		}
+		parts = append(parts, e.Error())
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,37 @@
+// service/errors.go
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+var (
+	// ErrNotFound is returned when an entity does not exist or has been deleted.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalid is wrapped by errors caused by input that fails validation.
+	ErrInvalid = errors.New("invalid request")
+	// ErrConflict is wrapped by errors caused by a clash with the current
+	// state, such as a live entity already holding a name.
+	ErrConflict = errors.New("conflict")
+)
+
+// translate maps repository errors onto the errors above; others are returned
+// unchanged.
+func translate(resource string, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("%s %w", resource, ErrNotFound)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: a live %s with this namespace, family and name already exists", ErrConflict, resource)
+	case errors.Is(err, repository.ErrReferenced):
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	case errors.Is(err, repository.ErrMissingReference):
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	default:
+		return err
+	}
+}
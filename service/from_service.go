@@ -4,8 +4,10 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/metrics"
 	"stellarsky.ai/platform/public-config-service/model"
 	"stellarsky.ai/platform/public-config-service/repository"
 )
@@ -22,47 +24,316 @@ func NewFormService(repo *repository.FormRepository, logger *slog.Logger) *FormS
 	}
 }
 
-func (s *FormService) GetAllForms() ([]model.Form, error) {
-	forms, err := s.repo.GetAll(context.Background())
+func (s *FormService) GetAllForms(ctx context.Context, includeDeleted bool) ([]model.Form, error) {
+	ctx, span := tracer.Start(ctx, "FormService.GetAllForms")
+	defer span.End()
+
+	forms, err := s.repo.GetAll(ctx, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting all forms", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting all forms", slog.Any("error", err))
 		return nil, err
 	}
 	return forms, nil
 }
 
-func (s *FormService) GetForm(id int64) (*model.Form, error) {
-	f, err := s.repo.GetByID(context.Background(), id)
+func (s *FormService) GetForm(ctx context.Context, id int64, includeDeleted bool) (*model.Form, error) {
+	ctx, span := tracer.Start(ctx, "FormService.GetForm")
+	defer span.End()
+
+	f, err := s.repo.GetByID(ctx, id, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting form by id", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting form by id", slog.Any("error", err))
 		return nil, err
 	}
 	if f == nil {
-		return nil, errors.New("form not found")
+		return nil, fmt.Errorf("form %w", ErrNotFound)
 	}
 	return f, nil
 }
 
-func (s *FormService) CreateForm(f *model.Form) error {
-	if err := s.repo.Create(context.Background(), f); err != nil {
-		s.logger.Error("error creating form", slog.Any("error", err))
+func (s *FormService) CreateForm(ctx context.Context, f *model.Form) error {
+	ctx, span := tracer.Start(ctx, "FormService.CreateForm")
+	defer span.End()
+
+	if err := s.createForm(ctx, f); err != nil {
 		return err
 	}
+	metrics.Publishes.WithLabelValues("create").Inc()
 	return nil
 }
 
-func (s *FormService) UpdateForm(f *model.Form) error {
-	if err := s.repo.Update(context.Background(), f); err != nil {
-		s.logger.Error("error updating form", slog.Any("error", err))
+// createForm is CreateForm without counting the published version, for
+// batches that count theirs once committed.
+func (s *FormService) createForm(ctx context.Context, f *model.Form) error {
+	if err := s.checkLayout(ctx, f, f.Attributes); err != nil {
 		return err
 	}
+
+	if err := s.repo.Create(ctx, f); err != nil {
+		s.logger.ErrorContext(ctx, "error creating form", slog.Any("error", err))
+		return translate("form", err)
+	}
 	return nil
 }
 
-func (s *FormService) DeleteForm(id int64) error {
-	if err := s.repo.Delete(context.Background(), id); err != nil {
-		s.logger.Error("error deleting form", slog.Any("error", err))
+func (s *FormService) UpdateForm(ctx context.Context, f *model.Form) error {
+	ctx, span := tracer.Start(ctx, "FormService.UpdateForm")
+	defer span.End()
+
+	if err := s.updateForm(ctx, f); err != nil {
 		return err
 	}
+	metrics.Publishes.WithLabelValues("update").Inc()
+	return nil
+}
+
+// updateForm is UpdateForm without counting the published version.
+func (s *FormService) updateForm(ctx context.Context, f *model.Form) error {
+	if len(f.Pages) > 0 || len(f.Conditions) > 0 || len(f.Groups) > 0 {
+		existing, err := s.GetForm(ctx, int64(f.ID), false)
+		if err != nil {
+			return err
+		}
+		if err := s.checkLayout(ctx, f, existing.Attributes); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.Update(ctx, f); err != nil {
+		s.logger.ErrorContext(ctx, "error updating form", slog.Any("error", err))
+		return translate("form", err)
+	}
 	return nil
 }
+
+// checkLayout validates the groups, pages and conditions of f, whose attributes are
+// given. Attributes referred to only by ID have their names looked up.
+func (s *FormService) checkLayout(ctx context.Context, f *model.Form, attributes []model.Attribute) error {
+	if len(f.Pages) == 0 && len(f.Conditions) == 0 && len(f.Groups) == 0 {
+		return nil
+	}
+	var ids []uint64
+	for _, a := range attributes {
+		if a.Name == "" && a.ID != 0 {
+			ids = append(ids, a.ID)
+		}
+	}
+	var stored map[uint64]string
+	if len(ids) > 0 {
+		var err error
+		if stored, err = s.repo.AttributeNames(ctx, ids); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(attributes))
+	for _, a := range attributes {
+		if a.Name == "" {
+			a.Name = stored[a.ID]
+		}
+		names = append(names, a.Name)
+	}
+	return checkLayout(f, names)
+}
+
+// ResolveForm returns form id laid out in pages with its attributes' types
+// and validations inlined, and its texts translated for readers who prefer
+// locales.
+func (s *FormService) ResolveForm(ctx context.Context, id int64, locales []string) (*model.ResolvedForm, error) {
+	ctx, span := tracer.Start(ctx, "FormService.ResolveForm")
+	defer span.End()
+
+	f, err := s.GetForm(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.inherit(ctx, f); err != nil {
+		return nil, err
+	}
+	return resolve(f, LocaleChain(locales)), nil
+}
+
+// FormSchema returns a JSON Schema for submissions to form id, titled in the
+// first of locales that has translations.
+func (s *FormService) FormSchema(ctx context.Context, id int64, locales []string) (map[string]interface{}, string, error) {
+	ctx, span := tracer.Start(ctx, "FormService.FormSchema")
+	defer span.End()
+
+	r, err := s.ResolveForm(ctx, id, locales)
+	if err != nil {
+		return nil, "", err
+	}
+	return formSchema(r), r.Namespace, nil
+}
+
+// ValidateSubmission checks answers to form id against the validations of
+// the attributes on the pages they reach. Validations with a message in one
+// of locales report it instead of the rule's own.
+func (s *FormService) ValidateSubmission(ctx context.Context, id int64, sub *model.Submission, locales []string) (*model.ValidationReport, error) {
+	ctx, span := tracer.Start(ctx, "FormService.ValidateSubmission")
+	defer span.End()
+
+	r, err := s.ResolveForm(ctx, id, locales)
+	if err != nil {
+		return nil, err
+	}
+	return validateSubmission(r, sub)
+}
+
+// TranslationReports lists, per locale, the texts of form id that are not
+// translated into it. With no locales it reports on every locale the form's
+// texts are translated into.
+func (s *FormService) TranslationReports(ctx context.Context, id int64, locales []string) ([]model.TranslationReport, error) {
+	ctx, span := tracer.Start(ctx, "FormService.TranslationReports")
+	defer span.End()
+
+	f, err := s.GetForm(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.inherit(ctx, f); err != nil {
+		return nil, err
+	}
+	return translationReports(f, locales), nil
+}
+
+// inherit gives each attribute of f its type as extended from the type's
+// ancestors, and the validations of that type ahead of its own. The
+// attribute's validations replace those of the type with the same rule.
+func (s *FormService) inherit(ctx context.Context, f *model.Form) error {
+	types := map[uint64]model.Type{}
+	for i := range f.Attributes {
+		a := &f.Attributes[i]
+		t, ok := types[a.TypeID]
+		if !ok {
+			lineage, err := s.repo.TypeLineage(ctx, a.TypeID)
+			if err != nil {
+				return err
+			}
+			if len(lineage) == 0 {
+				continue
+			}
+			if t, err = inheritedType(lineage); err != nil {
+				return err
+			}
+			types[a.TypeID] = t
+		}
+		a.Type = t
+		a.Validations = inheritValidations(t.Validations, a.Validations)
+	}
+	return nil
+}
+
+func (s *FormService) DeleteForm(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "FormService.DeleteForm")
+	defer span.End()
+
+	if err := s.repo.Delete(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error deleting form", slog.Any("error", err))
+		return translate("form", err)
+	}
+	return nil
+}
+
+// RestoreForm undeletes a soft-deleted form and returns it. It fails with
+// ErrConflict when the form is not deleted or a live form has taken its name.
+func (s *FormService) RestoreForm(ctx context.Context, id int64) (*model.Form, error) {
+	ctx, span := tracer.Start(ctx, "FormService.RestoreForm")
+	defer span.End()
+
+	f, err := s.GetForm(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !f.DeletedAt.Valid {
+		return nil, fmt.Errorf("%w: form %d is not deleted", ErrConflict, id)
+	}
+	if err := s.repo.Restore(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error restoring form", slog.Any("error", err))
+		return nil, translate("form", err)
+	}
+	return s.GetForm(ctx, id, false)
+}
+
+// BatchForms applies ops in one transaction: either every operation succeeds
+// or none has any effect and a *BatchError reports which one failed.
+func (s *FormService) BatchForms(ctx context.Context, ops []model.BatchOperation[model.Form]) ([]model.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "FormService.BatchForms")
+	defer span.End()
+
+	if err := CheckBatch(ops); err != nil {
+		return nil, err
+	}
+	var results []model.BatchResult
+	err := s.repo.Transaction(ctx, func(repo *repository.FormRepository) error {
+		tx := NewFormService(repo, s.logger)
+		var err error
+		results, err = runBatch(ctx, ops, batchActions[model.Form]{
+			create: func(ctx context.Context, f *model.Form) (uint64, error) {
+				err := tx.createForm(ctx, f)
+				return f.ID, err
+			},
+			update: func(ctx context.Context, id uint64, f *model.Form) error {
+				f.ID = id
+				return tx.updateForm(ctx, f)
+			},
+			delete: func(ctx context.Context, id uint64) error {
+				return tx.DeleteForm(ctx, int64(id))
+			},
+		})
+		return err
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error applying form batch", slog.Any("error", err))
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Op != model.BatchDelete {
+			metrics.Publishes.WithLabelValues(string(r.Op)).Inc()
+		}
+	}
+	return results, nil
+}
+
+// CloneForm copies form id as req describes and maps the source's IDs to the
+// clone's. Empty target fields default to the source's.
+func (s *FormService) CloneForm(ctx context.Context, id int64, req *model.CloneRequest) (*model.CloneResult, error) {
+	ctx, span := tracer.Start(ctx, "FormService.CloneForm")
+	defer span.End()
+
+	src, err := s.GetForm(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	switch req.OnConflict {
+	case "":
+		req.OnConflict = model.CloneFail
+	case model.CloneFail, model.CloneSuffix, model.CloneReuse:
+	default:
+		return nil, fmt.Errorf("%w: OnConflict must be %q, %q or %q", ErrInvalid, model.CloneFail, model.CloneSuffix, model.CloneReuse)
+	}
+	if req.Namespace == "" {
+		req.Namespace = src.Namespace
+	}
+	if req.Family == "" {
+		req.Family = src.Family
+	}
+	if req.Name == "" {
+		req.Name = src.Name
+	}
+
+	result, err := s.repo.Clone(ctx, id, req)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		return nil, fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error cloning form", slog.Any("error", err))
+		return nil, translate("form", err)
+	}
+	if !result.Reused {
+		metrics.Publishes.WithLabelValues("clone").Inc()
+	}
+	if result.Form, err = s.GetForm(ctx, int64(result.Forms[src.ID]), false); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+// service/health_service.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/buildinfo"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// pingTimeout bounds the database ping of a readiness check.
+const pingTimeout = 2 * time.Second
+
+type CheckResult struct {
+	Status  string
+	Error   string      `json:",omitempty"`
+	Details interface{} `json:",omitempty"`
+}
+
+type Health struct {
+	Status  string
+	Version string
+	Commit  string
+	Checks  map[string]CheckResult `json:",omitempty"`
+}
+
+type HealthService struct {
+	repo          *repository.HealthRepository
+	schemaVersion int
+	draining      atomic.Bool
+	logger        *slog.Logger
+}
+
+// NewHealthService returns a HealthService that expects the database schema
+// to be at schemaVersion.
+func NewHealthService(repo *repository.HealthRepository, schemaVersion int, logger *slog.Logger) *HealthService {
+	return &HealthService{
+		repo:          repo,
+		schemaVersion: schemaVersion,
+		logger:        logger,
+	}
+}
+
+// SetDraining makes every later readiness check fail, so that load balancers
+// stop sending traffic before the server shuts down.
+func (s *HealthService) SetDraining() {
+	s.draining.Store(true)
+}
+
+// Live reports that the process is up. It checks no dependencies: restarting
+// the service would not fix an unreachable database.
+func (s *HealthService) Live() *Health {
+	version, commit := buildinfo.Info()
+	return &Health{Status: StatusOK, Version: version, Commit: commit}
+}
+
+// Ready checks that the service can serve requests: it is not shutting down,
+// the database answers, the connection pool has room and the schema has been
+// migrated for this build.
+func (s *HealthService) Ready(ctx context.Context) *Health {
+	h := s.Live()
+	h.Status = StatusReady
+	h.Checks = map[string]CheckResult{
+		"shutdown":   s.checkShutdown(),
+		"database":   s.checkDatabase(ctx),
+		"pool":       s.checkPool(),
+		"migrations": s.checkMigrations(ctx),
+	}
+	for name, c := range h.Checks {
+		if c.Status != StatusOK {
+			h.Status = StatusNotReady
+			s.logger.WarnContext(ctx, "readiness check failed", slog.String("check", name), slog.String("error", c.Error))
+		}
+	}
+	return h
+}
+
+func (s *HealthService) checkShutdown() CheckResult {
+	if s.draining.Load() {
+		return CheckResult{Status: StatusFailed, Error: "server is shutting down"}
+	}
+	return CheckResult{Status: StatusOK}
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if err := s.repo.Ping(ctx); err != nil {
+		return CheckResult{Status: StatusFailed, Error: err.Error()}
+	}
+	return CheckResult{Status: StatusOK}
+}
+
+func (s *HealthService) checkPool() CheckResult {
+	stats, err := s.repo.PoolStats()
+	if err != nil {
+		return CheckResult{Status: StatusFailed, Error: err.Error()}
+	}
+	c := CheckResult{
+		Status: StatusOK,
+		Details: map[string]interface{}{
+			"open":          stats.OpenConnections,
+			"in_use":        stats.InUse,
+			"idle":          stats.Idle,
+			"max_open":      stats.MaxOpenConnections,
+			"wait_count":    stats.WaitCount,
+			"wait_duration": stats.WaitDuration.String(),
+		},
+	}
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		c.Status = StatusFailed
+		c.Error = "connection pool is saturated"
+	}
+	return c
+}
+
+func (s *HealthService) checkMigrations(ctx context.Context) CheckResult {
+	version, err := s.repo.SchemaVersion(ctx)
+	if err != nil {
+		return CheckResult{Status: StatusFailed, Error: err.Error()}
+	}
+	c := CheckResult{
+		Status:  StatusOK,
+		Details: map[string]int{"version": version, "expected": s.schemaVersion},
+	}
+	if version < s.schemaVersion {
+		c.Status = StatusFailed
+		c.Error = fmt.Sprintf("schema is at version %d, this build needs %d", version, s.schemaVersion)
+	}
+	return c
+}
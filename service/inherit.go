@@ -0,0 +1,98 @@
+// service/inherit.go
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// effectiveType merges lineage, a type followed by its ancestors nearest
+// first, into what the type's attributes get. Each type overrides the element
+// type, widget and design schema of its ancestors when it sets them, merges
+// its design defaults over theirs, and replaces their validations of the same
+// rule.
+func effectiveType(lineage []model.Type) (*model.EffectiveType, error) {
+	t := lineage[0]
+	e := &model.EffectiveType{
+		ID:        t.ID,
+		Namespace: t.Namespace,
+		Family:    t.Family,
+		Name:      t.Name,
+		Ancestors: make([]model.UsageRef, 0, len(lineage)-1),
+	}
+	for _, a := range lineage[1:] {
+		e.Ancestors = append(e.Ancestors, model.UsageRef{ID: a.ID, Namespace: a.Namespace, Family: a.Family, Name: a.Name})
+	}
+	var defaults interface{}
+	for i := len(lineage) - 1; i >= 0; i-- {
+		l := lineage[i]
+		if l.ElementType != "" {
+			e.ElementType = l.ElementType
+		}
+		if l.WidgetType != "" {
+			e.WidgetType = l.WidgetType
+		}
+		if len(l.DesignSchema) > 0 {
+			e.DesignSchema = l.DesignSchema
+		}
+		if len(l.DesignDefaults) > 0 {
+			var d interface{}
+			if err := json.Unmarshal(l.DesignDefaults, &d); err != nil {
+				return nil, fmt.Errorf("%w: type %q has invalid design defaults", ErrInvalid, l.Name)
+			}
+			if defaults == nil {
+				defaults = d
+			} else {
+				defaults = mergeDesign(defaults, d)
+			}
+		}
+		e.Validations = inheritValidations(e.Validations, l.Validations)
+	}
+	if defaults != nil {
+		b, err := json.Marshal(defaults)
+		if err != nil {
+			return nil, err
+		}
+		e.DesignDefaults = b
+	}
+	if e.Validations == nil {
+		e.Validations = []model.Validation{}
+	}
+	return e, nil
+}
+
+// inheritedType returns the first type of lineage with what it inherits from
+// the rest merged in, as attributes of it see it.
+func inheritedType(lineage []model.Type) (model.Type, error) {
+	e, err := effectiveType(lineage)
+	if err != nil {
+		return model.Type{}, err
+	}
+	t := lineage[0]
+	t.ElementType, t.WidgetType = e.ElementType, e.WidgetType
+	t.DesignSchema, t.DesignDefaults = e.DesignSchema, e.DesignDefaults
+	t.Validations = e.Validations
+	t.Parent = nil
+	return t, nil
+}
+
+// inheritValidations returns inherited followed by own, leaving out the
+// inherited validations own has one of the same rule for.
+func inheritValidations(inherited, own []model.Validation) []model.Validation {
+	if len(inherited) == 0 {
+		return own
+	}
+	overridden := map[string]bool{}
+	for _, v := range own {
+		overridden[v.RuleName] = true
+	}
+	merged := make([]model.Validation, 0, len(inherited)+len(own))
+	for _, v := range inherited {
+		if !overridden[v.RuleName] {
+			merged = append(merged, v)
+		}
+	}
+	return append(merged, own...)
+}
@@ -0,0 +1,195 @@
+// service/localize.go
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+	"stellarsky.ai/platform/public-config-service/model"
+)
+
+// BaseLocale is the locale of the texts entities carry untranslated: labels,
+// help texts, option labels and the messages of the rules package.
+const BaseLocale = "en"
+
+// LocaleChain returns the locales to look translations up in for readers who
+// prefer preferred, in order: each locale followed by the more general ones
+// it falls back to, such as "fr" after "fr-CA", and BaseLocale last. It
+// returns nil when nothing is preferred, leaving texts untranslated.
+func LocaleChain(preferred []string) []string {
+	if len(preferred) == 0 {
+		return nil
+	}
+	var chain []string
+	seen := map[string]bool{}
+	add := func(locale string) {
+		if !seen[locale] {
+			seen[locale] = true
+			chain = append(chain, locale)
+		}
+	}
+	for _, locale := range preferred {
+		for _, l := range fallbacks(locale) {
+			add(l)
+		}
+	}
+	add(BaseLocale)
+	return chain
+}
+
+// fallbacks returns locale followed by the more general locales it falls
+// back to, dropping one subtag at a time.
+func fallbacks(locale string) []string {
+	chain := []string{locale}
+	for i := strings.LastIndex(locale, "-"); i > 0; i = strings.LastIndex(locale, "-") {
+		locale = locale[:i]
+		chain = append(chain, locale)
+	}
+	return chain
+}
+
+// inLocale returns the text in the first locale of chain that has one, where
+// text looks up translations and base is the text in BaseLocale. It returns
+// base and false when chain reaches neither a translation nor BaseLocale.
+func inLocale(chain []string, base string, text func(locale string) string) (string, bool) {
+	for _, locale := range chain {
+		if t := text(locale); t != "" {
+			return t, true
+		}
+		if locale == BaseLocale {
+			return base, true
+		}
+	}
+	return base, false
+}
+
+// localizeOptions returns a copy of options with their labels in the first
+// locale of chain that has them, or options itself when chain is empty.
+func localizeOptions(options []model.Option, chain []string) []model.Option {
+	if len(chain) == 0 || options == nil {
+		return options
+	}
+	localized := make([]model.Option, len(options))
+	for i, o := range options {
+		o.Label, _ = inLocale(chain, o.Label, func(locale string) string { return o.Labels[locale] })
+		o.Labels = nil
+		localized[i] = o
+	}
+	return localized
+}
+
+// translationReports reports, for each of locales, the texts of f's
+// attributes that readers in it see untranslated. With no locales it covers
+// every locale f's texts are translated into.
+func translationReports(f *model.Form, locales []string) []model.TranslationReport {
+	attributes := make([]*model.Attribute, len(f.Attributes))
+	for i := range f.Attributes {
+		attributes[i] = &f.Attributes[i]
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Name < attributes[j].Name })
+	if len(locales) == 0 {
+		locales = translatedLocales(attributes)
+	}
+
+	reports := make([]model.TranslationReport, 0, len(locales))
+	for _, locale := range locales {
+		// Untranslated texts only count for the locales they are written in.
+		chain := fallbacks(locale)
+		report := model.TranslationReport{Locale: locale, Missing: []model.MissingTranslation{}}
+		tally := func(ok bool, missing model.MissingTranslation) {
+			report.Total++
+			if ok {
+				report.Translated++
+			} else {
+				report.Missing = append(report.Missing, missing)
+			}
+		}
+		for _, a := range attributes {
+			if a.Label != "" {
+				_, ok := inLocale(chain, a.Label, func(l string) string { return a.Translations[l].Label })
+				tally(ok, model.MissingTranslation{Attribute: a.Name, Text: "label"})
+			}
+			if a.HelpText != "" {
+				_, ok := inLocale(chain, a.HelpText, func(l string) string { return a.Translations[l].HelpText })
+				tally(ok, model.MissingTranslation{Attribute: a.Name, Text: "help_text"})
+			}
+			if a.OptionSet != nil {
+				for _, o := range a.OptionSet.Options {
+					_, ok := inLocale(chain, o.Label, func(l string) string { return o.Labels[l] })
+					tally(ok, model.MissingTranslation{Attribute: a.Name, Text: "option_label", Option: o.Value})
+				}
+			}
+			for _, v := range a.Validations {
+				_, ok := inLocale(chain, "", func(l string) string { return v.Messages[l] })
+				tally(ok, model.MissingTranslation{Attribute: a.Name, Text: "message", Validation: v.Name})
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// translatedLocales returns the locales any text of attributes is translated
+// into, sorted.
+func translatedLocales(attributes []*model.Attribute) []string {
+	seen := map[string]bool{}
+	for _, a := range attributes {
+		for locale := range a.Translations {
+			seen[locale] = true
+		}
+		if a.OptionSet != nil {
+			for _, o := range a.OptionSet.Options {
+				for locale := range o.Labels {
+					seen[locale] = true
+				}
+			}
+		}
+		for _, v := range a.Validations {
+			for locale := range v.Messages {
+				seen[locale] = true
+			}
+		}
+	}
+	locales := make([]string, 0, len(seen))
+	for locale := range seen {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// checkLocale rejects a locale that is not a BCP 47 language tag in
+// canonical form, such as "pt-BR".
+func checkLocale(locale string) error {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return fmt.Errorf("%w: %q is not a locale", ErrInvalid, locale)
+	}
+	if tag.String() != locale {
+		return fmt.Errorf("%w: locale %q should be written %q", ErrInvalid, locale, tag.String())
+	}
+	return nil
+}
+
+// checkTranslations rejects translations of a's texts keyed by an invalid
+// locale.
+func checkTranslations(a *model.Attribute) error {
+	for locale := range a.Translations {
+		if err := checkLocale(locale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkMessages rejects messages of v keyed by an invalid locale.
+func checkMessages(v *model.Validation) error {
+	for locale := range v.Messages {
+		if err := checkLocale(locale); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+// service/option_service.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/metrics"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/rules"
+)
+
+// ErrUpstream is wrapped by errors caused by a data source that could not be
+// reached or answered with something other than options.
+var ErrUpstream = errors.New("data source failed")
+
+// dataSourceCache names the option cache in metrics.
+const dataSourceCache = "data_source"
+
+// placeholder matches a {name} in a data source URL template.
+var placeholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// DataSourceLimits bound the lookups OptionService makes.
+type DataSourceLimits struct {
+	// Timeout bounds each request to a data source.
+	Timeout time.Duration
+	// CacheTTL applies to data sources that do not set their own.
+	CacheTTL        time.Duration
+	MaxCacheEntries int
+	// MaxResponseBytes caps the body read from a data source.
+	MaxResponseBytes int64
+	// AllowedHosts lists the only hosts data sources may point at; when it
+	// is empty no lookups are made. Addresses in loopback, link-local and
+	// private ranges are only dialled when an entry is that address or a
+	// network containing it, such as "10.0.0.0/8".
+	AllowedHosts []string
+}
+
+// maxRedirects bounds the redirects a lookup follows, each of which must
+// lead to an allowed host.
+const maxRedirects = 5
+
+// OptionService serves the options of choice attributes, proxying and
+// caching the lookups of those whose options come from a data source.
+type OptionService struct {
+	client *http.Client
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	limits DataSourceLimits
+	cache  map[string]cachedOptions
+}
+
+type cachedOptions struct {
+	options []model.Option
+	expires time.Time
+}
+
+// NewOptionService makes lookups with a copy of client that checks every
+// redirect and dialled address against the allowed hosts, so that an allowed
+// host cannot send a lookup anywhere else. The copy's transport is a clone of
+// client's, or of http.DefaultTransport if client's is not an
+// *http.Transport, and never uses a proxy.
+func NewOptionService(client *http.Client, limits DataSourceLimits, logger *slog.Logger) *OptionService {
+	s := &OptionService{
+		logger: logger,
+		limits: limits,
+		cache:  map[string]cachedOptions{},
+	}
+	base, ok := client.Transport.(*http.Transport)
+	if !ok {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: s.checkDial}
+	transport.DialContext = dialer.DialContext
+	guarded := *client
+	guarded.Transport = transport
+	guarded.CheckRedirect = s.checkRedirect
+	s.client = &guarded
+	return s
+}
+
+func (s *OptionService) allowedHosts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limits.AllowedHosts
+}
+
+// checkRedirect applies checkHost to every redirect a lookup follows.
+func (s *OptionService) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrUpstream, maxRedirects)
+	}
+	return checkHost(req.URL.String(), s.allowedHosts())
+}
+
+// checkDial refuses to connect to a loopback, link-local, private or
+// unspecified address that the allowed hosts do not list, whatever host name
+// resolved to it.
+func (s *OptionService) checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%w: cannot dial %q", ErrUpstream, address)
+	}
+	if !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsPrivate() && !ip.IsUnspecified() {
+		return nil
+	}
+	for _, allowed := range s.allowedHosts() {
+		if allowsIP(allowed, ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: address %s is not allowed", ErrUpstream, ip)
+}
+
+// SetLimits replaces the limits, such as after a configuration reload.
+// Cached options keep the expiry they were stored with.
+func (s *OptionService) SetLimits(limits DataSourceLimits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = limits
+}
+
+// Options returns the options of the attribute of f called name. values holds
+// the answers its data source depends on. The labels of an option set are
+// translated for readers who prefer locales; those of a data source are
+// passed through.
+func (s *OptionService) Options(ctx context.Context, f *model.Form, name string, values map[string]string, locales []string) ([]model.Option, error) {
+	ctx, span := tracer.Start(ctx, "OptionService.Options")
+	defer span.End()
+
+	var a *model.Attribute
+	for i := range f.Attributes {
+		if f.Attributes[i].Name == name {
+			a = &f.Attributes[i]
+		}
+	}
+	switch {
+	case a == nil:
+		return nil, fmt.Errorf("attribute %w", ErrNotFound)
+	case a.OptionSet != nil:
+		return localizeOptions(a.OptionSet.Options, LocaleChain(locales)), nil
+	case a.DataSource == nil:
+		return nil, fmt.Errorf("%w: attribute %q has no options", ErrNotFound, name)
+	}
+
+	d := a.DataSource
+	target, err := expandURL(d, values)
+	if err != nil {
+		return nil, err
+	}
+	// Attributes may map the same response differently.
+	key := fmt.Sprintf("%s %+v", target, d.Mapping)
+	s.mu.Lock()
+	limits := s.limits
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		metrics.CacheHit(dataSourceCache)
+		return cached.options, nil
+	}
+	metrics.CacheMiss(dataSourceCache)
+
+	options, err := s.fetch(ctx, d, target, limits)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error fetching options", slog.String("attribute", name), slog.Any("error", err))
+		return nil, err
+	}
+	ttl := limits.CacheTTL
+	if d.CacheTTL != "" {
+		ttl, _ = time.ParseDuration(d.CacheTTL)
+	}
+	if ttl > 0 {
+		s.store(key, cachedOptions{options: options, expires: time.Now().Add(ttl)}, limits.MaxCacheEntries)
+	}
+	return options, nil
+}
+
+// store caches entry under key, first making room by dropping expired
+// entries and then, if that is not enough, arbitrary ones.
+func (s *OptionService) store(key string, entry cachedOptions, max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if max > 0 && len(s.cache) >= max {
+		now := time.Now()
+		for k, c := range s.cache {
+			if now.After(c.expires) {
+				delete(s.cache, k)
+			}
+		}
+		for k := range s.cache {
+			if len(s.cache) < max {
+				break
+			}
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = entry
+}
+
+func (s *OptionService) fetch(ctx context.Context, d *model.DataSource, target string, limits DataSourceLimits) ([]model.Option, error) {
+	if err := checkHost(target, limits.AllowedHosts); err != nil {
+		return nil, err
+	}
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s answered %s", ErrUpstream, req.URL.Host, resp.Status)
+	}
+	body := io.Reader(resp.Body)
+	if limits.MaxResponseBytes > 0 {
+		body = io.LimitReader(body, limits.MaxResponseBytes)
+	}
+	var doc interface{}
+	if err := json.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: decoding response: %v", ErrUpstream, err)
+	}
+	return mapOptions(d.Mapping, doc)
+}
+
+// mapOptions picks the options out of a decoded response.
+func mapOptions(m model.ResponseMapping, doc interface{}) ([]model.Option, error) {
+	items := doc
+	if m.Items != "" {
+		for _, key := range strings.Split(m.Items, ".") {
+			obj, ok := items.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%w: response has no %q", ErrUpstream, m.Items)
+			}
+			items = obj[key]
+		}
+	}
+	list, ok := items.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: response has no list of options at %q", ErrUpstream, m.Items)
+	}
+	label := m.Label
+	if label == "" {
+		label = m.Value
+	}
+	options := make([]model.Option, 0, len(list))
+	for _, item := range list {
+		fields, ok := item.(map[string]interface{})
+		if !ok || rules.Empty(fields[m.Value]) {
+			continue
+		}
+		o := model.Option{Value: rules.Text(fields[m.Value]), Label: rules.Text(fields[label])}
+		if m.Group != "" {
+			o.Group = rules.Text(fields[m.Group])
+		}
+		options = append(options, o)
+	}
+	return options, nil
+}
+
+// expandURL fills the placeholders of d's URL template from values.
+func expandURL(d *model.DataSource, values map[string]string) (string, error) {
+	var missing []string
+	target := placeholder.ReplaceAllStringFunc(d.URL, func(p string) string {
+		name := p[1 : len(p)-1]
+		v, ok := values[name]
+		if !ok || v == "" {
+			missing = append(missing, name)
+		}
+		return strings.ReplaceAll(url.QueryEscape(v), "+", "%20")
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: options depend on %s", ErrInvalid, strings.Join(missing, ", "))
+	}
+	return target, nil
+}
+
+// checkHost rejects a target whose host is not allowed, and every target
+// when nothing is.
+func checkHost(target string, allowed []string) error {
+	if len(allowed) == 0 {
+		return fmt.Errorf("%w: no data source hosts are allowed", ErrUpstream)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q is not allowed", ErrUpstream, u.Scheme)
+	}
+	ip := net.ParseIP(u.Hostname())
+	for _, host := range allowed {
+		if strings.EqualFold(u.Hostname(), host) || ip != nil && allowsIP(host, ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %q is not allowed", ErrUpstream, u.Hostname())
+}
+
+// allowsIP reports whether allowed, an entry of AllowedHosts, is ip or a
+// network containing it.
+func allowsIP(allowed string, ip net.IP) bool {
+	if _, network, err := net.ParseCIDR(allowed); err == nil {
+		return network.Contains(ip)
+	}
+	a := net.ParseIP(allowed)
+	return a != nil && a.Equal(ip)
+}
+
+// checkDataSource rejects a data source whose URL is not an http or https
+// template over its dependencies, that maps no value or whose CacheTTL is
+// not a duration. Placeholders may only fill in the path and query, so that
+// answers cannot choose the host a lookup goes to.
+func checkDataSource(d *model.DataSource) error {
+	u, err := url.Parse(placeholder.ReplaceAllString(d.URL, "x"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: data source URL must be an absolute http or https URL", ErrInvalid)
+	}
+	authority := d.URL[strings.Index(d.URL, "://")+len("://"):]
+	if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+		authority = authority[:i]
+	}
+	if placeholder.MatchString(authority) {
+		return fmt.Errorf("%w: data source URL may only use placeholders in its path and query", ErrInvalid)
+	}
+	depends := map[string]bool{}
+	for _, name := range d.DependsOn {
+		depends[name] = true
+	}
+	for _, m := range placeholder.FindAllStringSubmatch(d.URL, -1) {
+		if !depends[m[1]] {
+			return fmt.Errorf("%w: data source URL uses {%s}, which is not in DependsOn", ErrInvalid, m[1])
+		}
+	}
+	if d.Mapping.Value == "" {
+		return fmt.Errorf("%w: data source mapping needs a Value field", ErrInvalid)
+	}
+	if d.CacheTTL != "" {
+		if ttl, err := time.ParseDuration(d.CacheTTL); err != nil || ttl < 0 {
+			return fmt.Errorf("%w: data source CacheTTL must be a duration such as \"10m\"", ErrInvalid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// service/option_set_service.go
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+type OptionSetService struct {
+	repo   *repository.OptionSetRepository
+	logger *slog.Logger
+}
+
+func NewOptionSetService(repo *repository.OptionSetRepository, logger *slog.Logger) *OptionSetService {
+	return &OptionSetService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *OptionSetService) GetAllOptionSets(ctx context.Context, includeDeleted bool) ([]model.OptionSet, error) {
+	ctx, span := tracer.Start(ctx, "OptionSetService.GetAllOptionSets")
+	defer span.End()
+
+	sets, err := s.repo.GetAll(ctx, includeDeleted)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting all option sets", slog.Any("error", err))
+		return nil, err
+	}
+	return sets, nil
+}
+
+func (s *OptionSetService) GetOptionSet(ctx context.Context, id int64, includeDeleted bool) (*model.OptionSet, error) {
+	ctx, span := tracer.Start(ctx, "OptionSetService.GetOptionSet")
+	defer span.End()
+
+	o, err := s.repo.GetByID(ctx, id, includeDeleted)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting option set by id", slog.Any("error", err))
+		return nil, err
+	}
+	if o == nil {
+		return nil, fmt.Errorf("option set %w", ErrNotFound)
+	}
+	return o, nil
+}
+
+func (s *OptionSetService) CreateOptionSet(ctx context.Context, o *model.OptionSet) error {
+	ctx, span := tracer.Start(ctx, "OptionSetService.CreateOptionSet")
+	defer span.End()
+
+	if err := checkOptions(o.Options); err != nil {
+		return err
+	}
+	if err := s.repo.Create(ctx, o); err != nil {
+		s.logger.ErrorContext(ctx, "error creating option set", slog.Any("error", err))
+		return translate("option set", err)
+	}
+	return nil
+}
+
+func (s *OptionSetService) UpdateOptionSet(ctx context.Context, o *model.OptionSet) error {
+	ctx, span := tracer.Start(ctx, "OptionSetService.UpdateOptionSet")
+	defer span.End()
+
+	if err := checkOptions(o.Options); err != nil {
+		return err
+	}
+	if err := s.repo.Update(ctx, o); err != nil {
+		s.logger.ErrorContext(ctx, "error updating option set", slog.Any("error", err))
+		return translate("option set", err)
+	}
+	return nil
+}
+
+// DeleteOptionSet deletes option set id according to opts and returns the
+// attributes that offered it, or with opts.DryRun offer it, and the forms
+// containing them. A restricted delete of an option set an attribute offers
+// fails with an *InUseError.
+func (s *OptionSetService) DeleteOptionSet(ctx context.Context, id int64, opts DeleteOptions) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "OptionSetService.DeleteOptionSet")
+	defer span.End()
+
+	usages, err := deleteWithUsages(ctx, s.repo, "option set", id, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error deleting option set", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// GetOptionSetUsages lists the live attributes offering option set id and the
+// forms containing them.
+func (s *OptionSetService) GetOptionSetUsages(ctx context.Context, id int64) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "OptionSetService.GetOptionSetUsages")
+	defer span.End()
+
+	if _, err := s.GetOptionSet(ctx, id, false); err != nil {
+		return nil, err
+	}
+	usages, err := s.repo.Usages(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting option set usages", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// RestoreOptionSet undeletes a soft-deleted option set and returns it. It
+// fails with ErrConflict when the option set is not deleted or a live option
+// set has taken its name.
+func (s *OptionSetService) RestoreOptionSet(ctx context.Context, id int64) (*model.OptionSet, error) {
+	ctx, span := tracer.Start(ctx, "OptionSetService.RestoreOptionSet")
+	defer span.End()
+
+	o, err := s.GetOptionSet(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !o.DeletedAt.Valid {
+		return nil, fmt.Errorf("%w: option set %d is not deleted", ErrConflict, id)
+	}
+	if err := s.repo.Restore(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error restoring option set", slog.Any("error", err))
+		return nil, translate("option set", err)
+	}
+	return s.GetOptionSet(ctx, id, false)
+}
+
+// checkOptions rejects options without a value, with the value of an earlier
+// one or with labels keyed by an invalid locale.
+func checkOptions(options model.Options) error {
+	seen := map[string]bool{}
+	for i, o := range options {
+		if o.Value == "" {
+			return fmt.Errorf("%w: option %d needs a value", ErrInvalid, i)
+		}
+		if seen[o.Value] {
+			return fmt.Errorf("%w: option value %q appears more than once", ErrInvalid, o.Value)
+		}
+		seen[o.Value] = true
+		for locale := range o.Labels {
+			if err := checkLocale(locale); err != nil {
+				return fmt.Errorf("option %q: %w", o.Value, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,451 @@
+// service/pages.go
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/rules"
+)
+
+// defaultPage names the single page of a form without a page layout.
+const defaultPage = "main"
+
+// checkLayout rejects groups, pages or conditions that do not fit the form's
+// attributes, named by names.
+func checkLayout(f *model.Form, names []string) error {
+	placed, err := checkGroups(f.Groups, names)
+	if err != nil {
+		return err
+	}
+	if err := checkPages(f.Pages, placed); err != nil {
+		return err
+	}
+	return checkConditions(f.Conditions, names)
+}
+
+// checkPages rejects a page layout that does not place each of names, the
+// form's groups and the attributes in none, on exactly one page, or whose
+// navigation refers to unknown names or does not move forward.
+func checkPages(pages model.Pages, names []string) error {
+	if len(pages) == 0 {
+		return nil
+	}
+	unplaced := map[string]bool{}
+	for _, name := range names {
+		if unplaced[name] {
+			return fmt.Errorf("%w: a form with pages needs unique attribute names, %q appears twice", ErrInvalid, name)
+		}
+		unplaced[name] = true
+	}
+	index := map[string]int{}
+	for i, p := range pages {
+		if p.Name == "" {
+			return fmt.Errorf("%w: page %d needs a name", ErrInvalid, i)
+		}
+		if _, ok := index[p.Name]; ok {
+			return fmt.Errorf("%w: page %q appears twice", ErrInvalid, p.Name)
+		}
+		index[p.Name] = i
+	}
+
+	answered := map[string]bool{}
+	for i, p := range pages {
+		for _, name := range p.Attributes {
+			if !unplaced[name] {
+				if answered[name] {
+					return fmt.Errorf("%w: %q is on more than one page", ErrInvalid, name)
+				}
+				return fmt.Errorf("%w: page %q lists %q, which is not one of the form's attributes or groups", ErrInvalid, p.Name, name)
+			}
+			delete(unplaced, name)
+			answered[name] = true
+		}
+		for _, rule := range p.Navigation {
+			if target, ok := index[rule.GoTo]; !ok || target <= i {
+				return fmt.Errorf("%w: page %q can only go to a later page, not %q", ErrInvalid, p.Name, rule.GoTo)
+			}
+			if err := checkCondition(rule.When, answered); err != nil {
+				return fmt.Errorf("%w: page %q: %v", ErrInvalid, p.Name, err)
+			}
+		}
+	}
+	for name := range unplaced {
+		return fmt.Errorf("%w: %q is not on any page", ErrInvalid, name)
+	}
+	return nil
+}
+
+// checkCondition rejects a condition on an attribute not in answered or with
+// an unknown operator.
+func checkCondition(c model.Condition, answered map[string]bool) error {
+	if !answered[c.Attribute] {
+		return fmt.Errorf("condition on %q, which is not answered on this or an earlier page", c.Attribute)
+	}
+	switch c.Op {
+	case model.OpEquals, model.OpNotEquals, model.OpSet, model.OpUnset:
+	case model.OpIn, model.OpNotIn:
+		if _, ok := c.Value.([]interface{}); !ok {
+			return fmt.Errorf("condition on %q needs a list of values for %s", c.Attribute, c.Op)
+		}
+	default:
+		return fmt.Errorf("condition on %q has unknown operator %q", c.Attribute, c.Op)
+	}
+	return nil
+}
+
+// checkGroups rejects groups that are unnamed, share a name with an
+// attribute or another group, have bad occurrence bounds, or do not consist
+// of the form's attributes, named by names, each in one group at most. It
+// returns the names pages place: the groups and the attributes in none.
+func checkGroups(groups model.Groups, names []string) ([]string, error) {
+	if len(groups) == 0 {
+		return names, nil
+	}
+	ungrouped := map[string]bool{}
+	for _, name := range names {
+		if ungrouped[name] {
+			return nil, fmt.Errorf("%w: a form with groups needs unique attribute names, %q appears twice", ErrInvalid, name)
+		}
+		ungrouped[name] = true
+	}
+	known := map[string]bool{}
+	for name := range ungrouped {
+		known[name] = true
+	}
+	for i, g := range groups {
+		switch {
+		case g.Name == "":
+			return nil, fmt.Errorf("%w: group %d needs a name", ErrInvalid, i)
+		case known[g.Name]:
+			return nil, fmt.Errorf("%w: group %q has the name of an attribute or another group", ErrInvalid, g.Name)
+		case len(g.Attributes) == 0:
+			return nil, fmt.Errorf("%w: group %q has no attributes", ErrInvalid, g.Name)
+		case g.MinOccurs < 0 || g.MaxOccurs < 0 || g.MaxOccurs > 0 && g.MaxOccurs < g.MinOccurs:
+			return nil, fmt.Errorf("%w: group %q needs 0 <= MinOccurs <= MaxOccurs, or MaxOccurs 0 for no limit", ErrInvalid, g.Name)
+		}
+		known[g.Name] = true
+		for _, name := range g.Attributes {
+			if !ungrouped[name] {
+				return nil, fmt.Errorf("%w: group %q lists %q, which is not one of the form's attributes or is in another group",
+					ErrInvalid, g.Name, name)
+			}
+			delete(ungrouped, name)
+		}
+	}
+	placed := make([]string, 0, len(ungrouped)+len(groups))
+	for _, name := range names {
+		if ungrouped[name] {
+			placed = append(placed, name)
+		}
+	}
+	for _, g := range groups {
+		placed = append(placed, g.Name)
+	}
+	return placed, nil
+}
+
+// layout returns the pages f is shown in. Attributes and groups added to the
+// form since its pages were written go on the last page, and names no longer
+// in the form are dropped.
+func layout(f *model.Form) model.Pages {
+	placeable := map[string]bool{}
+	grouped := map[string]bool{}
+	for _, g := range f.Groups {
+		placeable[g.Name] = true
+		for _, name := range g.Attributes {
+			grouped[name] = true
+		}
+	}
+	var rest []string
+	for _, a := range f.Attributes {
+		if !grouped[a.Name] && !placeable[a.Name] {
+			placeable[a.Name] = true
+			rest = append(rest, a.Name)
+		}
+	}
+	sort.Strings(rest)
+	for _, g := range f.Groups {
+		rest = append(rest, g.Name)
+	}
+
+	pages := make(model.Pages, 0, len(f.Pages))
+	for _, p := range f.Pages {
+		names := make([]string, 0, len(p.Attributes))
+		for _, name := range p.Attributes {
+			if placeable[name] {
+				names = append(names, name)
+				delete(placeable, name)
+			}
+		}
+		p.Attributes = names
+		pages = append(pages, p)
+	}
+	if len(pages) == 0 {
+		pages = append(pages, model.Page{Name: defaultPage})
+	}
+	last := &pages[len(pages)-1]
+	for _, name := range rest {
+		if placeable[name] {
+			last.Attributes = append(last.Attributes, name)
+		}
+	}
+	return pages
+}
+
+// resolve inlines the type and validations of each attribute of f into its
+// page layout, with texts in the first locale of chain that has them.
+func resolve(f *model.Form, chain []string) *model.ResolvedForm {
+	byName := map[string]*model.Attribute{}
+	for i := range f.Attributes {
+		byName[f.Attributes[i].Name] = &f.Attributes[i]
+	}
+	groups := map[string]*model.Group{}
+	for i := range f.Groups {
+		groups[f.Groups[i].Name] = &f.Groups[i]
+	}
+	attribute := func(name string) model.ResolvedAttribute {
+		a := resolveAttribute(byName[name], chain)
+		a.VisibleIf = f.Conditions[name].VisibleIf
+		a.RequiredIf = f.Conditions[name].RequiredIf
+		return a
+	}
+
+	r := &model.ResolvedForm{
+		ID:         f.ID,
+		Namespace:  f.Namespace,
+		Family:     f.Family,
+		Name:       f.Name,
+		ActionName: f.ActionName,
+		Version:    f.Version,
+		Locales:    chain,
+	}
+	for _, p := range layout(f) {
+		page := model.ResolvedPage{Name: p.Name, Title: p.Title, Navigation: p.Navigation, Order: p.Attributes,
+			Attributes: []model.ResolvedAttribute{}, Groups: []model.ResolvedGroup{}}
+		for _, name := range p.Attributes {
+			g, ok := groups[name]
+			if !ok {
+				page.Attributes = append(page.Attributes, attribute(name))
+				continue
+			}
+			group := model.ResolvedGroup{Name: g.Name, Title: g.Title, MinOccurs: g.MinOccurs, MaxOccurs: g.MaxOccurs,
+				Attributes: make([]model.ResolvedAttribute, 0, len(g.Attributes))}
+			for _, member := range g.Attributes {
+				if byName[member] != nil {
+					group.Attributes = append(group.Attributes, attribute(member))
+				}
+			}
+			page.Groups = append(page.Groups, group)
+		}
+		r.Pages = append(r.Pages, page)
+	}
+	return r
+}
+
+func resolveAttribute(a *model.Attribute, chain []string) model.ResolvedAttribute {
+	label, _ := inLocale(chain, a.Label, func(l string) string { return a.Translations[l].Label })
+	helpText, _ := inLocale(chain, a.HelpText, func(l string) string { return a.Translations[l].HelpText })
+	r := model.ResolvedAttribute{
+		ID:          a.ID,
+		Namespace:   a.Namespace,
+		Family:      a.Family,
+		Name:        a.Name,
+		Label:       label,
+		HelpText:    helpText,
+		DesignSpec:  designSpec(&a.Type, a.DesignSpec),
+		ElementType: a.Type.ElementType,
+		WidgetType:  a.Type.WidgetType,
+		Validations: make([]model.ResolvedValidation, 0, len(a.Validations)),
+	}
+	if a.OptionSet != nil {
+		r.Options = localizeOptions(a.OptionSet.Options, chain)
+	}
+	r.DataSource = a.DataSource
+	for _, v := range a.Validations {
+		message, _ := inLocale(chain, "", func(l string) string { return v.Messages[l] })
+		r.Validations = append(r.Validations, model.ResolvedValidation{
+			Name:     v.Name,
+			RuleName: v.RuleName,
+			Params:   rawJSON(v.ValidationParams),
+			Message:  message,
+		})
+	}
+	return r
+}
+
+// rawJSON passes a JSON column through as is, or as null when it is empty.
+func rawJSON(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
+
+// validateSubmission checks the answers in sub against the pages of r. See
+// model.Submission.
+func validateSubmission(r *model.ResolvedForm, sub *model.Submission) (*model.ValidationReport, error) {
+	pages := make(model.Pages, len(r.Pages))
+	for i, p := range r.Pages {
+		pages[i] = model.Page{Name: p.Name, Navigation: p.Navigation}
+	}
+	// Answers to hidden attributes are ignored, including by the conditions
+	// of the attributes after them.
+	values := make(map[string]interface{}, len(sub.Values))
+	for name, v := range sub.Values {
+		values[name] = v
+	}
+	for _, p := range r.Pages {
+		for _, a := range p.Attributes {
+			if !holds(a.VisibleIf, values) {
+				delete(values, a.Name)
+			}
+		}
+	}
+	report := &model.ValidationReport{Errors: []model.FieldError{}}
+
+	if sub.Page != "" {
+		current := -1
+		for i, p := range r.Pages {
+			if p.Name == sub.Page {
+				current = i
+			}
+		}
+		if current < 0 {
+			return nil, fmt.Errorf("%w: form has no page %q", ErrInvalid, sub.Page)
+		}
+		validatePage(report, &r.Pages[current], values)
+		if next := rules.Next(pages, current, values); next < len(pages) {
+			report.NextPage = pages[next].Name
+		}
+	} else {
+		for i := 0; i < len(pages); i = rules.Next(pages, i, values) {
+			validatePage(report, &r.Pages[i], values)
+		}
+	}
+	report.Valid = len(report.Errors) == 0
+	return report, nil
+}
+
+func validatePage(report *model.ValidationReport, p *model.ResolvedPage, values map[string]interface{}) {
+	report.Pages = append(report.Pages, p.Name)
+	attributes := map[string]*model.ResolvedAttribute{}
+	for i := range p.Attributes {
+		attributes[p.Attributes[i].Name] = &p.Attributes[i]
+	}
+	groups := map[string]*model.ResolvedGroup{}
+	for i := range p.Groups {
+		groups[p.Groups[i].Name] = &p.Groups[i]
+	}
+	for _, name := range p.Order {
+		if a, ok := attributes[name]; ok {
+			validateAnswer(report, model.FieldError{Page: p.Name, Attribute: a.Name}, a, values)
+		} else if g, ok := groups[name]; ok {
+			validateGroup(report, p.Name, g, values)
+		}
+	}
+}
+
+// validateGroup checks the number of occurrences of g and each answer in
+// them. Conditions within an occurrence see its answers over the form's.
+func validateGroup(report *model.ValidationReport, page string, g *model.ResolvedGroup, values map[string]interface{}) {
+	fail := func(rule, message string) {
+		report.Errors = append(report.Errors, model.FieldError{Page: page, Group: g.Name, Rule: rule, Message: message})
+	}
+	var occurrences []interface{}
+	if !rules.Empty(values[g.Name]) {
+		var ok bool
+		if occurrences, ok = values[g.Name].([]interface{}); !ok {
+			fail("occurrences", "must be a list")
+			return
+		}
+	}
+	if len(occurrences) < g.MinOccurs {
+		fail("min_occurs", fmt.Sprintf("needs at least %d entries", g.MinOccurs))
+	}
+	if g.MaxOccurs > 0 && len(occurrences) > g.MaxOccurs {
+		fail("max_occurs", fmt.Sprintf("allows at most %d entries", g.MaxOccurs))
+	}
+	for i, o := range occurrences {
+		answers, ok := o.(map[string]interface{})
+		if !ok {
+			index := i
+			report.Errors = append(report.Errors, model.FieldError{Page: page, Group: g.Name, Occurrence: &index,
+				Rule: "occurrences", Message: "must be an object"})
+			continue
+		}
+		merged := make(map[string]interface{}, len(values)+len(answers))
+		for name, v := range values {
+			merged[name] = v
+		}
+		for name, v := range answers {
+			merged[name] = v
+		}
+		for j := range g.Attributes {
+			a := &g.Attributes[j]
+			index := i
+			validateAnswer(report, model.FieldError{Page: page, Group: g.Name, Occurrence: &index, Attribute: a.Name}, a, merged)
+		}
+	}
+}
+
+// validateAnswer checks the answer to a unless a is hidden, reporting each
+// broken rule as a copy of at.
+func validateAnswer(report *model.ValidationReport, at model.FieldError, a *model.ResolvedAttribute, values map[string]interface{}) {
+	if !holds(a.VisibleIf, values) {
+		return
+	}
+	if a.RequiredIf != "" && rules.Empty(values[a.Name]) && holds(a.RequiredIf, values) {
+		at.Rule, at.Message = "required_if", "is required"
+		report.Errors = append(report.Errors, at)
+		return
+	}
+	for _, v := range a.Validations {
+		if ok, msg := rules.Check(v.RuleName, string(v.Params), values[a.Name]); !ok {
+			if v.Message != "" {
+				msg = v.Message
+			}
+			e := at
+			e.Rule, e.Message = v.RuleName, msg
+			report.Errors = append(report.Errors, e)
+		}
+	}
+	if ok, msg := checkChoice(a, values[a.Name]); !ok {
+		at.Rule, at.Message = "options", msg
+		report.Errors = append(report.Errors, at)
+	}
+}
+
+// multiChoice is the element type whose answers are lists of options.
+const multiChoice = "multi_choice"
+
+// checkChoice reports whether value picks from the enabled options of a, one
+// of them or, for a multi_choice attribute, a list of them. Attributes without
+// an option set accept anything.
+func checkChoice(a *model.ResolvedAttribute, value interface{}) (bool, string) {
+	if a.Options == nil || rules.Empty(value) {
+		return true, ""
+	}
+	picks, isList := value.([]interface{})
+	switch {
+	case a.ElementType == multiChoice && !isList:
+		return false, "must be a list of options"
+	case a.ElementType != multiChoice && isList:
+		return false, "must be a single option"
+	case !isList:
+		picks = []interface{}{value}
+	}
+	enabled := map[string]bool{}
+	for _, o := range a.Options {
+		if !o.Disabled {
+			enabled[o.Value] = true
+		}
+	}
+	for _, p := range picks {
+		if !enabled[rules.Text(p)] {
+			return false, fmt.Sprintf("%q is not one of the options", rules.Text(p))
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,86 @@
+// service/purge_service.go
+package service
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/repository"
+)
+
+// purger is implemented by every catalog repository.
+type purger interface {
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// PurgeService permanently removes entities that have been soft-deleted for
+// longer than the retention period.
+type PurgeService struct {
+	// purgers are ordered so that dependents go before what they depend on.
+	purgers   []namedPurger
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+type namedPurger struct {
+	resource string
+	purger
+}
+
+func NewPurgeService(typeRepo *repository.TypeRepository, validationRepo *repository.ValidationRepository,
+	optionSetRepo *repository.OptionSetRepository, attributeRepo *repository.AttributeRepository, formRepo *repository.FormRepository,
+	retention time.Duration, logger *slog.Logger) *PurgeService {
+	return &PurgeService{
+		purgers: []namedPurger{
+			{"form", formRepo},
+			{"attribute", attributeRepo},
+			{"option_set", optionSetRepo},
+			{"validation", validationRepo},
+			{"type", typeRepo},
+		},
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// PurgeOnce removes everything deleted before now minus the retention and
+// returns the number of rows removed per resource.
+func (s *PurgeService) PurgeOnce(ctx context.Context) (map[string]int64, error) {
+	ctx, span := tracer.Start(ctx, "PurgeService.PurgeOnce")
+	defer span.End()
+
+	cutoff := time.Now().Add(-s.retention)
+	purged := map[string]int64{}
+	for _, p := range s.purgers {
+		for {
+			n, err := p.Purge(ctx, cutoff)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "error purging deleted entities", slog.String("resource", p.resource), slog.Any("error", err))
+				return purged, err
+			}
+			purged[p.resource] += n
+			if n == 0 {
+				break
+			}
+		}
+	}
+	return purged, nil
+}
+
+// Run calls PurgeOnce every interval until ctx is done.
+func (s *PurgeService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeOnce(ctx)
+			if err == nil {
+				s.logger.InfoContext(ctx, "purged deleted entities", slog.Any("purged", purged))
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// service/schema.go
+package service
+
+import (
+	"stellarsky.ai/platform/public-config-service/model"
+	"stellarsky.ai/platform/public-config-service/rules"
+)
+
+// schemaDialect is the JSON Schema draft exported schemas declare.
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonTypes maps element types whose answers are not strings to their JSON
+// Schema type.
+var jsonTypes = map[string]string{
+	"boolean":    "boolean",
+	"currency":   "number",
+	"decimal":    "number",
+	"number":     "number",
+	"percentage": "number",
+	"quantity":   "number",
+	"year":       "integer",
+}
+
+// formSchema returns a JSON Schema describing submissions to r: an object
+// with a property per attribute and an array of objects per group. Rules
+// without a keyword equivalent, and conditional rules, are left out.
+func formSchema(r *model.ResolvedForm) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, p := range r.Pages {
+		for _, a := range p.Attributes {
+			properties[a.Name] = attributeSchema(&a)
+			if isRequired(&a) {
+				required = append(required, a.Name)
+			}
+		}
+		for _, g := range p.Groups {
+			properties[g.Name] = groupSchema(&g)
+			if g.MinOccurs > 0 {
+				required = append(required, g.Name)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"$schema":    schemaDialect,
+		"title":      r.Name,
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+func groupSchema(g *model.ResolvedGroup) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, a := range g.Attributes {
+		properties[a.Name] = attributeSchema(&a)
+		if isRequired(&a) {
+			required = append(required, a.Name)
+		}
+	}
+	s := map[string]interface{}{
+		"type": "array",
+		"items": map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		},
+		"minItems": g.MinOccurs,
+	}
+	if g.Title != "" {
+		s["title"] = g.Title
+	}
+	if g.MaxOccurs > 0 {
+		s["maxItems"] = g.MaxOccurs
+	}
+	return s
+}
+
+func attributeSchema(a *model.ResolvedAttribute) map[string]interface{} {
+	s := map[string]interface{}{"type": "string"}
+	if t, ok := jsonTypes[a.ElementType]; ok {
+		s["type"] = t
+	}
+	if a.Label != "" {
+		s["title"] = a.Label
+	}
+	if a.HelpText != "" {
+		s["description"] = a.HelpText
+	}
+	if a.Options != nil {
+		values := []string{}
+		for _, o := range a.Options {
+			if !o.Disabled {
+				values = append(values, o.Value)
+			}
+		}
+		if a.ElementType == multiChoice {
+			s["type"] = "array"
+			s["items"] = map[string]interface{}{"enum": values}
+			s["uniqueItems"] = true
+		} else {
+			s["enum"] = values
+		}
+	}
+	for _, v := range a.Validations {
+		p, err := rules.ParseParams(string(v.Params))
+		if err != nil {
+			continue
+		}
+		switch v.RuleName {
+		case "min_length":
+			setIf(s, "minLength", p.Min)
+		case "max_length":
+			setIf(s, "maxLength", p.Max)
+		case "exact_length":
+			setIf(s, "minLength", p.Length)
+			setIf(s, "maxLength", p.Length)
+		case "match_pattern":
+			setIf(s, "pattern", p.Pattern)
+		case "min_value":
+			setIf(s, "minimum", p.Min)
+		case "max_value":
+			setIf(s, "maximum", p.Max)
+		case "equals":
+			setIf(s, "const", p.Value)
+		case "email":
+			s["format"] = "email"
+		case "valid_url":
+			s["format"] = "uri"
+		}
+	}
+	return s
+}
+
+// isRequired reports whether a must always be answered.
+func isRequired(a *model.ResolvedAttribute) bool {
+	if a.VisibleIf != "" || a.RequiredIf != "" {
+		return false
+	}
+	for _, v := range a.Validations {
+		if v.RuleName == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+func setIf[T any](s map[string]interface{}, keyword string, value *T) {
+	if value != nil {
+		s[keyword] = *value
+	}
+}
@@ -0,0 +1,7 @@
+// service/tracing.go
+package service
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts a span for each service call; the HTTP span is its parent.
+var tracer = otel.Tracer("stellarsky.ai/platform/public-config-service/service")
@@ -3,7 +3,7 @@ package service
 
 import (
 	"context"
-	"errors"
+	"fmt"
 
 	"golang.org/x/exp/slog"
 	"stellarsky.ai/platform/public-config-service/model"
@@ -22,47 +22,226 @@ func NewTypeService(repo *repository.TypeRepository, logger *slog.Logger) *TypeS
 	}
 }
 
-func (s *TypeService) GetAllTypes() ([]model.Type, error) {
-	types, err := s.repo.GetAll(context.Background())
+func (s *TypeService) GetAllTypes(ctx context.Context, includeDeleted bool) ([]model.Type, error) {
+	ctx, span := tracer.Start(ctx, "TypeService.GetAllTypes")
+	defer span.End()
+
+	types, err := s.repo.GetAll(ctx, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting all types", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting all types", slog.Any("error", err))
 		return nil, err
 	}
 	return types, nil
 }
 
-func (s *TypeService) GetType(id int64) (*model.Type, error) {
-	t, err := s.repo.GetByID(context.Background(), id)
+func (s *TypeService) GetType(ctx context.Context, id int64, includeDeleted bool) (*model.Type, error) {
+	ctx, span := tracer.Start(ctx, "TypeService.GetType")
+	defer span.End()
+
+	t, err := s.repo.GetByID(ctx, id, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting type by id", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting type by id", slog.Any("error", err))
 		return nil, err
 	}
 	if t == nil {
-		return nil, errors.New("type not found")
+		return nil, fmt.Errorf("type %w", ErrNotFound)
 	}
 	return t, nil
 }
 
-func (s *TypeService) CreateType(t *model.Type) error {
-	if err := s.repo.Create(context.Background(), t); err != nil {
-		s.logger.Error("error creating type", slog.Any("error", err))
-		return err
+func (s *TypeService) CreateType(ctx context.Context, t *model.Type) error {
+	ctx, span := tracer.Start(ctx, "TypeService.CreateType")
+	defer span.End()
+
+	err := s.repo.Transaction(ctx, func(repo *repository.TypeRepository) error {
+		if _, err := checkType(ctx, t, repo.LockedLineage); err != nil {
+			return err
+		}
+		return repo.Create(ctx, t)
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error creating type", slog.Any("error", err))
+		return translate("type", err)
 	}
 	return nil
 }
 
-func (s *TypeService) UpdateType(t *model.Type) error {
-	if err := s.repo.Update(context.Background(), t); err != nil {
-		s.logger.Error("error updating type", slog.Any("error", err))
-		return err
+// UpdateType writes t. The type and the types it comes to extend stay locked
+// from checking its lineage until it is written, so that concurrent updates
+// cannot together make types extend each other or chain too many types.
+func (s *TypeService) UpdateType(ctx context.Context, t *model.Type) error {
+	ctx, span := tracer.Start(ctx, "TypeService.UpdateType")
+	defer span.End()
+
+	err := s.repo.Transaction(ctx, func(repo *repository.TypeRepository) error {
+		if err := repo.Lock(ctx, t.ID); err != nil {
+			return err
+		}
+		types, err := checkType(ctx, t, repo.LockedLineage)
+		if err != nil {
+			return err
+		}
+		if t.ParentID != nil {
+			depth, err := repo.DescendantDepth(ctx, t.ID)
+			if err != nil {
+				return err
+			}
+			if len(types)+depth > repository.MaxTypeDepth {
+				return fmt.Errorf("%w: type %d and the types extending it would chain more than %d types",
+					ErrInvalid, t.ID, repository.MaxTypeDepth)
+			}
+		}
+		return repo.Update(ctx, t)
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error updating type", slog.Any("error", err))
+		return translate("type", err)
 	}
 	return nil
 }
 
-func (s *TypeService) DeleteType(id int64) error {
-	if err := s.repo.Delete(context.Background(), id); err != nil {
-		s.logger.Error("error deleting type", slog.Any("error", err))
+// checkType rejects a type extending a type that does not exist, itself or a
+// type extending it, or more types than repository.MaxTypeDepth allows, and a
+// type whose design, merged with what it inherits, is invalid. It looks up the
+// lineages of live types with lineage and returns t followed by its ancestors.
+func checkType(ctx context.Context, t *model.Type, lineage func(context.Context, uint64) ([]model.Type, error)) ([]model.Type, error) {
+	// Parents are set by ID; Parent is only read from catalogs.
+	t.Parent = nil
+	if err := checkDesign(t); err != nil {
+		return nil, err
+	}
+	if t.ParentID == nil {
+		return []model.Type{*t}, nil
+	}
+	ancestors, err := lineage(ctx, *t.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ancestors) == 0 {
+		return nil, fmt.Errorf("%w: parent type %d does not exist", ErrInvalid, *t.ParentID)
+	}
+	for _, a := range ancestors {
+		if t.ID != 0 && a.ID == t.ID {
+			return nil, fmt.Errorf("%w: type %d cannot extend itself or a type extending it", ErrInvalid, t.ID)
+		}
+	}
+	if len(ancestors) >= repository.MaxTypeDepth {
+		return nil, fmt.Errorf("%w: types cannot extend more than %d types", ErrInvalid, repository.MaxTypeDepth-1)
+	}
+	types := append([]model.Type{*t}, ancestors...)
+	e, err := effectiveType(types)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDesign(&model.Type{DesignSchema: e.DesignSchema, DesignDefaults: e.DesignDefaults}); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// GetEffectiveType returns type id with what it inherits from the types it
+// extends merged in, as its attributes get it.
+func (s *TypeService) GetEffectiveType(ctx context.Context, id int64) (*model.EffectiveType, error) {
+	ctx, span := tracer.Start(ctx, "TypeService.GetEffectiveType")
+	defer span.End()
+
+	lineage, err := s.repo.Lineage(ctx, uint64(id))
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting type lineage", slog.Any("error", err))
+		return nil, err
+	}
+	if len(lineage) == 0 {
+		return nil, fmt.Errorf("type %w", ErrNotFound)
+	}
+	return effectiveType(lineage)
+}
+
+// DeleteType deletes type id according to opts and returns the types
+// extending it, its attributes and the forms containing them, as they were or
+// with opts.DryRun are. A restricted delete of a type that is extended or has
+// attributes fails with an *InUseError.
+func (s *TypeService) DeleteType(ctx context.Context, id int64, opts DeleteOptions) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "TypeService.DeleteType")
+	defer span.End()
+
+	usages, err := deleteWithUsages(ctx, s.repo, "type", id, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error deleting type", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// GetTypeUsages lists the live types, attributes and forms that depend on
+// type id.
+func (s *TypeService) GetTypeUsages(ctx context.Context, id int64) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "TypeService.GetTypeUsages")
+	defer span.End()
+
+	if _, err := s.GetType(ctx, id, false); err != nil {
+		return nil, err
+	}
+	usages, err := s.repo.Usages(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting type usages", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// RestoreType undeletes a soft-deleted type and returns it. It fails with
+// ErrConflict when the type is not deleted or a live type has taken its name.
+func (s *TypeService) RestoreType(ctx context.Context, id int64) (*model.Type, error) {
+	ctx, span := tracer.Start(ctx, "TypeService.RestoreType")
+	defer span.End()
+
+	t, err := s.GetType(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !t.DeletedAt.Valid {
+		return nil, fmt.Errorf("%w: type %d is not deleted", ErrConflict, id)
+	}
+	if err := s.repo.Restore(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error restoring type", slog.Any("error", err))
+		return nil, translate("type", err)
+	}
+	return s.GetType(ctx, id, false)
+}
+
+// BatchTypes applies ops in one transaction: either every operation succeeds
+// or none has any effect and a *BatchError reports which one failed. Deletes
+// are restricted, failing for a type that is still in use.
+func (s *TypeService) BatchTypes(ctx context.Context, ops []model.BatchOperation[model.Type]) ([]model.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "TypeService.BatchTypes")
+	defer span.End()
+
+	if err := CheckBatch(ops); err != nil {
+		return nil, err
+	}
+	var results []model.BatchResult
+	err := s.repo.Transaction(ctx, func(repo *repository.TypeRepository) error {
+		tx := NewTypeService(repo, s.logger)
+		var err error
+		results, err = runBatch(ctx, ops, batchActions[model.Type]{
+			create: func(ctx context.Context, t *model.Type) (uint64, error) {
+				err := tx.CreateType(ctx, t)
+				return t.ID, err
+			},
+			update: func(ctx context.Context, id uint64, t *model.Type) error {
+				t.ID = id
+				return tx.UpdateType(ctx, t)
+			},
+			delete: func(ctx context.Context, id uint64) error {
+				_, err := tx.DeleteType(ctx, int64(id), DeleteOptions{})
+				return err
+			},
+		})
 		return err
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error applying type batch", slog.Any("error", err))
+		return nil, err
 	}
-	return nil
+	return results, nil
 }
@@ -3,7 +3,7 @@ package service
 
 import (
 	"context"
-	"errors"
+	"fmt"
 
 	"golang.org/x/exp/slog"
 	"stellarsky.ai/platform/public-config-service/model"
@@ -22,47 +22,149 @@ func NewValidationService(repo *repository.ValidationRepository, logger *slog.Lo
 	}
 }
 
-func (s *ValidationService) GetAllValidations() ([]model.Validation, error) {
-	validations, err := s.repo.GetAll(context.Background())
+func (s *ValidationService) GetAllValidations(ctx context.Context, includeDeleted bool) ([]model.Validation, error) {
+	ctx, span := tracer.Start(ctx, "ValidationService.GetAllValidations")
+	defer span.End()
+
+	validations, err := s.repo.GetAll(ctx, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting all validations", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting all validations", slog.Any("error", err))
 		return nil, err
 	}
 	return validations, nil
 }
 
-func (s *ValidationService) GetValidation(id int64) (*model.Validation, error) {
-	v, err := s.repo.GetByID(context.Background(), id)
+func (s *ValidationService) GetValidation(ctx context.Context, id int64, includeDeleted bool) (*model.Validation, error) {
+	ctx, span := tracer.Start(ctx, "ValidationService.GetValidation")
+	defer span.End()
+
+	v, err := s.repo.GetByID(ctx, id, includeDeleted)
 	if err != nil {
-		s.logger.Error("error getting validation by id", slog.Any("error", err))
+		s.logger.ErrorContext(ctx, "error getting validation by id", slog.Any("error", err))
 		return nil, err
 	}
 	if v == nil {
-		return nil, errors.New("validation not found")
+		return nil, fmt.Errorf("validation %w", ErrNotFound)
 	}
 	return v, nil
 }
 
-func (s *ValidationService) CreateValidation(v *model.Validation) error {
-	if err := s.repo.Create(context.Background(), v); err != nil {
-		s.logger.Error("error creating validation", slog.Any("error", err))
+func (s *ValidationService) CreateValidation(ctx context.Context, v *model.Validation) error {
+	ctx, span := tracer.Start(ctx, "ValidationService.CreateValidation")
+	defer span.End()
+
+	if err := checkMessages(v); err != nil {
 		return err
 	}
+	if err := s.repo.Create(ctx, v); err != nil {
+		s.logger.ErrorContext(ctx, "error creating validation", slog.Any("error", err))
+		return translate("validation", err)
+	}
 	return nil
 }
 
-func (s *ValidationService) UpdateValidation(v *model.Validation) error {
-	if err := s.repo.Update(context.Background(), v); err != nil {
-		s.logger.Error("error updating validation", slog.Any("error", err))
+func (s *ValidationService) UpdateValidation(ctx context.Context, v *model.Validation) error {
+	ctx, span := tracer.Start(ctx, "ValidationService.UpdateValidation")
+	defer span.End()
+
+	if err := checkMessages(v); err != nil {
 		return err
 	}
+	if err := s.repo.Update(ctx, v); err != nil {
+		s.logger.ErrorContext(ctx, "error updating validation", slog.Any("error", err))
+		return translate("validation", err)
+	}
 	return nil
 }
 
-func (s *ValidationService) DeleteValidation(id int64) error {
-	if err := s.repo.Delete(context.Background(), id); err != nil {
-		s.logger.Error("error deleting validation", slog.Any("error", err))
+// DeleteValidation deletes validation id according to opts and returns the
+// types and attributes it was bound to, or with opts.DryRun is bound to, and
+// the forms containing those attributes. A restricted delete of a bound
+// validation fails with an *InUseError.
+func (s *ValidationService) DeleteValidation(ctx context.Context, id int64, opts DeleteOptions) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "ValidationService.DeleteValidation")
+	defer span.End()
+
+	usages, err := deleteWithUsages(ctx, s.repo, "validation", id, opts)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error deleting validation", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// GetValidationUsages lists the live types and attributes bound to validation
+// id and the forms containing those attributes.
+func (s *ValidationService) GetValidationUsages(ctx context.Context, id int64) (*model.Usages, error) {
+	ctx, span := tracer.Start(ctx, "ValidationService.GetValidationUsages")
+	defer span.End()
+
+	if _, err := s.GetValidation(ctx, id, false); err != nil {
+		return nil, err
+	}
+	usages, err := s.repo.Usages(ctx, id)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error getting validation usages", slog.Any("error", err))
+		return nil, err
+	}
+	return usages, nil
+}
+
+// RestoreValidation undeletes a soft-deleted validation and returns it. It
+// fails with ErrConflict when the validation is not deleted or a live
+// validation has taken its name.
+func (s *ValidationService) RestoreValidation(ctx context.Context, id int64) (*model.Validation, error) {
+	ctx, span := tracer.Start(ctx, "ValidationService.RestoreValidation")
+	defer span.End()
+
+	v, err := s.GetValidation(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if !v.DeletedAt.Valid {
+		return nil, fmt.Errorf("%w: validation %d is not deleted", ErrConflict, id)
+	}
+	if err := s.repo.Restore(ctx, id); err != nil {
+		s.logger.ErrorContext(ctx, "error restoring validation", slog.Any("error", err))
+		return nil, translate("validation", err)
+	}
+	return s.GetValidation(ctx, id, false)
+}
+
+// BatchValidations applies ops in one transaction: either every operation
+// succeeds or none has any effect and a *BatchError reports which one failed.
+// Deletes are restricted, failing for a validation still bound to a type or
+// an attribute.
+func (s *ValidationService) BatchValidations(ctx context.Context, ops []model.BatchOperation[model.Validation]) ([]model.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "ValidationService.BatchValidations")
+	defer span.End()
+
+	if err := CheckBatch(ops); err != nil {
+		return nil, err
+	}
+	var results []model.BatchResult
+	err := s.repo.Transaction(ctx, func(repo *repository.ValidationRepository) error {
+		tx := NewValidationService(repo, s.logger)
+		var err error
+		results, err = runBatch(ctx, ops, batchActions[model.Validation]{
+			create: func(ctx context.Context, v *model.Validation) (uint64, error) {
+				err := tx.CreateValidation(ctx, v)
+				return v.ID, err
+			},
+			update: func(ctx context.Context, id uint64, v *model.Validation) error {
+				v.ID = id
+				return tx.UpdateValidation(ctx, v)
+			},
+			delete: func(ctx context.Context, id uint64) error {
+				_, err := tx.DeleteValidation(ctx, int64(id), DeleteOptions{})
+				return err
+			},
+		})
 		return err
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "error applying validation batch", slog.Any("error", err))
+		return nil, err
 	}
-	return nil
+	return results, nil
 }
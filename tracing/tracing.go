@@ -0,0 +1,109 @@
+// tracing/tracing.go
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"golang.org/x/exp/slog"
+	"stellarsky.ai/platform/public-config-service/buildinfo"
+	"stellarsky.ai/platform/public-config-service/config"
+)
+
+// Exporter names accepted in config.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterMemory = "memory"
+	ExporterNone   = "none"
+)
+
+// Provider wraps the SDK tracer provider. Memory is only set when the memory
+// exporter is selected, so tests can inspect finished spans.
+type Provider struct {
+	*sdktrace.TracerProvider
+	Memory *tracetest.InMemoryExporter
+}
+
+// Setup builds a tracer provider from config and installs it, together with
+// the W3C trace-context and baggage propagators, as the global default. With
+// the "none" exporter spans are still created, so trace IDs propagate, but
+// nothing is exported.
+func Setup(ctx context.Context, cfg config.TracingConfig, logger *slog.Logger) (*Provider, error) {
+	p := &Provider{}
+	var exporter sdktrace.SpanExporter
+	switch strings.ToLower(cfg.Exporter) {
+	case ExporterOTLP:
+		opts := []otlptracegrpc.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("creating stdout exporter: %w", err)
+		}
+		exporter = exp
+	case ExporterMemory:
+		p.Memory = tracetest.NewInMemoryExporter()
+		exporter = p.Memory
+	case ExporterNone, "":
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "public-config-service"
+	}
+	version, _ := buildinfo.Info()
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version))
+
+	// A ratio of zero samples no new traces.
+	ratio := cfg.SampleRatio
+	if ratio > 1 {
+		ratio = 1
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}
+	switch {
+	case p.Memory != nil:
+		// Synchronous export so spans are visible as soon as they end.
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	case exporter != nil:
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	p.TracerProvider = sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(p.TracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	logger.InfoContext(ctx, "Tracing configured",
+		slog.String("exporter", cfg.Exporter),
+		slog.String("service", serviceName),
+		slog.Float64("sample_ratio", ratio))
+	return p, nil
+}